@@ -1,5 +1,21 @@
 import api from "./axios";
 
+/**
+ * Get paginated appointments
+ * @param {object} params - Query parameters
+ * @param {string} params.status - Filter by status
+ * @param {string} params.start - Start time lower bound (YYYY-MM-DD or RFC3339)
+ * @param {string} params.end - Start time upper bound (YYYY-MM-DD or RFC3339)
+ * @param {number} params.tech_id - Filter by technician
+ * @param {number} params.member_id - Filter by member
+ * @param {number} params.service_id - Filter by service
+ * @param {string} params.keyword - Search member name or phone
+ * @param {string} params.sort_by - created_at (default) or start_time
+ * @param {string} params.sort_order - asc or desc (default)
+ * @param {number} params.page - Page number
+ * @param {number} params.page_size - Page size
+ * @returns {Promise<{appointments: array, total: number, page: number, page_size: number}>}
+ */
 export const getAppointments = (params) => {
 	return api.get("/api/appointments", { params });
 };
@@ -15,3 +31,11 @@ export const cancelAppointment = (id) => {
 export const completeAppointment = (id, data) => {
 	return api.put(`/api/appointments/${id}/complete`, data);
 };
+
+export const getAppointmentNotes = (id) => {
+	return api.get(`/api/appointments/${id}/notes`);
+};
+
+export const createAppointmentNote = (id, data) => {
+	return api.post(`/api/appointments/${id}/notes`, data);
+};
@@ -0,0 +1,9 @@
+import api from "./axios";
+
+export const getCampaignIssues = (params = {}) => {
+	return api.get("/api/campaign-issues", { params });
+};
+
+export const runCampaigns = () => {
+	return api.post("/api/campaigns/run");
+};
@@ -0,0 +1,17 @@
+import api from "./axios";
+
+export const getMemberTiers = () => {
+	return api.get("/api/member-tiers");
+};
+
+export const createMemberTier = (data) => {
+	return api.post("/api/member-tiers", data);
+};
+
+export const updateMemberTier = (id, data) => {
+	return api.put(`/api/member-tiers/${id}`, data);
+};
+
+export const deleteMemberTier = (id) => {
+	return api.delete(`/api/member-tiers/${id}`);
+};
@@ -1,13 +1,37 @@
 import api from "./axios";
 
-export const getMembers = () => {
-	return api.get("/api/members");
+export const getMembers = (params = {}) => {
+	return api.get("/api/members", { params });
+};
+
+export const getMember = (id) => {
+	return api.get(`/api/members/${id}`);
 };
 
 export const createMember = (data) => {
 	return api.post("/api/members", data);
 };
 
+export const updateMember = (id, data) => {
+	return api.put(`/api/members/${id}`, data);
+};
+
+export const mergeMember = (id, duplicateId) => {
+	return api.post(`/api/members/${id}/merge`, { duplicate_id: duplicateId });
+};
+
+export const bindMemberReferrer = (id, referrerCode) => {
+	return api.post(`/api/members/${id}/referrer`, { referrer_code: referrerCode });
+};
+
+export const getMemberLevelHistory = (id) => {
+	return api.get(`/api/members/${id}/level-history`);
+};
+
+export const recalculateMemberLevels = () => {
+	return api.post("/api/member-levels/recalculate");
+};
+
 export const updateMemberBalance = (id, balance) => {
 	return api.put(`/api/members/${id}/balance`, { balance });
 };
@@ -15,3 +39,39 @@ export const updateMemberBalance = (id, balance) => {
 export const deleteMember = (id) => {
 	return api.delete(`/api/members/${id}`);
 };
+
+export const getMemberPoints = (id, params = {}) => {
+	return api.get(`/api/members/${id}/points`, { params });
+};
+
+export const getMemberSegments = () => {
+	return api.get("/api/member-segments");
+};
+
+export const getSegmentMembers = (params = {}) => {
+	return api.get("/api/member-segments/members", { params });
+};
+
+export const recalculateMemberSegments = () => {
+	return api.post("/api/member-segments/recalculate");
+};
+
+export const getHealthConditions = () => {
+	return api.get("/api/health-conditions");
+};
+
+export const getMemberHealthIntake = (id) => {
+	return api.get(`/api/members/${id}/health-intake`);
+};
+
+export const saveMemberHealthIntake = (id, data) => {
+	return api.put(`/api/members/${id}/health-intake`, data);
+};
+
+export const getMemberHealthIntakeHistory = (id) => {
+	return api.get(`/api/members/${id}/health-intake/history`);
+};
+
+export const getMemberTreatmentNotes = (id) => {
+	return api.get(`/api/members/${id}/treatment-notes`);
+};
@@ -3,3 +3,7 @@ import api from "./axios";
 export const listOrders = (params = {}) => {
 	return api.get("/api/orders", { params });
 };
+
+export const refundOrder = (id, reason) => {
+	return api.post(`/api/orders/${id}/refund`, { reason });
+};
@@ -1,10 +1,15 @@
 import api from "./axios";
 
 /**
- * Get list of all physical products
+ * Get physical products (all of them unless page/page_size is given)
  * @param {object} params - Query parameters
  * @param {boolean} params.is_active - Filter by active status
- * @returns {Promise<{products: array, total: number}>}
+ * @param {string} params.q - Search by name, SKU or barcode
+ * @param {number} params.category_id - Filter by category (0 = uncategorized)
+ * @param {number} params.parent_id - Filter variants of a parent product (0 = standalone products)
+ * @param {number} params.page - Page number
+ * @param {number} params.page_size - Page size
+ * @returns {Promise<{products: array, total: number, page?: number, page_size?: number}>}
  */
 export const getProducts = (params = {}) => {
 	return api.get("/api/products", { params });
@@ -19,16 +24,33 @@ export const getProduct = (id) => {
 	return api.get(`/api/products/${id}`);
 };
 
+/**
+ * Look up a product by its barcode (front-desk scanner)
+ * @param {string} code - Barcode
+ * @returns {Promise<object>}
+ */
+export const getProductByBarcode = (code) => {
+	return api.get(`/api/products/barcode/${encodeURIComponent(code)}`);
+};
+
 /**
  * Create a new physical product
  * @param {object} data - Product data
- * @param {string} data.name - Product name
+ * @param {string} data.name - Product name (derived from the parent for variants)
  * @param {number} data.stock - Initial stock
  * @param {number} data.retail_price - Retail price
  * @param {number} data.cost_price - Cost price
  * @param {string} data.description - Product description
  * @param {string} data.image_url - Product image URL
  * @param {boolean} data.is_active - Whether product is active
+ * @param {number} data.reorder_point - Reorder point: stock at or below it counts as low stock (optional, default 10)
+ * @param {number} data.target_stock - Target stock level for reorder suggestions (optional, 0 = estimate from sales velocity)
+ * @param {number} data.category_id - Category ID (optional)
+ * @param {string} data.sku - SKU, unique when set (optional)
+ * @param {string} data.barcode - Barcode, unique when set (optional)
+ * @param {number} data.parent_id - Parent product ID; name, category, description and image come from the parent (optional)
+ * @param {string} data.variant_name - Variant name such as "10ml", required with parent_id
+ * @param {object} data.options - Variant attributes, e.g. { size: "10ml", scent: "lavender" } (optional)
  * @returns {Promise<object>}
  */
 export const createProduct = (data) => {
@@ -95,6 +117,12 @@ export const getAllInventoryLogs = (params = {}) => {
  * @param {number} data.product_id - Product ID
  * @param {number} data.change_amount - Change amount (positive for restock, negative for sale)
  * @param {string} data.action_type - Action type: restock, sale, or adjustment
+ * @param {number} data.member_id - Buying member (required for a sale)
+ * @param {number} data.points_used - Points redeemed on a sale (optional)
+ * @param {number} data.coupon_id - Coupon redeemed on a sale (optional)
+ * @param {number} data.unit_cost - Unit cost of a restock (optional, updates moving-average cost)
+ * @param {string} data.batch_no - Batch number of a restock (optional, generated when empty)
+ * @param {string} data.expiry_date - Expiry date of a restock, YYYY-MM-DD (optional)
  * @param {string} data.remark - Remark/note
  * @returns {Promise<object>}
  */
@@ -107,6 +135,9 @@ export const createInventoryChange = (data) => {
  * @param {array} items - Array of restock items
  * @param {number} items[].product_id - Product ID
  * @param {number} items[].quantity - Quantity to restock
+ * @param {number} items[].unit_cost - Unit cost (optional, updates moving-average cost)
+ * @param {string} items[].batch_no - Batch number (optional, generated when empty)
+ * @param {string} items[].expiry_date - Expiry date, YYYY-MM-DD (optional)
  * @param {string} items[].remark - Remark
  * @returns {Promise<object>}
  */
@@ -121,3 +152,143 @@ export const batchRestock = (items) => {
 export const getInventoryStats = () => {
 	return api.get("/api/inventory/stats");
 };
+
+/**
+ * Get product batches in first-expiring-first-out order
+ * @param {object} params - Query parameters
+ * @param {number} params.product_id - Filter by product ID
+ * @param {boolean} params.include_empty - Include batches with no remaining quantity
+ * @returns {Promise<{batches: array, total: number}>}
+ */
+export const getProductBatches = (params = {}) => {
+	return api.get("/api/inventory/batches", { params });
+};
+
+/**
+ * Get batches expiring within the given number of days (including expired ones)
+ * @param {number} days - Look-ahead window in days (default 30)
+ * @returns {Promise<{batches: array, total: number, expired_count: number, total_quantity: number, days: number}>}
+ */
+export const getExpiringBatches = (days = 30) => {
+	return api.get("/api/inventory/batches/expiring", { params: { days } });
+};
+
+/**
+ * Write off quantity from a batch (e.g. expired or damaged goods)
+ * @param {number} batchId - Batch ID
+ * @param {object} data - Write-off data
+ * @param {number} data.quantity - Quantity to write off
+ * @param {string} data.remark - Remark
+ * @returns {Promise<object>}
+ */
+export const writeOffBatch = (batchId, data) => {
+	return api.post(`/api/inventory/batches/${batchId}/write-off`, data);
+};
+
+/**
+ * Get active products at or below their reorder point with suggested reorder quantities
+ * @returns {Promise<{products: array, total: number, total_suggested: number, velocity_window_days: number, cover_days: number}>}
+ */
+export const getLowStockProducts = () => {
+	return api.get("/api/inventory/low-stock");
+};
+
+/**
+ * Get low-stock alerts raised when stock crossed the reorder point
+ * @param {object} params - Query parameters
+ * @param {string} params.status - open (default), resolved or all
+ * @param {number} params.product_id - Filter by product ID
+ * @param {number} params.page - Page number
+ * @param {number} params.page_size - Page size
+ * @returns {Promise<{alerts: array, total: number, page: number, page_size: number}>}
+ */
+export const getStockAlerts = (params = {}) => {
+	return api.get("/api/inventory/alerts", { params });
+};
+
+/**
+ * Get product categories with their product counts
+ * @returns {Promise<{categories: array, total: number}>}
+ */
+export const getProductCategories = () => {
+	return api.get("/api/product-categories");
+};
+
+/**
+ * Create a product category (manager only)
+ * @param {object} data - name, description, sort_order
+ * @returns {Promise<object>}
+ */
+export const createProductCategory = (data) => {
+	return api.post("/api/product-categories", data);
+};
+
+/**
+ * Update a product category (manager only)
+ * @param {number} id - Category ID
+ * @param {object} data - name, description, sort_order
+ * @returns {Promise<object>}
+ */
+export const updateProductCategory = (id, data) => {
+	return api.put(`/api/product-categories/${id}`, data);
+};
+
+/**
+ * Delete a product category that has no products (manager only)
+ * @param {number} id - Category ID
+ * @returns {Promise<object>}
+ */
+export const deleteProductCategory = (id) => {
+	return api.delete(`/api/product-categories/${id}`);
+};
+
+/**
+ * Get parent products with their variants and stock summary
+ * @param {object} params - Query parameters
+ * @param {string} params.q - Search by name
+ * @param {number} params.category_id - Filter by category
+ * @returns {Promise<{parents: array, total: number}>}
+ */
+export const getParentProducts = (params = {}) => {
+	return api.get("/api/product-parents", { params });
+};
+
+/**
+ * Get a parent product with variant stock and sales rolled up to the parent
+ * @param {number} id - Parent product ID
+ * @param {object} params - Query parameters
+ * @param {string} params.start - Sales start date (optional)
+ * @param {string} params.end - Sales end date (optional)
+ * @returns {Promise<{parent: object, summary: object, variant_sales: array, sales: object}>}
+ */
+export const getParentProduct = (id, params = {}) => {
+	return api.get(`/api/product-parents/${id}`, { params });
+};
+
+/**
+ * Create a parent product (manager only)
+ * @param {object} data - name, description, image_url, category_id
+ * @returns {Promise<object>}
+ */
+export const createParentProduct = (data) => {
+	return api.post("/api/product-parents", data);
+};
+
+/**
+ * Update a parent product and its variants' catalog info (manager only)
+ * @param {number} id - Parent product ID
+ * @param {object} data - name, description, image_url, category_id
+ * @returns {Promise<object>}
+ */
+export const updateParentProduct = (id, data) => {
+	return api.put(`/api/product-parents/${id}`, data);
+};
+
+/**
+ * Delete a parent product that has no variants (manager only)
+ * @param {number} id - Parent product ID
+ * @returns {Promise<object>}
+ */
+export const deleteParentProduct = (id) => {
+	return api.delete(`/api/product-parents/${id}`);
+};
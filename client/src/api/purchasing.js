@@ -0,0 +1,117 @@
+import api from "./axios";
+
+/**
+ * Get suppliers
+ * @param {object} params - Query parameters
+ * @param {boolean} params.is_active - Filter by active status
+ * @returns {Promise<{suppliers: array, total: number}>}
+ */
+export const getSuppliers = (params = {}) => {
+	return api.get("/api/suppliers", { params });
+};
+
+/**
+ * Create a supplier (manager only)
+ * @param {object} data - name, contact_name, phone, email, address, note
+ * @returns {Promise<object>}
+ */
+export const createSupplier = (data) => {
+	return api.post("/api/suppliers", data);
+};
+
+/**
+ * Update a supplier (manager only)
+ * @param {number} id - Supplier ID
+ * @param {object} data - Supplier data
+ * @returns {Promise<object>}
+ */
+export const updateSupplier = (id, data) => {
+	return api.put(`/api/suppliers/${id}`, data);
+};
+
+/**
+ * Delete a supplier without open purchase orders (manager only)
+ * @param {number} id - Supplier ID
+ * @returns {Promise<object>}
+ */
+export const deleteSupplier = (id) => {
+	return api.delete(`/api/suppliers/${id}`);
+};
+
+/**
+ * Get purchase orders
+ * @param {object} params - status, supplier_id, page, page_size
+ * @returns {Promise<{purchase_orders: array, total: number}>}
+ */
+export const getPurchaseOrders = (params = {}) => {
+	return api.get("/api/purchase-orders", { params });
+};
+
+/**
+ * Get a purchase order with its lines
+ * @param {number} id - Purchase order ID
+ * @returns {Promise<object>}
+ */
+export const getPurchaseOrder = (id) => {
+	return api.get(`/api/purchase-orders/${id}`);
+};
+
+/**
+ * Create a draft purchase order (manager only)
+ * @param {object} data - Purchase order data
+ * @param {number} data.supplier_id - Supplier ID
+ * @param {array} data.lines - Lines: { product_id, quantity, unit_cost }
+ * @returns {Promise<object>}
+ */
+export const createPurchaseOrder = (data) => {
+	return api.post("/api/purchase-orders", data);
+};
+
+/**
+ * Update a draft purchase order, replacing its lines (manager only)
+ * @param {number} id - Purchase order ID
+ * @param {object} data - Purchase order data
+ * @returns {Promise<object>}
+ */
+export const updatePurchaseOrder = (id, data) => {
+	return api.put(`/api/purchase-orders/${id}`, data);
+};
+
+/**
+ * Submit a draft purchase order to the supplier (manager only)
+ * @param {number} id - Purchase order ID
+ * @returns {Promise<object>}
+ */
+export const submitPurchaseOrder = (id) => {
+	return api.post(`/api/purchase-orders/${id}/submit`);
+};
+
+/**
+ * Cancel a purchase order that has not received any goods (manager only)
+ * @param {number} id - Purchase order ID
+ * @returns {Promise<object>}
+ */
+export const cancelPurchaseOrder = (id) => {
+	return api.post(`/api/purchase-orders/${id}/cancel`);
+};
+
+/**
+ * Receive goods against a purchase order
+ * @param {number} id - Purchase order ID
+ * @param {array} items - Items: { line_id, quantity }
+ * @param {string} remark - Remark
+ * @returns {Promise<object>}
+ */
+export const receivePurchaseOrder = (id, items, remark = "") => {
+	return api.post(`/api/purchase-orders/${id}/receive`, { items, remark });
+};
+
+/**
+ * Get quantities ordered but not yet received, per product
+ * @param {object} params - Query parameters
+ * @param {number} params.product_id - Filter by product
+ * @returns {Promise<{items: array}>}
+ */
+export const getOnOrderQuantities = (params = {}) => {
+	return api.get("/api/purchase-orders/on-order", { params });
+};
@@ -0,0 +1,45 @@
+import api from "./axios";
+
+export const getReferralProgram = () => {
+	return api.get("/api/referral-program");
+};
+
+export const updateReferralProgram = (data) => {
+	return api.put("/api/referral-program", data);
+};
+
+export const getReferralTree = (memberId, depth = 3) => {
+	return api.get(`/api/members/${memberId}/referral-tree`, { params: { depth } });
+};
+
+export const getReferralAttribution = (params) => {
+	return api.get("/api/dashboard/referral-attribution", { params });
+};
+
+export const getFissionLogs = (params) => {
+	return api.get("/api/fission/logs", { params });
+};
+
+export const requestCommissionWithdrawal = (memberId, data) => {
+	return api.post(`/api/members/${memberId}/commission-withdrawals`, data);
+};
+
+export const getCommissionWithdrawals = (params) => {
+	return api.get("/api/commission-withdrawals", { params });
+};
+
+export const approveCommissionWithdrawal = (id) => {
+	return api.post(`/api/commission-withdrawals/${id}/approve`);
+};
+
+export const rejectCommissionWithdrawal = (id, reason) => {
+	return api.post(`/api/commission-withdrawals/${id}/reject`, { reason });
+};
+
+export const payCommissionWithdrawal = (id, payoutRef) => {
+	return api.post(`/api/commission-withdrawals/${id}/pay`, { payout_ref: payoutRef });
+};
+
+export const getCommissionLiability = () => {
+	return api.get("/api/commission/liability");
+};
@@ -0,0 +1,45 @@
+import api from "./axios";
+
+export const getTags = () => {
+	return api.get("/api/tags");
+};
+
+export const createTag = (data) => {
+	return api.post("/api/tags", data);
+};
+
+export const deleteTag = (id) => {
+	return api.delete(`/api/tags/${id}`);
+};
+
+export const setMemberTags = (memberId, tags) => {
+	return api.put(`/api/members/${memberId}/tags`, { tags });
+};
+
+export const getSegments = () => {
+	return api.get("/api/segments");
+};
+
+export const previewSegment = (filter) => {
+	return api.post("/api/segments/preview", { filter });
+};
+
+export const createSegment = (data) => {
+	return api.post("/api/segments", data);
+};
+
+export const updateSegment = (id, data) => {
+	return api.put(`/api/segments/${id}`, data);
+};
+
+export const deleteSegment = (id) => {
+	return api.delete(`/api/segments/${id}`);
+};
+
+export const runSegmentAction = (id, data) => {
+	return api.post(`/api/segments/${id}/actions`, data);
+};
+
+export const getSegmentActionLogs = (id) => {
+	return api.get(`/api/segments/${id}/actions`);
+};
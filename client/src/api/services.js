@@ -15,3 +15,11 @@ export const updateService = (id, data) => {
 export const deleteService = (id) => {
 	return api.delete(`/api/services/${id}`);
 };
+
+export const getServiceMaterials = (id) => {
+	return api.get(`/api/services/${id}/materials`);
+};
+
+export const updateServiceMaterials = (id, materials) => {
+	return api.put(`/api/services/${id}/materials`, { materials });
+};
@@ -0,0 +1,65 @@
+import api from "./axios";
+
+/**
+ * Get stocktake sessions
+ * @param {object} params - Query parameters
+ * @param {string} params.status - counting, approved or cancelled
+ * @param {number} params.page - Page number
+ * @param {number} params.page_size - Page size
+ * @returns {Promise<{stocktakes: array, total: number, page: number, page_size: number}>}
+ */
+export const getStocktakes = (params = {}) => {
+	return api.get("/api/stocktakes", { params });
+};
+
+/**
+ * Get a stocktake with per-line variances and a summary
+ * @param {number} id - Stocktake ID
+ * @returns {Promise<object>}
+ */
+export const getStocktake = (id) => {
+	return api.get(`/api/stocktakes/${id}`);
+};
+
+/**
+ * Open a stocktake, snapshotting the expected stock of the selected products
+ * @param {object} data - Stocktake data
+ * @param {number[]} data.product_ids - Products to count (empty = all active products)
+ * @param {string} data.remark - Remark
+ * @returns {Promise<object>}
+ */
+export const createStocktake = (data = {}) => {
+	return api.post("/api/stocktakes", data);
+};
+
+/**
+ * Save counted quantities; can be called several times while counting
+ * @param {number} id - Stocktake ID
+ * @param {array} counts - Counted lines
+ * @param {number} counts[].product_id - Product ID
+ * @param {number} counts[].counted_quantity - Counted quantity
+ * @param {string} counts[].reason_code - damaged, expired, lost, theft, miscount, sample or other (required for variances before approval)
+ * @param {string} counts[].remark - Remark
+ * @returns {Promise<object>}
+ */
+export const saveStocktakeCounts = (id, counts) => {
+	return api.put(`/api/stocktakes/${id}/counts`, { counts });
+};
+
+/**
+ * Approve a stocktake and post all variances as adjustment logs (manager only)
+ * @param {number} id - Stocktake ID
+ * @returns {Promise<{stocktake: object, logs: array}>}
+ */
+export const approveStocktake = (id) => {
+	return api.post(`/api/stocktakes/${id}/approve`);
+};
+
+/**
+ * Cancel a stocktake that is still counting (manager only)
+ * @param {number} id - Stocktake ID
+ * @returns {Promise<object>}
+ */
+export const cancelStocktake = (id) => {
+	return api.post(`/api/stocktakes/${id}/cancel`);
+};
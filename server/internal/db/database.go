@@ -5,7 +5,9 @@ import (
 	"log"
 	"path/filepath"
 
+	"server/internal/membership"
 	"server/internal/models"
+	"server/pkg/util"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
@@ -40,6 +42,14 @@ func Init(dbPath string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("auto migrate: %w", err)
 	}
 
+	if err := backfillInvitationCodes(database); err != nil {
+		return nil, fmt.Errorf("backfill invitation codes: %w", err)
+	}
+
+	if err := membership.SeedDefaultTiers(database); err != nil {
+		return nil, fmt.Errorf("seed member tiers: %w", err)
+	}
+
 	// Create default admin user if not exists
 	if err := createDefaultAdmin(database); err != nil {
 		return nil, fmt.Errorf("create default admin: %w", err)
@@ -62,9 +72,64 @@ func migrate(database *gorm.DB) error {
 		&models.FissionLog{},
 		&models.PhysicalProduct{},
 		&models.InventoryLog{},
+		&models.MemberLevelChange{},
+		&models.MemberTier{},
+		&models.PointsTransaction{},
+		&models.Coupon{},
+		&models.CampaignIssue{},
+		&models.MemberRFM{},
+		&models.Tag{},
+		&models.SavedSegment{},
+		&models.SegmentActionLog{},
+		&models.HealthIntake{},
+		&models.TreatmentNote{},
+		&models.ReferralProgram{},
+		&models.CommissionWithdrawal{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.ProductBatch{},
+		&models.BatchConsumption{}, &models.StockAlert{}, &models.Stocktake{}, &models.StocktakeLine{}, &models.ServiceMaterial{}, &models.ProductCategory{}, &models.ParentProduct{},
 	)
 }
 
+// GenerateUniqueInvitationCode returns an invitation code not used by any member,
+// including soft-deleted ones since the unique index still covers them.
+func GenerateUniqueInvitationCode(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		code, err := util.GenerateInvitationCode(util.InvitationCodeLength)
+		if err != nil {
+			return "", err
+		}
+		var count int64
+		if err := tx.Unscoped().Model(&models.Member{}).Where("invitation_code = ?", code).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a unique invitation code")
+}
+
+// backfillInvitationCodes assigns codes to members created before codes were server generated
+func backfillInvitationCodes(database *gorm.DB) error {
+	var members []models.Member
+	if err := database.Unscoped().Where("invitation_code IS NULL OR invitation_code = ''").Find(&members).Error; err != nil {
+		return err
+	}
+	for _, m := range members {
+		code, err := GenerateUniqueInvitationCode(database)
+		if err != nil {
+			return err
+		}
+		if err := database.Unscoped().Model(&models.Member{}).Where("id = ?", m.ID).Update("invitation_code", code).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // createDefaultAdmin creates a default admin user if none exists
 func createDefaultAdmin(database *gorm.DB) error {
 	var count int64
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -84,3 +85,105 @@ func TestListAppointments_PreloadFix(t *testing.T) {
 		t.Errorf("Response body should contain service name 'Massage', got: %s", body)
 	}
 }
+
+func TestListAppointments_FiltersAndPagination(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	alice := models.Member{Name: "Alice", Phone: "13800000001", InvitationCode: "code-13800000001"}
+	bob := models.Member{Name: "Bob", Phone: "13900000002", InvitationCode: "code-13900000002"}
+	testDB.Create(&alice)
+	testDB.Create(&bob)
+
+	tech1 := models.Technician{Name: "T1", Status: 0}
+	tech2 := models.Technician{Name: "T2", Status: 0}
+	testDB.Create(&tech1)
+	testDB.Create(&tech2)
+
+	service := models.ServiceProduct{Name: "Massage", Duration: 60, Price: 100}
+	testDB.Create(&service)
+
+	base := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		appt := models.Appointment{
+			MemberID:    alice.ID,
+			TechID:      tech1.ID,
+			ServiceID:   service.ID,
+			StartTime:   base.AddDate(0, 0, i),
+			EndTime:     base.AddDate(0, 0, i).Add(time.Hour),
+			Status:      "pending",
+			OriginPrice: 100,
+			ActualPrice: 100,
+		}
+		testDB.Create(&appt)
+	}
+	other := models.Appointment{
+		MemberID:    bob.ID,
+		TechID:      tech2.ID,
+		ServiceID:   service.ID,
+		StartTime:   base,
+		EndTime:     base.Add(time.Hour),
+		Status:      "completed",
+		OriginPrice: 100,
+		ActualPrice: 100,
+	}
+	testDB.Create(&other)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/appointments", ListAppointments)
+
+	type listResult struct {
+		Data struct {
+			Appointments []models.Appointment `json:"appointments"`
+			Total        int64                `json:"total"`
+			Page         int                  `json:"page"`
+			PageSize     int                  `json:"page_size"`
+		} `json:"data"`
+	}
+	doGet := func(url string) listResult {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d, body=%s", url, w.Code, w.Body.String())
+		}
+		var res listResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return res
+	}
+
+	res := doGet("/appointments?keyword=1380000&sort_by=start_time&sort_order=asc&page=2&page_size=2")
+	if res.Data.Total != 5 {
+		t.Fatalf("expected total 5, got %d", res.Data.Total)
+	}
+	if len(res.Data.Appointments) != 2 {
+		t.Fatalf("expected 2 appointments on page 2, got %d", len(res.Data.Appointments))
+	}
+	if !res.Data.Appointments[0].StartTime.Equal(base.AddDate(0, 0, 2)) {
+		t.Fatalf("expected third appointment first on page 2, got %v", res.Data.Appointments[0].StartTime)
+	}
+
+	res = doGet("/appointments?tech_id=" + strconvUint(tech1.ID) + "&start=2025-03-02&end=2025-03-03")
+	if res.Data.Total != 2 {
+		t.Fatalf("expected 2 appointments in date range, got %d", res.Data.Total)
+	}
+
+	res = doGet("/appointments?member_id=" + strconvUint(bob.ID) + "&status=completed")
+	if res.Data.Total != 1 || res.Data.Appointments[0].Member.Name != "Bob" {
+		t.Fatalf("expected Bob's single completed appointment, got %+v", res.Data)
+	}
+
+	for _, url := range []string{"/appointments?tech_id=abc", "/appointments?member_id=-1", "/appointments?service_id=1.5"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s: expected 400, got %d", url, w.Code)
+		}
+	}
+}
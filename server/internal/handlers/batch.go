@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WriteOffBatchRequest 批次报损的请求体
+type WriteOffBatchRequest struct {
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+	Remark   string `json:"remark" binding:"max=200"`
+}
+
+// ListProductBatches 查询商品批次，默认只返回仍有剩余的批次，按先到期先出顺序排列
+// GET /api/inventory/batches?product_id=1&include_empty=true
+func ListProductBatches(c *gin.Context) {
+	query := db.DB.Preload("Product").Order("expiry_date IS NULL, expiry_date ASC, received_at ASC, id ASC")
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		productID, err := strconv.ParseUint(productIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid product_id", nil))
+			return
+		}
+		query = query.Where("product_id = ?", uint(productID))
+	}
+	if c.Query("include_empty") != "true" {
+		query = query.Where("remaining > 0")
+	}
+
+	batches := make([]models.ProductBatch, 0)
+	if err := query.Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch batches", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"batches": batches,
+		"total":   len(batches),
+	}, ""))
+}
+
+// GetExpiringBatches 列出 N 天内到期（含已过期）且仍有剩余的批次，便于打折处理或报损
+// GET /api/inventory/batches/expiring?days=30
+func GetExpiringBatches(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 || days > 365 {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "days must be between 0 and 365", nil))
+		return
+	}
+
+	batches, err := inventory.ExpiringBatches(db.DB, days, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch expiring batches", err.Error()))
+		return
+	}
+
+	var expiredCount, quantity int
+	for _, b := range batches {
+		if b.Expired {
+			expiredCount++
+		}
+		quantity += b.Remaining
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"batches":        batches,
+		"total":          len(batches),
+		"expired_count":  expiredCount,
+		"total_quantity": quantity,
+		"days":           days,
+	}, ""))
+}
+
+// WriteOffProductBatch 报损指定批次，扣减商品库存并记录纠错日志
+// POST /api/inventory/batches/:id/write-off
+func WriteOffProductBatch(c *gin.Context) {
+	batchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid batch ID", nil))
+		return
+	}
+	var req WriteOffBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.Error(http.StatusUnauthorized, "User not authenticated", nil))
+		return
+	}
+
+	var inventoryLog *models.InventoryLog
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		inventoryLog, err = inventory.WriteOffBatch(tx, uint(batchID), req.Quantity, userID.(uint), req.Remark)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Batch not found", nil))
+		case errors.Is(err, inventory.ErrBatchInsufficient), errors.Is(err, inventory.ErrInsufficientStock):
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to write off batch", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(inventoryLog, "Batch written off successfully"))
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProductBatches_RestockSaleExpiringAndWriteOff(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-batch", PasswordHash: "x", Role: "manager", IsActive: true}
+	product := models.PhysicalProduct{Name: "Serum", Stock: 0, RetailPrice: 120, CostPrice: 40, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/inventory/change", CreateInventoryChange)
+	router.GET("/api/inventory/batches", ListProductBatches)
+	router.GET("/api/inventory/batches/expiring", GetExpiringBatches)
+	router.POST("/api/inventory/batches/:id/write-off", WriteOffProductBatch)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	soon := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	later := time.Now().AddDate(0, 0, 200).Format("2006-01-02")
+	for _, body := range []gin.H{
+		{"product_id": product.ID, "change_amount": 5, "action_type": "restock", "batch_no": "LATER", "expiry_date": later},
+		{"product_id": product.ID, "change_amount": 3, "action_type": "restock", "batch_no": "SOON", "expiry_date": soon},
+	} {
+		if w := send("POST", "/api/inventory/change", body); w.Code != http.StatusOK {
+			t.Fatalf("restock: expected 200, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": 1, "action_type": "restock", "expiry_date": "2026-13-01"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid expiry date, got %d", w.Code)
+	}
+
+	// 销售 4 件：先取临期批次的 3 件，再取远期批次 1 件
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -4, "action_type": "adjustment"}); w.Code != http.StatusOK {
+		t.Fatalf("sale: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var listed struct {
+		Data struct {
+			Batches []models.ProductBatch `json:"batches"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", fmt.Sprintf("/api/inventory/batches?product_id=%d", product.ID), nil).Body.Bytes(), &listed)
+	if len(listed.Data.Batches) != 1 || listed.Data.Batches[0].BatchNo != "LATER" || listed.Data.Batches[0].Remaining != 4 {
+		t.Fatalf("expected only LATER batch with 4 remaining, got %+v", listed.Data.Batches)
+	}
+	laterBatch := listed.Data.Batches[0]
+
+	var expiring struct {
+		Data struct {
+			Total         int `json:"total"`
+			TotalQuantity int `json:"total_quantity"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", "/api/inventory/batches/expiring?days=365", nil).Body.Bytes(), &expiring)
+	if expiring.Data.Total != 1 || expiring.Data.TotalQuantity != 4 {
+		t.Fatalf("unexpected expiring report: %+v", expiring.Data)
+	}
+	if w := send("GET", "/api/inventory/batches/expiring?days=400", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for out-of-range days, got %d", w.Code)
+	}
+
+	path := fmt.Sprintf("/api/inventory/batches/%d/write-off", laterBatch.ID)
+	if w := send("POST", path, gin.H{"quantity": 5}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when writing off more than remaining, got %d", w.Code)
+	}
+	if w := send("POST", path, gin.H{"quantity": 4, "remark": "damaged"}); w.Code != http.StatusOK {
+		t.Fatalf("write-off: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var saved models.PhysicalProduct
+	testDB.First(&saved, product.ID)
+	if saved.Stock != 0 {
+		t.Fatalf("expected stock 0 after write-off, got %d", saved.Stock)
+	}
+	if w := send("POST", "/api/inventory/batches/9999/write-off", gin.H{"quantity": 1}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown batch, got %d", w.Code)
+	}
+}
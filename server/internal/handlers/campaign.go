@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCampaignIssues 获取会员关怀活动的发放记录
+// 支持按活动类型、会员、发放时间筛选，并分页返回
+func ListCampaignIssues(c *gin.Context) {
+	query := db.DB.Model(&models.CampaignIssue{})
+
+	if campaign := c.Query("campaign"); campaign != "" {
+		query = query.Where("campaign = ?", campaign)
+	}
+	if memberID := c.Query("member_id"); memberID != "" {
+		query = query.Where("member_id = ?", memberID)
+	}
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+	if !start.IsZero() {
+		query = query.Where("created_at >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("created_at < ?", end)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count campaign issues", err.Error()))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	issues := make([]models.CampaignIssue, 0)
+	if err := query.Preload("Member").
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&issues).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch campaign issues", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"issues":    issues,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, ""))
+}
+
+// RunMemberCampaigns 立即执行一次生日/入会周年活动发放（与定时任务逻辑一致，重复执行不会重复发放）
+func RunMemberCampaigns(c *gin.Context) {
+	summary, err := membership.RunCampaigns(db.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to run member campaigns", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(summary, "Member campaigns executed"))
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+	"server/internal/referral"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateCommissionWithdrawalRequest 佣金提现申请的请求体
+type CreateCommissionWithdrawalRequest struct {
+	Amount  float64 `json:"amount" binding:"required,gt=0"`
+	Method  string  `json:"method" binding:"required,oneof=bank wechat alipay cash"`
+	Account string  `json:"account" binding:"max=128"`
+	Note    string  `json:"note" binding:"max=255"`
+}
+
+// RejectCommissionWithdrawalRequest 驳回提现申请的请求体
+type RejectCommissionWithdrawalRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// PayCommissionWithdrawalRequest 记录提现打款的请求体
+type PayCommissionWithdrawalRequest struct {
+	PayoutRef string `json:"payout_ref" binding:"max=128"` // 打款流水号，现金打款可为空
+}
+
+// writeWithdrawalError 将提现流程中的错误转换为对应的 HTTP 响应
+func writeWithdrawalError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Withdrawal or member not found", nil))
+	case errors.Is(err, referral.ErrInsufficientCommission), errors.Is(err, referral.ErrWithdrawalBelowMinimum):
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+	case errors.Is(err, referral.ErrWithdrawalState):
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, fallback, err.Error()))
+	}
+}
+
+// CreateCommissionWithdrawal 为会员提交佣金提现申请，金额立即从佣金钱包冻结
+// POST /api/members/:id/commission-withdrawals
+func CreateCommissionWithdrawal(c *gin.Context) {
+	memberID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member ID", nil))
+		return
+	}
+	var req CreateCommissionWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if req.Method != "cash" && req.Account == "" {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "account is required for non-cash payouts", nil))
+		return
+	}
+
+	var withdrawal *models.CommissionWithdrawal
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = referral.RequestWithdrawal(tx, referral.WithdrawalRequest{
+			MemberID:    uint(memberID),
+			Amount:      req.Amount,
+			Method:      req.Method,
+			Account:     req.Account,
+			Note:        req.Note,
+			RequestedBy: currentOperatorID(c),
+		})
+		return err
+	})
+	if err != nil {
+		writeWithdrawalError(c, err, "Failed to create withdrawal")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(withdrawal, "Withdrawal requested successfully"))
+}
+
+// ListCommissionWithdrawals 分页查询佣金提现申请
+// GET /api/commission-withdrawals?member_id=1&status=pending&page=1&page_size=20
+func ListCommissionWithdrawals(c *gin.Context) {
+	query := db.DB.Model(&models.CommissionWithdrawal{})
+	if memberIDStr := c.Query("member_id"); memberIDStr != "" {
+		memberID, err := strconv.ParseUint(memberIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member_id", nil))
+			return
+		}
+		query = query.Where("member_id = ?", uint(memberID))
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count withdrawals", err.Error()))
+		return
+	}
+	withdrawals := make([]models.CommissionWithdrawal, 0)
+	if err := query.Preload("Member").Order("created_at DESC, id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&withdrawals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch withdrawals", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"withdrawals": withdrawals,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+	}, ""))
+}
+
+// withdrawalID 解析路径中的提现申请ID
+func withdrawalID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid withdrawal ID", nil))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ApproveCommissionWithdrawal 审核通过提现申请
+// POST /api/commission-withdrawals/:id/approve
+func ApproveCommissionWithdrawal(c *gin.Context) {
+	id, ok := withdrawalID(c)
+	if !ok {
+		return
+	}
+	var withdrawal *models.CommissionWithdrawal
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = referral.ApproveWithdrawal(tx, id, currentOperatorID(c), time.Now())
+		return err
+	})
+	if err != nil {
+		writeWithdrawalError(c, err, "Failed to approve withdrawal")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(withdrawal, "Withdrawal approved"))
+}
+
+// RejectCommissionWithdrawal 驳回提现申请，冻结金额退回佣金钱包
+// POST /api/commission-withdrawals/:id/reject
+func RejectCommissionWithdrawal(c *gin.Context) {
+	id, ok := withdrawalID(c)
+	if !ok {
+		return
+	}
+	var req RejectCommissionWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	var withdrawal *models.CommissionWithdrawal
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = referral.RejectWithdrawal(tx, id, req.Reason, currentOperatorID(c), time.Now())
+		return err
+	})
+	if err != nil {
+		writeWithdrawalError(c, err, "Failed to reject withdrawal")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(withdrawal, "Withdrawal rejected"))
+}
+
+// PayCommissionWithdrawal 记录已审核提现的线下打款
+// POST /api/commission-withdrawals/:id/pay
+func PayCommissionWithdrawal(c *gin.Context) {
+	id, ok := withdrawalID(c)
+	if !ok {
+		return
+	}
+	// 现金打款可不带请求体（payout_ref 为空）
+	var req PayCommissionWithdrawalRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+			return
+		}
+	}
+	var withdrawal *models.CommissionWithdrawal
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = referral.MarkWithdrawalPaid(tx, id, req.PayoutRef, currentOperatorID(c), time.Now())
+		return err
+	})
+	if err != nil {
+		writeWithdrawalError(c, err, "Failed to record payout")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(withdrawal, "Payout recorded"))
+}
+
+// GetCommissionLiability 汇总门店尚未支付给推荐人的佣金负债
+// GET /api/commission/liability
+func GetCommissionLiability(c *gin.Context) {
+	liability, err := referral.OutstandingLiability(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize commission liability", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(liability, ""))
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCommissionWithdrawal_RequestApprovePay(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	member := models.Member{Name: "Alice", Phone: "13800000091", InvitationCode: "W0091", Balance: 500, CommissionBalance: 80}
+	testDB.Create(&member)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/members/:id/commission-withdrawals", CreateCommissionWithdrawal)
+	router.GET("/api/commission-withdrawals", ListCommissionWithdrawals)
+	router.POST("/api/commission-withdrawals/:id/approve", ApproveCommissionWithdrawal)
+	router.POST("/api/commission-withdrawals/:id/pay", PayCommissionWithdrawal)
+	router.GET("/api/commission/liability", GetCommissionLiability)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	requestPath := "/api/members/" + strconvUint(member.ID) + "/commission-withdrawals"
+	if w := send("POST", requestPath, gin.H{"amount": 50, "method": "bank"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without bank account, got %d", w.Code)
+	}
+	// 预存余额不能提现
+	if w := send("POST", requestPath, gin.H{"amount": 100, "method": "cash"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when exceeding commission wallet, got %d", w.Code)
+	}
+	w := send("POST", requestPath, gin.H{"amount": 50, "method": "wechat", "account": "wx-alice"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data models.CommissionWithdrawal `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	withdrawalPath := "/api/commission-withdrawals/" + strconvUint(created.Data.ID)
+	if w := send("POST", withdrawalPath+"/pay", gin.H{"payout_ref": "WX001"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 paying before approval, got %d", w.Code)
+	}
+	if w := send("POST", withdrawalPath+"/approve", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on approve, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("POST", withdrawalPath+"/pay", gin.H{"payout_ref": "WX001"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on payout, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	var saved models.Member
+	testDB.First(&saved, member.ID)
+	if saved.CommissionBalance != 30 || saved.Balance != 500 {
+		t.Fatalf("expected wallet 30 and prepaid balance untouched, got wallet %v balance %v", saved.CommissionBalance, saved.Balance)
+	}
+
+	w = send("GET", "/api/commission-withdrawals?status=paid&member_id="+strconvUint(member.ID), nil)
+	var list struct {
+		Data struct {
+			Withdrawals []models.CommissionWithdrawal `json:"withdrawals"`
+			Total       int64                         `json:"total"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Data.Total != 1 || list.Data.Withdrawals[0].PayoutRef != "WX001" || list.Data.Withdrawals[0].Member.Name != "Alice" {
+		t.Fatalf("unexpected withdrawal list: %+v", list.Data)
+	}
+
+	w = send("GET", "/api/commission/liability", nil)
+	var liability struct {
+		Data struct {
+			Wallet      float64 `json:"wallet"`
+			Outstanding float64 `json:"outstanding"`
+			PaidOut     float64 `json:"paid_out"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &liability)
+	if liability.Data.Wallet != 30 || liability.Data.Outstanding != 30 || liability.Data.PaidOut != 50 {
+		t.Fatalf("unexpected liability: %+v", liability.Data)
+	}
+
+	// 现金打款无需流水号，可不带请求体
+	json.Unmarshal(send("POST", requestPath, gin.H{"amount": 20, "method": "cash"}).Body.Bytes(), &created)
+	cashPath := "/api/commission-withdrawals/" + strconvUint(created.Data.ID)
+	send("POST", cashPath+"/approve", nil)
+	req, _ := http.NewRequest("POST", cashPath+"/pay", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 recording a cash payout without body, got %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCoupons_RedeemedAtSettlementAndSale(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-coupon", PasswordHash: "x", Role: "operator", IsActive: true}
+	testDB.Create(&operator)
+	member := models.Member{Name: "Cou", Phone: "10000000341", InvitationCode: "code-10000000341", Level: "basic", IsActive: true}
+	other := models.Member{Name: "Oth", Phone: "10000000342", InvitationCode: "code-10000000342", Level: "basic", IsActive: true}
+	testDB.Create(&member)
+	testDB.Create(&other)
+
+	now := time.Now()
+	birthday, _ := membership.IssueCoupon(testDB, member.ID, "生日礼遇", 30, 100, now.AddDate(0, 0, 30))
+	highMin, _ := membership.IssueCoupon(testDB, member.ID, "满减", 20, 500, now.AddDate(0, 0, 30))
+	expired, _ := membership.IssueCoupon(testDB, member.ID, "过期", 20, 0, now.AddDate(0, 0, -1))
+	othersCoupon, _ := membership.IssueCoupon(testDB, other.ID, "他人", 20, 0, now.AddDate(0, 0, 30))
+	shopCoupon, _ := membership.IssueCoupon(testDB, member.ID, "商品券", 10, 0, now.AddDate(0, 0, 30))
+
+	tech := models.Technician{Name: "Tech", Status: 0}
+	service := models.ServiceProduct{Name: "Facial", Duration: 60, Price: 120}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	newAppointment := func() models.Appointment {
+		appt := models.Appointment{
+			MemberID: member.ID, TechID: tech.ID, ServiceID: service.ID,
+			StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-1 * time.Hour),
+			Status: "pending", OriginPrice: 120, ActualPrice: 120,
+		}
+		testDB.Create(&appt)
+		return appt
+	}
+	appt := newAppointment()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.PUT("/api/appointments/:id/complete", CompleteAppointment)
+	router.POST("/api/inventory/change", CreateInventoryChange)
+
+	send := func(method, path string, body gin.H) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	complete := func(id uint, body gin.H) *httptest.ResponseRecorder {
+		return send("PUT", "/api/appointments/"+strconvUint(id)+"/complete", body)
+	}
+
+	for name, couponID := range map[string]uint{"min spend": highMin.ID, "expired": expired.ID, "other member": othersCoupon.ID} {
+		if w := complete(appt.ID, gin.H{"payment_method": "cash", "cash_amount": 100, "coupon_id": couponID}); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+	// 付款金额需扣除优惠券面额
+	if w := complete(appt.ID, gin.H{"payment_method": "cash", "cash_amount": 120, "coupon_id": birthday.ID}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for payment ignoring coupon, got %d", w.Code)
+	}
+	if w := complete(appt.ID, gin.H{"payment_method": "cash", "cash_amount": 90, "coupon_id": birthday.ID}); w.Code != http.StatusOK {
+		t.Fatalf("settle with coupon: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	var order models.Order
+	testDB.Where("appointment_id = ?", appt.ID).First(&order)
+	if order.PaidAmount != 90 || order.CouponDeduction != 30 || order.CouponID == nil || *order.CouponID != birthday.ID {
+		t.Fatalf("expected paid 90 with 30 coupon deduction, got %+v", order)
+	}
+	var used models.Coupon
+	testDB.First(&used, birthday.ID)
+	if used.UsedAt == nil || used.OrderID == nil || *used.OrderID != order.ID {
+		t.Fatalf("expected coupon marked used by order %d, got %+v", order.ID, used)
+	}
+
+	// 同一张券不能再次使用
+	again := newAppointment()
+	if w := complete(again.ID, gin.H{"payment_method": "cash", "cash_amount": 90, "coupon_id": birthday.ID}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 reusing a coupon, got %d", w.Code)
+	}
+
+	// 商品销售同样可以使用优惠券
+	product := models.PhysicalProduct{Name: "Mask", Stock: 5, RetailPrice: 25, CostPrice: 10, IsActive: true}
+	testDB.Create(&product)
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -2, "action_type": "sale",
+		"member_id": member.ID, "coupon_id": shopCoupon.ID}); w.Code != http.StatusOK {
+		t.Fatalf("sale with coupon: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var sale models.Order
+	testDB.Where("coupon_id = ?", shopCoupon.ID).First(&sale)
+	if sale.OrderType != "physical" || sale.PaidAmount != 40 || sale.CouponDeduction != 10 {
+		t.Fatalf("expected physical order paid 40 after 10 coupon deduction, got %+v", sale)
+	}
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -1, "action_type": "sale",
+		"member_id": member.ID, "coupon_id": shopCoupon.ID}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 reusing a sale coupon, got %d", w.Code)
+	}
+	testDB.First(&product, product.ID)
+	if product.Stock != 3 {
+		t.Fatalf("rejected sale must not change stock, got %d", product.Stock)
+	}
+}
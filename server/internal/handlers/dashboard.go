@@ -9,8 +9,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"server/internal/inventory"
 	"server/internal/models"
 	"server/internal/response"
+	"server/internal/segment"
+	"server/pkg/util"
 )
 
 // DashboardHandler handles dashboard-related requests
@@ -194,6 +197,8 @@ func (h *DashboardHandler) GetServiceRanking(c *gin.Context) {
 		ServiceName  string  `json:"service_name"`
 		OrderCount   int64   `json:"order_count"`
 		TotalRevenue float64 `json:"total_revenue"`
+		TotalCost    float64 `json:"total_cost"`   // 消耗的耗材成本
+		GrossProfit  float64 `json:"gross_profit"` // 扣除耗材成本后的毛利
 	}
 
 	var rankings = make([]ServiceRank, 0)
@@ -202,7 +207,7 @@ func (h *DashboardHandler) GetServiceRanking(c *gin.Context) {
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
 
 	if err := h.db.Model(&models.Order{}).Table("orders").
-		Select("service_products.id as service_id, service_products.name as service_name, COUNT(orders.id) as order_count, COALESCE(SUM(orders.paid_amount), 0) as total_revenue").
+		Select("service_products.id as service_id, service_products.name as service_name, COUNT(orders.id) as order_count, COALESCE(SUM(orders.paid_amount), 0) as total_revenue, COALESCE(SUM(orders.cost_amount), 0) as total_cost").
 		Joins("JOIN appointments ON appointments.id = orders.appointment_id").
 		Joins("JOIN service_products ON service_products.id = appointments.service_id").
 		Where("orders.order_type = ? AND orders.created_at >= ?", "service", thirtyDaysAgo).
@@ -215,13 +220,23 @@ func (h *DashboardHandler) GetServiceRanking(c *gin.Context) {
 		return
 	}
 
+	for i := range rankings {
+		rankings[i].TotalCost = util.RoundMoney(rankings[i].TotalCost)
+		rankings[i].GrossProfit, _ = inventory.GrossMargin(rankings[i].TotalRevenue, rankings[i].TotalCost)
+	}
+
 	log.Printf("GetServiceRanking found %d items", len(rankings))
 	c.JSON(http.StatusOK, response.Success(rankings, ""))
 }
 
 // GetFissionRanking returns top members by invitation count
-// GET /api/fission/ranking
+// GET /api/fission/ranking?limit=10
 func (h *DashboardHandler) GetFissionRanking(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
 	type FissionRank struct {
 		ID              uint    `json:"id"`
 		Name            string  `json:"name"`
@@ -233,15 +248,14 @@ func (h *DashboardHandler) GetFissionRanking(c *gin.Context) {
 
 	var rankings = make([]FissionRank, 0)
 
-	// 统计每个会员邀请的人数和累计佣金
+	// 统计每个会员邀请的人数和累计佣金（分别用子查询汇总，避免两张表连接后行数相乘）
 	if err := h.db.Model(&models.Member{}).
-		Select("members.id, members.name, members.phone, members.level, COUNT(invitees.id) as invite_count, COALESCE(SUM(fission_logs.commission_amount), 0) as total_commission").
-		Joins("LEFT JOIN members AS invitees ON invitees.referrer_id = members.id").
-		Joins("LEFT JOIN fission_logs ON fission_logs.inviter_id = members.id").
-		Group("members.id, members.name, members.phone, members.level").
-		Having("COUNT(invitees.id) > 0").
+		Select("members.id, members.name, members.phone, members.level, "+
+			"(SELECT COUNT(*) FROM members AS invitees WHERE invitees.referrer_id = members.id AND invitees.deleted_at IS NULL) as invite_count, "+
+			"(SELECT COALESCE(SUM(fission_logs.commission_amount), 0) FROM fission_logs WHERE fission_logs.inviter_id = members.id AND fission_logs.status <> ? AND fission_logs.deleted_at IS NULL) as total_commission", "reversed").
+		Where("EXISTS (SELECT 1 FROM members AS invitees WHERE invitees.referrer_id = members.id AND invitees.deleted_at IS NULL)").
 		Order("invite_count DESC, total_commission DESC").
-		Limit(10).
+		Limit(limit).
 		Scan(&rankings).Error; err != nil {
 		log.Printf("GetFissionRanking error: %v", err)
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to get fission ranking", err.Error()))
@@ -334,6 +348,9 @@ func (h *DashboardHandler) GetMonthlyStats(c *gin.Context) {
 	}, ""))
 }
 
+// 销售成本取下单时记录的成本；引入成本核算前的历史订单按商品当前成本估算
+const costExpr = "COALESCE(orders.cost_amount, -inventory_logs.change_amount * physical_products.cost_price)"
+
 // GetProductSalesOverview returns product sales overview
 // GET /api/dashboard/product-sales
 func (h *DashboardHandler) GetProductSalesOverview(c *gin.Context) {
@@ -354,19 +371,29 @@ func (h *DashboardHandler) GetProductSalesOverview(c *gin.Context) {
 	type ProductSales struct {
 		ProductID    uint    `json:"product_id"`
 		ProductName  string  `json:"product_name"`
+		ParentID     *uint   `json:"parent_id,omitempty"`   // 所属父商品，独立商品为空
+		ParentName   string  `json:"parent_name,omitempty"` // 父商品名称
 		SalesCount   int64   `json:"sales_count"`
 		TotalRevenue float64 `json:"total_revenue"`
+		TotalCost    float64 `json:"total_cost"`   // 销售成本
+		GrossProfit  float64 `json:"gross_profit"` // 毛利
+		GrossMargin  float64 `json:"gross_margin"` // 毛利率（%）
+	}
+
+	salesQuery := func() *gorm.DB {
+		return h.db.Model(&models.Order{}).Table("orders").
+			Joins("JOIN inventory_logs ON inventory_logs.id = orders.inventory_log_id").
+			Joins("JOIN physical_products ON physical_products.id = inventory_logs.product_id").
+			Where("orders.order_type = ? AND orders.created_at >= ? AND orders.refunded_at IS NULL", "physical", startDate)
 	}
 
 	var topProducts = make([]ProductSales, 0)
 
 	// 统计热销商品（从 orders 表）
-	if err := h.db.Model(&models.Order{}).Table("orders").
-		Select("physical_products.id as product_id, physical_products.name as product_name, COUNT(orders.id) as sales_count, COALESCE(SUM(orders.paid_amount), 0) as total_revenue").
-		Joins("JOIN inventory_logs ON inventory_logs.id = orders.inventory_log_id").
-		Joins("JOIN physical_products ON physical_products.id = inventory_logs.product_id").
-		Where("orders.order_type = ? AND orders.created_at >= ?", "physical", startDate).
-		Group("physical_products.id, physical_products.name").
+	if err := salesQuery().
+		Joins("LEFT JOIN parent_products ON parent_products.id = physical_products.parent_id").
+		Select("physical_products.id as product_id, physical_products.name as product_name, physical_products.parent_id as parent_id, COALESCE(parent_products.name, '') as parent_name, COUNT(orders.id) as sales_count, COALESCE(SUM(orders.paid_amount), 0) as total_revenue, COALESCE(SUM(" + costExpr + "), 0) as total_cost").
+		Group("physical_products.id, physical_products.name, physical_products.parent_id, parent_products.name").
 		Order("sales_count DESC").
 		Limit(5).
 		Scan(&topProducts).Error; err != nil {
@@ -375,29 +402,59 @@ func (h *DashboardHandler) GetProductSalesOverview(c *gin.Context) {
 		return
 	}
 	log.Printf("GetProductSalesOverview found %d items", len(topProducts))
+	for i := range topProducts {
+		p := &topProducts[i]
+		p.TotalCost = util.RoundMoney(p.TotalCost)
+		p.GrossProfit, p.GrossMargin = inventory.GrossMargin(p.TotalRevenue, p.TotalCost)
+	}
 
-	// 统计总销售额和总销量（从 orders 表）
-	var totalRevenue float64
-	var totalSales int64
-	if err := h.db.Model(&models.Order{}).Table("orders").
-		Where("orders.order_type = ? AND orders.created_at >= ?", "physical", startDate).
-		Select("COALESCE(SUM(orders.paid_amount), 0)").
-		Scan(&totalRevenue).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to calculate total revenue", err.Error()))
+	// 按父商品汇总各规格的销售（仅统计有父商品的规格）
+	type ParentSales struct {
+		ParentID     uint    `json:"parent_id"`
+		ParentName   string  `json:"parent_name"`
+		SalesCount   int64   `json:"sales_count"`
+		TotalRevenue float64 `json:"total_revenue"`
+		TotalCost    float64 `json:"total_cost"`
+		GrossProfit  float64 `json:"gross_profit"`
+		GrossMargin  float64 `json:"gross_margin"`
+	}
+	var topParents = make([]ParentSales, 0)
+	if err := salesQuery().
+		Joins("JOIN parent_products ON parent_products.id = physical_products.parent_id").
+		Select("parent_products.id as parent_id, parent_products.name as parent_name, COUNT(orders.id) as sales_count, COALESCE(SUM(orders.paid_amount), 0) as total_revenue, COALESCE(SUM(" + costExpr + "), 0) as total_cost").
+		Group("parent_products.id, parent_products.name").
+		Order("sales_count DESC").
+		Limit(5).
+		Scan(&topParents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to get parent product sales", err.Error()))
 		return
 	}
+	for i := range topParents {
+		p := &topParents[i]
+		p.TotalRevenue = util.RoundMoney(p.TotalRevenue)
+		p.TotalCost = util.RoundMoney(p.TotalCost)
+		p.GrossProfit, p.GrossMargin = inventory.GrossMargin(p.TotalRevenue, p.TotalCost)
+	}
 
-	if err := h.db.Model(&models.Order{}).
-		Where("order_type = ? AND created_at >= ?", "physical", startDate).
-		Count(&totalSales).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count total sales", err.Error()))
+	// 统计总销售额、总销量和总销售成本（从 orders 表）
+	var totals struct {
+		TotalRevenue float64
+		TotalSales   int64
+		TotalCost    float64
+	}
+	if err := salesQuery().
+		Select("COALESCE(SUM(orders.paid_amount), 0) as total_revenue, COUNT(orders.id) as total_sales, COALESCE(SUM(" + costExpr + "), 0) as total_cost").
+		Scan(&totals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to calculate total revenue", err.Error()))
 		return
 	}
+	totalCost := util.RoundMoney(totals.TotalCost)
+	grossProfit, grossMargin := inventory.GrossMargin(totals.TotalRevenue, totalCost)
 
-	// 统计库存预警（库存低于10的商品数）
+	// 统计库存预警（库存不高于补货点的上架商品数）
 	var lowStockCount int64
 	if err := h.db.Model(&models.PhysicalProduct{}).
-		Where("stock < ? AND is_active = ?", 10, true).
+		Where("stock <= reorder_point AND is_active = ?", true).
 		Count(&lowStockCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count low stock", err.Error()))
 		return
@@ -405,8 +462,12 @@ func (h *DashboardHandler) GetProductSalesOverview(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response.Success(gin.H{
 		"topProducts":   topProducts,
-		"totalRevenue":  totalRevenue,
-		"totalSales":    totalSales,
+		"topParents":    topParents,
+		"totalRevenue":  totals.TotalRevenue,
+		"totalSales":    totals.TotalSales,
+		"totalCost":     totalCost,
+		"grossProfit":   grossProfit,
+		"grossMargin":   grossMargin,
 		"lowStockCount": lowStockCount,
 		"periodDays":    days,
 	}, ""))
@@ -418,6 +479,22 @@ func (h *DashboardHandler) GetMarketingMetrics(c *gin.Context) {
 	orderType := c.Query("order_type")
 	memberLevel := c.Query("member_level")
 
+	// 可选：按已保存分群筛选订单/预约所属会员
+	var segmentSQL string
+	var segmentArgs []interface{}
+	if segmentID := c.Query("segment_id"); segmentID != "" {
+		_, filter, err := loadSegmentFilter(h.db, segmentID)
+		if err != nil {
+			c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), "Failed to load segment", err.Error()))
+			return
+		}
+		segmentSQL, segmentArgs, err = segment.MemberIDSubquery(filter, time.Now())
+		if err != nil {
+			c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), "Failed to compile segment filter", err.Error()))
+			return
+		}
+	}
+
 	groupExpr := "DATE(orders.created_at)"
 	switch granularity {
 	case "week":
@@ -437,6 +514,9 @@ func (h *DashboardHandler) GetMarketingMetrics(c *gin.Context) {
 		if memberLevel != "" {
 			q = q.Joins("JOIN members ON members.id = orders.member_id").Where("members.level = ?", memberLevel)
 		}
+		if segmentSQL != "" {
+			q = q.Where("orders.member_id IN ("+segmentSQL+")", segmentArgs...)
+		}
 		if !start.IsZero() && !end.IsZero() {
 			startDate := start.Format("2006-01-02")
 			endDate := end.Add(-time.Nanosecond).Format("2006-01-02")
@@ -485,6 +565,9 @@ func (h *DashboardHandler) GetMarketingMetrics(c *gin.Context) {
 	if memberLevel != "" {
 		conversionDenominatorQuery = conversionDenominatorQuery.Joins("JOIN members ON members.id = appointments.member_id").Where("members.level = ?", memberLevel)
 	}
+	if segmentSQL != "" {
+		conversionDenominatorQuery = conversionDenominatorQuery.Where("appointments.member_id IN ("+segmentSQL+")", segmentArgs...)
+	}
 	if !start.IsZero() && !end.IsZero() {
 		startDate := start.Format("2006-01-02")
 		endDate := end.Add(-time.Nanosecond).Format("2006-01-02")
@@ -541,6 +624,7 @@ func (h *DashboardHandler) GetMarketingMetrics(c *gin.Context) {
 		"end":         end,
 		"orderType":   orderType,
 		"memberLevel": memberLevel,
+		"segmentId":   c.Query("segment_id"),
 		"summary":     summary,
 		"series":      series,
 	}, ""))
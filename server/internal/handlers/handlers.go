@@ -3,16 +3,24 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"server/internal/db"
+	"server/internal/health"
+	"server/internal/inventory"
+	"server/internal/membership"
 	"server/internal/models"
+	"server/internal/referral"
 	"server/internal/response"
 	"server/pkg/config"
 	"server/pkg/util"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -92,21 +100,74 @@ func GetDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, response.Success(stats, ""))
 }
 
-// ListAppointments 获取订单列表 (支持状态筛选)
+// ListAppointments 获取预约列表
+// 支持按状态、预约时间段、技师、会员、服务项目筛选，按会员姓名/手机号搜索，并分页返回
 func ListAppointments(c *gin.Context) {
 	var appointments []models.Appointment
 	query := db.DB.Model(&models.Appointment{})
 
 	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
+		query = query.Where("appointments.status = ?", status)
+	}
+	for _, param := range []string{"tech_id", "member_id", "service_id"} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid "+param, nil))
+				return
+			}
+			query = query.Where("appointments."+param+" = ?", uint(id))
+		}
+	}
+
+	// 按会员姓名或手机号模糊搜索
+	if keyword := strings.TrimSpace(c.Query("keyword")); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Joins("JOIN members ON members.id = appointments.member_id").
+			Where("members.name LIKE ? OR members.phone LIKE ?", like, like)
+	}
+
+	// 按预约开始时间筛选
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+	if !start.IsZero() {
+		query = query.Where("appointments.start_time >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("appointments.start_time < ?", end)
+	}
+
+	sortColumn := "appointments.created_at"
+	if c.Query("sort_by") == "start_time" {
+		sortColumn = "appointments.start_time"
+	}
+	sortDirection := "DESC"
+	if strings.EqualFold(c.Query("sort_order"), "asc") {
+		sortDirection = "ASC"
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count appointments", err.Error()))
+		return
 	}
 
+	offset := (page - 1) * pageSize
 	if err := query.
 		Preload("Member").
 		Preload("Technician").
 		Preload("ServiceProduct").
-		Limit(50).
-		Order("created_at DESC").
+		Order(sortColumn + " " + sortDirection).
+		Limit(pageSize).
+		Offset(offset).
 		Find(&appointments).Error; err != nil {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
 		return
@@ -151,7 +212,7 @@ func ListAppointments(c *gin.Context) {
 		CommissionTo     *models.Member `json:"commission_to,omitempty"`
 	}
 
-	var result []AppointmentWithCommission
+	result := make([]AppointmentWithCommission, 0, len(appointments))
 	for _, appt := range appointments {
 		item := AppointmentWithCommission{
 			Appointment:      appt,
@@ -171,37 +232,12 @@ func ListAppointments(c *gin.Context) {
 		result = append(result, item)
 	}
 
-	c.JSON(http.StatusOK, response.Success(result, ""))
-}
-
-// GetFissionRanking 获取分销排行榜
-func GetFissionRanking(c *gin.Context) {
-	type FissionRank struct {
-		ID              uint    `json:"id"`
-		Name            string  `json:"name"`
-		Phone           string  `json:"phone"`
-		Level           string  `json:"level"`
-		InviteCount     int64   `json:"inviteCount"`
-		TotalCommission float64 `json:"totalCommission"`
-	}
-
-	var rankings []FissionRank
-
-	// 统计每个会员邀请的人数和累计佣金
-	if err := db.DB.Model(&models.Member{}).
-		Select("members.id, members.name, members.phone, members.level, COUNT(invitees.id) as invite_count, COALESCE(SUM(fission_logs.commission_amount), 0) as total_commission").
-		Joins("LEFT JOIN members AS invitees ON invitees.referrer_id = members.id").
-		Joins("LEFT JOIN fission_logs ON fission_logs.inviter_id = members.id").
-		Group("members.id, members.name, members.phone, members.level").
-		Having("COUNT(invitees.id) > 0").
-		Order("invite_count DESC, total_commission DESC").
-		Limit(10).
-		Scan(&rankings).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to get fission ranking", err))
-		return
-	}
-
-	c.JSON(http.StatusOK, response.Success(rankings, ""))
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"appointments": result,
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+	}, ""))
 }
 
 // CreateAppointment 创建预约
@@ -239,16 +275,24 @@ func CreateAppointment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Member not found", nil))
 		return
 	}
+	if !member.IsActive {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Member is deactivated", nil))
+		return
+	}
+
+	// 健康禁忌仅提醒不拦截，由前台与会员确认后继续
+	intake, err := health.LatestIntake(db.DB, member.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load health intake", err.Error()))
+		return
+	}
+	healthWarnings := health.Warnings(intake, &service)
 
 	// Calculate Discount based on Member Level
-	discountRate := config.GlobalMemberDiscount.Basic
-	switch member.Level {
-	case "platinum":
-		discountRate = config.GlobalMemberDiscount.Platinum
-	case "gold":
-		discountRate = config.GlobalMemberDiscount.Gold
-	case "silver":
-		discountRate = config.GlobalMemberDiscount.Silver
+	discountRate, err := membership.ServiceDiscountRate(db.DB, member.Level)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load member tier", err.Error()))
+		return
 	}
 	// Use CalculateRate to handle multiplication and rounding
 	actualPrice := util.CalculateRate(service.Price, discountRate)
@@ -305,11 +349,15 @@ func CreateAppointment(c *gin.Context) {
 
 	// Reload to get associations
 	db.DB.Preload("Member").Preload("Technician").Preload("ServiceItem").First(&appointment, appointment.ID)
+	appointment.HealthWarnings = healthWarnings
 
 	msg := ""
 	if status == "waiting" {
 		msg = "Time slot conflict. Added to waitlist."
 	}
+	if len(healthWarnings) > 0 {
+		msg = strings.TrimSpace(msg + " Health contraindication warning: " + strings.Join(healthWarnings, "; "))
+	}
 
 	c.JSON(http.StatusOK, response.Success(appointment, msg))
 }
@@ -342,16 +390,20 @@ func CancelAppointment(c *gin.Context) {
 
 // checkWaitlist checks if any waiting appointments can be promoted
 func checkWaitlist(techID uint) {
+	database := db.DB
+	if database == nil {
+		return
+	}
 	var waitingList []models.Appointment
 	// Find all waiting appointments for this tech, ordered by creation time (FCFS)
-	db.DB.Where("tech_id = ? AND status = ?", techID, "waiting").
+	database.Where("tech_id = ? AND status = ?", techID, "waiting").
 		Order("created_at asc").
 		Find(&waitingList)
 
 	for _, waitAppt := range waitingList {
 		// Check if this slot is now free
 		var conflictCount int64
-		db.DB.Model(&models.Appointment{}).
+		database.Model(&models.Appointment{}).
 			Where("tech_id = ? AND status = 'pending' AND start_time < ? AND end_time > ?",
 				techID, waitAppt.EndTime, waitAppt.StartTime).
 			Count(&conflictCount)
@@ -359,7 +411,7 @@ func checkWaitlist(techID uint) {
 		if conflictCount == 0 {
 			// Promote to pending
 			waitAppt.Status = "pending"
-			db.DB.Save(&waitAppt)
+			database.Save(&waitAppt)
 		}
 	}
 }
@@ -380,9 +432,11 @@ func CompleteAppointment(c *gin.Context) {
 
 	// 解析支付请求参数
 	var req struct {
-		PaymentMethod string  `json:"payment_method"` // balance, cash, mixed
+		PaymentMethod string  `json:"payment_method"` // balance, cash, points, mixed
 		BalanceAmount float64 `json:"balance_amount"`
 		CashAmount    float64 `json:"cash_amount"`
+		PointsUsed    int     `json:"points_used" binding:"gte=0"` // 抵扣使用的积分
+		CouponID      *uint   `json:"coupon_id"`                   // 使用的优惠券（可选）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -390,8 +444,33 @@ func CompleteAppointment(c *gin.Context) {
 		return
 	}
 
+	// 优惠券先抵扣（按订单金额校验使用门槛），积分在剩余金额上按比例上限抵扣
+	var coupon *models.Coupon
+	var couponDeduction float64
+	if req.CouponID != nil {
+		var err error
+		coupon, couponDeduction, err = membership.CouponDiscount(db.DB, appt.MemberID, *req.CouponID, appt.ActualPrice, time.Now())
+		if err != nil {
+			if membership.IsCouponError(err) {
+				c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load coupon", err.Error()))
+			return
+		}
+	}
+
+	// 积分抵扣校验（余额与比例上限）
+	if err := membership.CheckRedeemable(&appt.Member, req.PointsUsed, appt.ActualPrice-couponDeduction); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	pointsDeduction := membership.PointsValue(req.PointsUsed)
+	// 会员实付金额（不含优惠券与积分抵扣部分），用于佣金、消费额与积分计算
+	paidAmount := util.RoundMoney(appt.ActualPrice - couponDeduction - pointsDeduction)
+
 	// 验证支付金额是否匹配订单金额 (允许0.01误差)
-	totalPaid := req.BalanceAmount + req.CashAmount
+	totalPaid := req.BalanceAmount + req.CashAmount + couponDeduction + pointsDeduction
 	if totalPaid < appt.ActualPrice-0.01 || totalPaid > appt.ActualPrice+0.01 {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, fmt.Sprintf("Payment amount mismatch: expected %.2f, got %.2f", appt.ActualPrice, totalPaid), nil))
 		return
@@ -402,23 +481,29 @@ func CompleteAppointment(c *gin.Context) {
 
 	member := appt.Member
 	inviterID := member.ReferrerID
-	commissionInCents := int64(0)
 
-	// 处理余额扣款
+	// 处理余额扣款：以数据库中的最新余额为条件原子扣减，只更新余额列，
+	// 避免整行保存覆盖积分、佣金余额等由其他流程并发修改的字段
 	if req.BalanceAmount > 0 {
-		// 重新查询会员以获取最新余额并加锁
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&member, member.ID).Error; err != nil {
+		result := tx.Model(&models.Member{}).
+			Where("id = ? AND balance >= ?", member.ID, req.BalanceAmount).
+			Update("balance", gorm.Expr("balance - ?", req.BalanceAmount))
+		if result.Error != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to lock member record", nil))
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member", nil))
 			return
 		}
-
-		if member.Balance < req.BalanceAmount {
+		if result.RowsAffected == 0 {
 			tx.Rollback()
 			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Insufficient balance", nil))
 			return
 		}
-		member.Balance -= req.BalanceAmount
+	}
+	// 重新读取会员，后续积分抵扣与等级评估基于事务内的最新数据
+	if err := tx.First(&member, member.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load member", nil))
+		return
 	}
 
 	// 1. Update Appointment Status and Payment Info
@@ -426,6 +511,7 @@ func CompleteAppointment(c *gin.Context) {
 	appt.PaymentMethod = req.PaymentMethod
 	appt.PaidBalance = req.BalanceAmount
 	appt.PaidCash = req.CashAmount
+	appt.PaidPoints = req.PointsUsed
 
 	if err := tx.Save(&appt).Error; err != nil {
 		tx.Rollback()
@@ -433,112 +519,90 @@ func CompleteAppointment(c *gin.Context) {
 		return
 	}
 
-	// 2. Update Member Consumption & Level (注意：member已经在上面可能被更新过余额)
-	member.YearlyTotalConsumption += appt.ActualPrice
-	member.Level = util.CalculateMemberLevel(member.YearlyTotalConsumption)
-
-	if err := tx.Save(&member).Error; err != nil {
+	var existingOrder models.Order
+	if err := tx.Where("appointment_id = ?", appt.ID).First(&existingOrder).Error; err != nil && err != gorm.ErrRecordNotFound {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member", nil))
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to check existing order", err.Error()))
 		return
 	}
+	order := existingOrder
+	materialShortfalls := make([]inventory.MaterialShortfall, 0)
+	if existingOrder.ID == 0 {
+		order = models.Order{
+			MemberID:        appt.MemberID,
+			InviterID:       inviterID,
+			PaidAmount:      paidAmount,
+			PointsDeduction: pointsDeduction,
+			CouponDeduction: couponDeduction,
+			OrderType:       "service",
+			AppointmentID:   &appt.ID,
+		}
+		if coupon != nil {
+			order.CouponID = &coupon.ID
+		}
 
-	// 3. Commission Logic
-	if member.ReferrerID != nil {
-		// 使用 utils.CalculateRate 计算佣金，自动处理精度
-		commissionAmount := util.CalculateRate(appt.ActualPrice, config.GlobalCommission.ReferralRate)
-
-		// 转换为分进行后续整数校验（为了保持原有逻辑的严格性）
-		commissionInCents = util.ToCents(commissionAmount)
-		priceInCents := util.ToCents(appt.ActualPrice)
-
-		// 精度校验: 确保佣金为非负数
-		if commissionInCents < 0 {
+		// 按服务物料清单扣减耗材库存，耗材成本计入服务订单成本
+		var operatorID uint
+		if id := currentOperatorID(c); id != nil {
+			operatorID = *id
+		}
+		var materialLogs []models.InventoryLog
+		var materialCost float64
+		var err error
+		materialLogs, materialShortfalls, materialCost, err = inventory.ConsumeServiceMaterials(tx, appt.ServiceID, appt.ID, operatorID, time.Now())
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Invalid commission amount: negative value", nil))
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to consume service materials", err.Error()))
 			return
 		}
-
-		// 精度校验: 确保佣金在合理范围内 (不超过订单金额)
-		if commissionInCents > priceInCents {
+		if len(materialLogs) > 0 {
+			order.CostAmount = &materialCost
+		}
+		if err := tx.Create(&order).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Invalid commission amount: exceeds order amount", nil))
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to create order", err.Error()))
 			return
 		}
 
-		// 更新推荐人余额 (转换回元)
-		var referrer models.Member
-		if err := tx.First(&referrer, *member.ReferrerID).Error; err == nil {
-			// 记录更新前的余额用于日志
-			oldBalance := referrer.Balance
-
-			// 更新余额 (整数分转换为元)
-			referrer.Balance += float64(commissionInCents) / 100
-
-			// 精度校验: 更新后的余额不能为负数
-			if referrer.Balance < 0 {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Invalid referrer balance: would become negative", nil))
-				return
-			}
-
-			// 精度校验: 余额变化应该在合理范围内
-			expectedChange := float64(commissionInCents) / 100
-			actualChange := referrer.Balance - oldBalance
-			if actualChange < expectedChange-0.01 || actualChange > expectedChange+0.01 {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Balance calculation error", nil))
-				return
-			}
-
-			if err := tx.Save(&referrer).Error; err != nil {
+		if _, _, err := membership.Redeem(tx, &member, req.PointsUsed, &order); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to redeem points", err.Error()))
+			return
+		}
+		if coupon != nil {
+			if err := membership.UseCoupon(tx, coupon, &order, time.Now()); err != nil {
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update referrer balance", nil))
+				c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to use coupon", err.Error()))
 				return
 			}
+		}
 
-			// 记录分销日志 (使用整数计算后的精确金额)
-			fissionLog := models.FissionLog{
-				InviterID:        referrer.ID,
-				InviteeID:        member.ID,
-				CommissionAmount: float64(commissionInCents) / 100,
-			}
-			if err := tx.Create(&fissionLog).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create fission log", nil))
-				return
-			}
+		// 2. 推荐佣金：由分佣引擎沿推荐链逐级结算
+		if _, err := referral.Settle(tx, &order, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to settle referral commission", err.Error()))
+			return
 		}
 	}
 
-	var existingOrder models.Order
-	if err := tx.Where("appointment_id = ?", appt.ID).First(&existingOrder).Error; err != nil && err != gorm.ErrRecordNotFound {
+	// 3. 订单结算后统一更新会员消费额与等级（结算时只升级，降级由定时任务处理）
+	if _, err := membership.ApplySettledOrder(tx, &order, time.Now()); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to check existing order", err.Error()))
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member level", err.Error()))
 		return
 	}
-	if existingOrder.ID == 0 {
-		order := models.Order{
-			MemberID:         appt.MemberID,
-			InviterID:        inviterID,
-			PaidAmount:       appt.ActualPrice,
-			CommissionAmount: float64(commissionInCents) / 100,
-			OrderType:        "service",
-			AppointmentID:    &appt.ID,
-		}
-		if err := tx.Create(&order).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to create order", err.Error()))
-			return
-		}
-	}
 
 	tx.Commit()
 
+	// 耗材账面库存不足不阻断结算，记录日志并在响应中提示，留待盘点核实
+	for _, s := range materialShortfalls {
+		log.Printf("appointment %d settled with material shortfall: %s required %d, deducted %d", appt.ID, s.ProductName, s.Required, s.Deducted)
+	}
+
 	// Trigger Waitlist Check for this technician
 	go checkWaitlist(appt.TechID)
 
-	c.JSON(http.StatusOK, response.Success(nil, "Appointment completed and settled"))
+	c.JSON(http.StatusOK, response.Success(gin.H{"material_shortfalls": materialShortfalls}, "Appointment completed and settled"))
 }
 
 // ListTechnicians 获取技师列表
@@ -851,6 +915,10 @@ func CreateServiceItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid service item data", nil))
 		return
 	}
+	if err := normalizeContraindications(&item); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
 
 	if err := db.DB.Create(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create service item", nil))
@@ -860,6 +928,12 @@ func CreateServiceItem(c *gin.Context) {
 	c.JSON(http.StatusOK, response.Success(item, "Service item created successfully"))
 }
 
+// UpdateServiceItemRequest 更新服务项目请求体；未传 contraindications 时保留原有禁忌
+type UpdateServiceItemRequest struct {
+	models.ServiceProduct
+	Contraindications *datatypes.JSON `json:"contraindications"`
+}
+
 // UpdateServiceItem 更新服务项目
 func UpdateServiceItem(c *gin.Context) {
 	id := c.Param("id")
@@ -869,7 +943,7 @@ func UpdateServiceItem(c *gin.Context) {
 		return
 	}
 
-	var req models.ServiceProduct
+	var req UpdateServiceItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
 		return
@@ -880,6 +954,13 @@ func UpdateServiceItem(c *gin.Context) {
 	item.Price = req.Price
 	item.IsActive = req.IsActive
 	item.ImageURL = req.ImageURL
+	if req.Contraindications != nil {
+		item.Contraindications = *req.Contraindications
+	}
+	if err := normalizeContraindications(&item); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
 
 	if err := db.DB.Save(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update service item", nil))
@@ -899,35 +980,3 @@ func DeleteServiceItem(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response.Success(nil, "Service item deleted successfully"))
 }
-
-// ListMembers 获取会员列表
-func ListMembers(c *gin.Context) {
-	var members []models.Member
-	if err := db.DB.Limit(20).Find(&members).Error; err != nil {
-		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
-		return
-	}
-
-	c.JSON(http.StatusOK, response.Success(members, ""))
-}
-
-// CreateMember 创建会员
-func CreateMember(c *gin.Context) {
-	var member models.Member
-	if err := c.ShouldBindJSON(&member); err != nil {
-		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
-		return
-	}
-
-	if member.Name == "" || member.Phone == "" {
-		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Name and Phone are required", nil))
-		return
-	}
-
-	if err := db.DB.Create(&member).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create member", nil))
-		return
-	}
-
-	c.JSON(http.StatusOK, response.Success(member, "Member created successfully"))
-}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"server/internal/db"
+	"server/internal/health"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// normalizeContraindications 校验服务禁忌代码并去重
+func normalizeContraindications(item *models.ServiceProduct) error {
+	var codes []string
+	if len(item.Contraindications) > 0 {
+		if err := json.Unmarshal(item.Contraindications, &codes); err != nil {
+			return err
+		}
+	}
+	normalized, err := health.NormalizeConditions(codes)
+	if err != nil {
+		return err
+	}
+	item.Contraindications = normalized
+	return nil
+}
+
+// ListHealthConditions 获取支持的健康状况代码（问卷选项与服务禁忌共用）
+func ListHealthConditions(c *gin.Context) {
+	c.JSON(http.StatusOK, response.Success(health.Conditions, ""))
+}
+
+// HealthIntakeRequest 提交健康问卷的请求体
+type HealthIntakeRequest struct {
+	Conditions  []string `json:"conditions"`
+	Allergies   string   `json:"allergies" binding:"max=255"`
+	Injuries    string   `json:"injuries" binding:"max=255"`
+	Medications string   `json:"medications" binding:"max=255"`
+	Notes       string   `json:"notes"`
+}
+
+// GetMemberHealthIntake 获取会员最新版本的健康问卷，未填写时 data 为 null
+func GetMemberHealthIntake(c *gin.Context) {
+	var member models.Member
+	if err := db.DB.First(&member, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	intake, err := health.LatestIntake(db.DB, member.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch health intake", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(intake, ""))
+}
+
+// ListMemberHealthIntakes 获取会员健康问卷的全部历史版本（新版本在前）
+func ListMemberHealthIntakes(c *gin.Context) {
+	intakes := make([]models.HealthIntake, 0)
+	if err := db.DB.Where("member_id = ?", c.Param("id")).Order("version DESC").Find(&intakes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch health intake history", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(intakes, ""))
+}
+
+// SaveMemberHealthIntake 提交健康问卷，每次提交生成一个新版本
+func SaveMemberHealthIntake(c *gin.Context) {
+	var req HealthIntakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	conditions, err := health.NormalizeConditions(req.Conditions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	intake := models.HealthIntake{
+		MemberID:    member.ID,
+		Conditions:  conditions,
+		Allergies:   strings.TrimSpace(req.Allergies),
+		Injuries:    strings.TrimSpace(req.Injuries),
+		Medications: strings.TrimSpace(req.Medications),
+		Notes:       req.Notes,
+		RecordedBy:  currentOperatorID(c),
+	}
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return health.SaveIntake(tx, &intake)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to save health intake", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(intake, "Health intake saved successfully"))
+}
+
+// TreatmentNoteRequest 技师提交护理记录的请求体
+type TreatmentNoteRequest struct {
+	TechID  uint   `json:"tech_id"` // 为空时取预约的服务技师
+	Content string `json:"content" binding:"required"`
+}
+
+// CreateTreatmentNote 为预约添加护理记录，记录人须为该预约的服务技师
+func CreateTreatmentNote(c *gin.Context) {
+	var req TreatmentNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var appt models.Appointment
+	if err := db.DB.First(&appt, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Appointment not found", nil))
+		return
+	}
+	if appt.Status == "cancelled" {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Cannot add notes to a cancelled appointment", nil))
+		return
+	}
+	if req.TechID != 0 && req.TechID != appt.TechID {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Only the appointment's technician can write treatment notes", nil))
+		return
+	}
+
+	note := models.TreatmentNote{
+		AppointmentID: appt.ID,
+		MemberID:      appt.MemberID,
+		TechID:        appt.TechID,
+		Content:       strings.TrimSpace(req.Content),
+		AuthorID:      currentOperatorID(c),
+	}
+	if note.Content == "" {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Content is required", nil))
+		return
+	}
+	if err := db.DB.Create(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create treatment note", err.Error()))
+		return
+	}
+
+	db.DB.Preload("Technician").First(&note, note.ID)
+	c.JSON(http.StatusOK, response.Success(note, "Treatment note created successfully"))
+}
+
+// ListAppointmentTreatmentNotes 获取单次预约的护理记录
+func ListAppointmentTreatmentNotes(c *gin.Context) {
+	notes := make([]models.TreatmentNote, 0)
+	if err := db.DB.Preload("Technician").Where("appointment_id = ?", c.Param("id")).
+		Order("created_at ASC").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch treatment notes", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(notes, ""))
+}
+
+// ListMemberTreatmentNotes 获取会员历次到店的护理记录（新记录在前），供技师服务前查看
+func ListMemberTreatmentNotes(c *gin.Context) {
+	notes := make([]models.TreatmentNote, 0)
+	if err := db.DB.Preload("Technician").Where("member_id = ?", c.Param("id")).
+		Order("created_at DESC").Limit(50).Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch treatment notes", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(notes, ""))
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+func TestHealthIntake_VersionsAndAppointmentWarning(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	member := models.Member{Name: "Alice", Phone: "13800000071", InvitationCode: "H0071", IsActive: true}
+	tech := models.Technician{Name: "Bob", Status: 0}
+	otherTech := models.Technician{Name: "Carl", Status: 0}
+	service := models.ServiceProduct{Name: "Hot stone", Duration: 60, Price: 200, Contraindications: datatypes.JSON(`["pregnancy","hypertension"]`)}
+	testDB.Create(&member)
+	testDB.Create(&tech)
+	testDB.Create(&otherTech)
+	testDB.Create(&service)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/members/:id/health-intake", SaveMemberHealthIntake)
+	router.GET("/api/members/:id/health-intake/history", ListMemberHealthIntakes)
+	router.POST("/api/appointments", CreateAppointment)
+	router.POST("/api/appointments/:id/notes", CreateTreatmentNote)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	intakePath := "/api/members/" + strconvUint(member.ID) + "/health-intake"
+	if w := send("PUT", intakePath, gin.H{"conditions": []string{"unknown"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown condition, got %d", w.Code)
+	}
+	if w := send("PUT", intakePath, gin.H{"conditions": []string{"allergy"}, "allergies": "精油"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	if w := send("PUT", intakePath, gin.H{"conditions": []string{"allergy", "pregnancy"}, "allergies": "精油"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var versions []models.HealthIntake
+	testDB.Where("member_id = ?", member.ID).Order("version ASC").Find(&versions)
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Fatalf("expected two intake versions, got %+v", versions)
+	}
+
+	w := send("POST", "/api/appointments", gin.H{
+		"member_id":  member.ID,
+		"tech_id":    tech.ID,
+		"service_id": service.ID,
+		"start_time": time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected appointment to be created despite warning, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data models.Appointment `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if len(created.Data.HealthWarnings) != 1 {
+		t.Fatalf("expected one pregnancy warning, got %v", created.Data.HealthWarnings)
+	}
+
+	notesPath := "/api/appointments/" + strconvUint(created.Data.ID) + "/notes"
+	if w := send("POST", notesPath, gin.H{"tech_id": otherTech.ID, "content": "力度适中"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for another technician, got %d", w.Code)
+	}
+	if w := send("POST", notesPath, gin.H{"content": "避开腹部，力度适中"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var note models.TreatmentNote
+	if err := testDB.First(&note).Error; err != nil || note.TechID != tech.ID || note.MemberID != member.ID {
+		t.Fatalf("unexpected treatment note: %+v, err=%v", note, err)
+	}
+}
+
+func TestUpdateServiceItem_KeepsContraindicationsWhenOmitted(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	service := models.ServiceProduct{Name: "Hot stone", Duration: 60, Price: 200, IsActive: true, Contraindications: datatypes.JSON(`["pregnancy"]`)}
+	testDB.Create(&service)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/services/:id", UpdateServiceItem)
+	send := func(body gin.H) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("PUT", "/api/services/"+strconvUint(service.ID), bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 服务管理页面编辑时不带 contraindications，禁忌应保持不变
+	if w := send(gin.H{"name": "Hot stone deluxe", "duration": 90, "price": 260, "is_active": true}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var saved models.ServiceProduct
+	testDB.First(&saved, service.ID)
+	if saved.Name != "Hot stone deluxe" || string(saved.Contraindications) != `["pregnancy"]` {
+		t.Fatalf("expected contraindications kept after edit, got name=%s contraindications=%s", saved.Name, saved.Contraindications)
+	}
+
+	if w := send(gin.H{"name": "Hot stone deluxe", "duration": 90, "price": 260, "is_active": true, "contraindications": []string{}}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	testDB.First(&saved, service.ID)
+	if string(saved.Contraindications) != `[]` {
+		t.Fatalf("expected contraindications cleared when sent empty, got %s", saved.Contraindications)
+	}
+}
@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/membership"
 	"server/internal/models"
+	"server/internal/referral"
 	"server/internal/response"
-	"server/pkg/config"
 	"server/pkg/util"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // InventoryChangeRequest represents the request body for inventory changes
 type InventoryChangeRequest struct {
-	ProductID    uint   `json:"product_id" binding:"required"`
-	ChangeAmount int    `json:"change_amount" binding:"required"`
-	ActionType   string `json:"action_type" binding:"required,oneof=restock sale adjustment"`
-	MemberID     *uint  `json:"member_id"` // 购买者ID（销售时可选）
-	Remark       string `json:"remark"`
+	ProductID    uint     `json:"product_id" binding:"required"`
+	ChangeAmount int      `json:"change_amount" binding:"required"`
+	ActionType   string   `json:"action_type" binding:"required,oneof=restock sale adjustment"`
+	MemberID     *uint    `json:"member_id"`                                           // 购买者ID（销售时可选）
+	PointsUsed   int      `json:"points_used" binding:"gte=0"`                         // 销售时使用的抵扣积分
+	CouponID     *uint    `json:"coupon_id"`                                           // 销售时使用的优惠券（可选）
+	UnitCost     *float64 `json:"unit_cost" binding:"omitempty,gte=0"`                 // 入库单价（到货时可选，用于更新移动加权平均成本）
+	BatchNo      string   `json:"batch_no" binding:"max=64"`                           // 到货批号（可选）
+	ExpiryDate   string   `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"` // 到货批次到期日 YYYY-MM-DD（可选）
+	Remark       string   `json:"remark"`
 }
 
 // ListInventoryLogs returns all inventory logs
@@ -183,6 +193,10 @@ func CreateInventoryChange(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Sale requires member_id to create order", nil))
 		return
 	}
+	if req.ActionType != "sale" && req.PointsUsed > 0 {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "points_used is only allowed for sales", nil))
+		return
+	}
 
 	// Use transaction to ensure atomicity
 	tx := database.Begin()
@@ -192,31 +206,80 @@ func CreateInventoryChange(c *gin.Context) {
 		}
 	}()
 
-	// Get product and lock for update
+	// 商品信息仅用于计价；库存以下方的原子条件更新为准
 	var product models.PhysicalProduct
-	if err := tx.Clauses().First(&product, req.ProductID).Error; err != nil {
+	if err := tx.First(&product, req.ProductID).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Product not found", nil))
 		return
 	}
 
-	// Check if stock is sufficient for sale or negative adjustment
-	if req.ChangeAmount < 0 && product.Stock+req.ChangeAmount < 0 {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Insufficient stock", gin.H{
-			"current_stock":    product.Stock,
-			"requested_change": req.ChangeAmount,
-		}))
-		return
+	// 销售时加载购买会员，按会员等级折扣计算销售金额
+	var member models.Member
+	var calculatedSaleAmount float64
+	var coupon *models.Coupon
+	var couponDeduction float64
+	if req.ActionType == "sale" {
+		if err := tx.First(&member, *req.MemberID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+			return
+		}
+		if !member.IsActive {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Member is deactivated", nil))
+			return
+		}
+		// 出库时 ChangeAmount 为负数
+		originAmount := float64(-req.ChangeAmount) * product.RetailPrice
+		discountRate, err := membership.ProductDiscountRate(tx, member.Level)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load member tier", err.Error()))
+			return
+		}
+		calculatedSaleAmount = util.CalculateRate(originAmount, discountRate)
+
+		// 优惠券按折后金额校验使用门槛，积分在券后金额上按比例上限抵扣
+		if req.CouponID != nil {
+			coupon, couponDeduction, err = membership.CouponDiscount(tx, member.ID, *req.CouponID, calculatedSaleAmount, time.Now())
+			if err != nil {
+				tx.Rollback()
+				if membership.IsCouponError(err) {
+					c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+					return
+				}
+				c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load coupon", err.Error()))
+				return
+			}
+		}
+		if err := membership.CheckRedeemable(&member, req.PointsUsed, calculatedSaleAmount-couponDeduction); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+			return
+		}
 	}
+	// 会员实付金额 = 折后金额 - 优惠券抵扣金额 - 积分抵扣金额
+	pointsDeduction := membership.PointsValue(req.PointsUsed)
+	paidAmount := util.RoundMoney(calculatedSaleAmount - couponDeduction - pointsDeduction)
 
-	beforeStock := product.Stock
-	afterStock := beforeStock + req.ChangeAmount
-
-	// Update product stock
-	product.Stock = afterStock
-	if err := tx.Save(&product).Error; err != nil {
+	// 原子扣减/增加库存，并发销售时库存不会为负；带单价到货时同时更新移动加权平均成本
+	var change inventory.StockChange
+	var err error
+	if req.ActionType == "restock" {
+		change, err = inventory.Restock(tx, product.ID, req.ChangeAmount, req.UnitCost)
+	} else {
+		change, err = inventory.Adjust(tx, product.ID, req.ChangeAmount)
+	}
+	if err != nil {
 		tx.Rollback()
+		if errors.Is(err, inventory.ErrInsufficientStock) {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Insufficient stock", gin.H{
+				"current_stock":    change.Before,
+				"requested_change": req.ChangeAmount,
+			}))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update product stock", nil))
 		return
 	}
@@ -228,10 +291,21 @@ func CreateInventoryChange(c *gin.Context) {
 		MemberID:     req.MemberID,
 		ChangeAmount: req.ChangeAmount,
 		ActionType:   req.ActionType,
-		BeforeStock:  beforeStock,
-		AfterStock:   afterStock,
+		BeforeStock:  change.Before,
+		AfterStock:   change.After,
 		Remark:       req.Remark,
 	}
+	// 销售出库按出库时的移动加权平均成本记录销售成本
+	var costAmount float64
+	switch req.ActionType {
+	case "sale":
+		costAmount = inventory.CostOfGoods(-req.ChangeAmount, change.UnitCost)
+		inventoryLog.SaleAmount = &calculatedSaleAmount
+		inventoryLog.UnitCost = &change.UnitCost
+		inventoryLog.CostAmount = &costAmount
+	case "restock":
+		inventoryLog.UnitCost = req.UnitCost
+	}
 
 	if err := tx.Create(&inventoryLog).Error; err != nil {
 		tx.Rollback()
@@ -239,63 +313,80 @@ func CreateInventoryChange(c *gin.Context) {
 		return
 	}
 
-	// Fission commission logic for product sales with member
-	// 自动计算销售金额：出库数量 * 零售价（出库时 ChangeAmount 为负数）
-	var calculatedSaleAmount float64
-	if req.ActionType == "sale" && req.ChangeAmount < 0 {
-		calculatedSaleAmount = float64(-req.ChangeAmount) * product.RetailPrice
+	// 到货登记为新批次；出库（销售、负数纠错）按先到期先出扣减批次
+	if req.ActionType == "restock" {
+		expiry, err := inventory.ParseExpiryDate(req.ExpiryDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid expiry date, expected YYYY-MM-DD", nil))
+			return
+		}
+		if _, err := inventory.CreateBatch(tx, &inventoryLog, inventory.BatchInput{BatchNo: req.BatchNo, ExpiryDate: expiry}, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create batch", err.Error()))
+			return
+		}
+	} else if req.ChangeAmount < 0 {
+		if _, err := inventory.ConsumeFEFO(tx, product.ID, -req.ChangeAmount, inventoryLog.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to consume batches", err.Error()))
+			return
+		}
 	}
 
-	var inviterID *uint
-	commissionInCents := int64(0)
-
-	if req.ActionType == "sale" && req.MemberID != nil && calculatedSaleAmount > 0 {
-		var member models.Member
-		if err := tx.First(&member, *req.MemberID).Error; err == nil && member.ReferrerID != nil {
-			inviterID = member.ReferrerID
-			commissionAmount := util.CalculateRate(calculatedSaleAmount, config.GlobalCommission.ReferralRate)
-			commissionInCents = util.ToCents(commissionAmount)
-			saleAmountInCents := util.ToCents(calculatedSaleAmount)
-
-			if commissionInCents >= 0 && commissionInCents <= saleAmountInCents {
-				var referrer models.Member
-				if err := tx.First(&referrer, *member.ReferrerID).Error; err == nil {
-					oldBalance := referrer.Balance
-					referrer.Balance += float64(commissionInCents) / 100
-
-					if referrer.Balance >= 0 {
-						expectedChange := float64(commissionInCents) / 100
-						actualChange := referrer.Balance - oldBalance
-						if actualChange >= expectedChange-0.01 && actualChange <= expectedChange+0.01 {
-							if err := tx.Save(&referrer).Error; err == nil {
-								fissionLog := models.FissionLog{
-									InviterID:        referrer.ID,
-									InviteeID:        member.ID,
-									CommissionAmount: float64(commissionInCents) / 100,
-								}
-								tx.Create(&fissionLog)
-							}
-						}
-					}
-				}
-			}
-		}
+	// 出库跌破补货点时生成补货提醒，到货回到补货点以上时关闭提醒
+	if _, err := inventory.CheckReorderPoint(tx, product.ID, change, &inventoryLog.ID, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update stock alerts", err.Error()))
+		return
 	}
 
 	if req.ActionType == "sale" && req.MemberID != nil && calculatedSaleAmount > 0 {
 		order := models.Order{
-			MemberID:         *req.MemberID,
-			InviterID:        inviterID,
-			PaidAmount:       calculatedSaleAmount,
-			CommissionAmount: float64(commissionInCents) / 100,
-			OrderType:        "physical",
-			InventoryLogID:   &inventoryLog.ID,
+			MemberID:        *req.MemberID,
+			InviterID:       member.ReferrerID,
+			PaidAmount:      paidAmount,
+			PointsDeduction: pointsDeduction,
+			CouponDeduction: couponDeduction,
+			CostAmount:      &costAmount,
+			OrderType:       "physical",
+			InventoryLogID:  &inventoryLog.ID,
+		}
+		if coupon != nil {
+			order.CouponID = &coupon.ID
 		}
 		if err := tx.Create(&order).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to create order", err.Error()))
 			return
 		}
+
+		if _, _, err := membership.Redeem(tx, &member, req.PointsUsed, &order); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to redeem points", err.Error()))
+			return
+		}
+		if coupon != nil {
+			if err := membership.UseCoupon(tx, coupon, &order, time.Now()); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to use coupon", err.Error()))
+				return
+			}
+		}
+
+		// 推荐佣金与服务订单共用同一分佣引擎
+		if _, err := referral.Settle(tx, &order, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to settle referral commission", err.Error()))
+			return
+		}
+
+		// 商品订单与服务订单走同一套会员消费额与等级更新逻辑
+		if _, err := membership.ApplySettledOrder(tx, &order, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member level", err.Error()))
+			return
+		}
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -312,10 +403,13 @@ func CreateInventoryChange(c *gin.Context) {
 // BatchRestockRequest represents the request body for batch restocking
 type BatchRestockRequest struct {
 	Items []struct {
-		ProductID uint   `json:"product_id" binding:"required"`
-		Quantity  int    `json:"quantity" binding:"required,min=1"`
-		Remark    string `json:"remark"`
-	} `json:"items" binding:"required,min=1"`
+		ProductID  uint     `json:"product_id" binding:"required"`
+		Quantity   int      `json:"quantity" binding:"required,min=1"`
+		UnitCost   *float64 `json:"unit_cost" binding:"omitempty,gte=0"`                 // 入库单价（可选）
+		BatchNo    string   `json:"batch_no" binding:"max=64"`                           // 批号（可选）
+		ExpiryDate string   `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"` // 到期日 YYYY-MM-DD（可选）
+		Remark     string   `json:"remark"`
+	} `json:"items" binding:"required,min=1,dive"`
 }
 
 // BatchRestock creates multiple restock records at once
@@ -343,21 +437,13 @@ func BatchRestock(c *gin.Context) {
 	var createdLogs []models.InventoryLog
 
 	for _, item := range req.Items {
-		// Get product
-		var product models.PhysicalProduct
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Product not found: ID "+strconv.Itoa(int(item.ProductID)), nil))
-			return
-		}
-
-		beforeStock := product.Stock
-		afterStock := beforeStock + item.Quantity
-
-		// Update stock
-		product.Stock = afterStock
-		if err := tx.Save(&product).Error; err != nil {
+		change, err := inventory.Restock(tx, item.ProductID, item.Quantity, item.UnitCost)
+		if err != nil {
 			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Product not found: ID "+strconv.Itoa(int(item.ProductID)), nil))
+				return
+			}
 			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update product stock", nil))
 			return
 		}
@@ -368,8 +454,9 @@ func BatchRestock(c *gin.Context) {
 			OperatorID:   userID.(uint),
 			ChangeAmount: item.Quantity,
 			ActionType:   "restock",
-			BeforeStock:  beforeStock,
-			AfterStock:   afterStock,
+			BeforeStock:  change.Before,
+			AfterStock:   change.After,
+			UnitCost:     item.UnitCost,
 			Remark:       item.Remark,
 		}
 		if err := tx.Create(&log).Error; err != nil {
@@ -378,6 +465,23 @@ func BatchRestock(c *gin.Context) {
 			return
 		}
 
+		expiry, err := inventory.ParseExpiryDate(item.ExpiryDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid expiry date, expected YYYY-MM-DD", nil))
+			return
+		}
+		if _, err := inventory.CreateBatch(tx, &log, inventory.BatchInput{BatchNo: item.BatchNo, ExpiryDate: expiry}, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create batch", err.Error()))
+			return
+		}
+		if _, err := inventory.CheckReorderPoint(tx, item.ProductID, change, &log.ID, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update stock alerts", err.Error()))
+			return
+		}
+
 		createdLogs = append(createdLogs, log)
 	}
 
@@ -401,6 +505,7 @@ func GetInventoryStats(c *gin.Context) {
 		RestockCount      int64 `json:"restock_count"`
 		SaleCount         int64 `json:"sale_count"`
 		AdjustmentCount   int64 `json:"adjustment_count"`
+		ConsumptionCount  int64 `json:"consumption_count"` // 服务耗材扣减次数
 	}
 
 	// Total transactions
@@ -410,6 +515,7 @@ func GetInventoryStats(c *gin.Context) {
 	database.Model(&models.InventoryLog{}).Where("action_type = ?", "restock").Count(&stats.RestockCount)
 	database.Model(&models.InventoryLog{}).Where("action_type = ?", "sale").Count(&stats.SaleCount)
 	database.Model(&models.InventoryLog{}).Where("action_type = ?", "adjustment").Count(&stats.AdjustmentCount)
+	database.Model(&models.InventoryLog{}).Where("action_type = ?", inventory.ActionConsumption).Count(&stats.ConsumptionCount)
 
 	c.JSON(http.StatusOK, response.Success(stats, ""))
 }
@@ -66,3 +66,66 @@ func TestCreateInventoryChange_SaleCreatesPhysicalOrder(t *testing.T) {
 		t.Fatalf("expected member_id %d, got %d", member.ID, order.MemberID)
 	}
 }
+
+func TestCreateInventoryChange_SaleAppliesDiscountAndUpgradesLevel(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op4", PasswordHash: "x", Role: "operator", IsActive: true}
+	member := models.Member{Name: "Bob", Phone: "10000000022", InvitationCode: "code-10000000022", Level: "silver", IsActive: true}
+	product := models.PhysicalProduct{Name: "Serum", Stock: 10, RetailPrice: 3000, CostPrice: 1000, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&member)
+	testDB.Create(&product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/inventory/change", func(c *gin.Context) {
+		c.Set("user_id", operator.ID)
+		CreateInventoryChange(c)
+	})
+
+	reqBody, _ := json.Marshal(gin.H{
+		"product_id":    product.ID,
+		"change_amount": -2,
+		"action_type":   "sale",
+		"member_id":     member.ID,
+	})
+	req, _ := http.NewRequest("POST", "/api/inventory/change", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	// 银卡 95 折：2 * 3000 * 0.95 = 5700
+	var log models.InventoryLog
+	testDB.First(&log)
+	if log.SaleAmount == nil || *log.SaleAmount != 5700 {
+		t.Fatalf("expected sale_amount 5700, got %v", log.SaleAmount)
+	}
+	var order models.Order
+	testDB.First(&order)
+	if order.PaidAmount != 5700 {
+		t.Fatalf("expected paid_amount 5700, got %v", order.PaidAmount)
+	}
+
+	var updated models.Member
+	testDB.First(&updated, member.ID)
+	if updated.YearlyTotalConsumption != 5700 {
+		t.Fatalf("expected yearly consumption 5700, got %v", updated.YearlyTotalConsumption)
+	}
+	if updated.Level != "gold" {
+		t.Fatalf("expected level gold, got %s", updated.Level)
+	}
+
+	var changes int64
+	testDB.Model(&models.MemberLevelChange{}).Where("member_id = ? AND to_level = ?", member.ID, "gold").Count(&changes)
+	if changes != 1 {
+		t.Fatalf("expected 1 level change record, got %d", changes)
+	}
+}
@@ -0,0 +1,889 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/db"
+	"server/internal/health"
+	"server/internal/membership"
+	"server/internal/models"
+	"server/internal/referral"
+	"server/internal/response"
+	"server/internal/segment"
+	"server/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// memberSortColumns 会员列表允许的排序字段
+var memberSortColumns = map[string]string{
+	"created_at":               "members.created_at",
+	"balance":                  "members.balance",
+	"yearly_total_consumption": "members.yearly_total_consumption",
+}
+
+// ListMembers 获取会员列表
+// 支持按手机号/姓名/邀请码搜索，按等级、余额区间、标签与已保存分群筛选，并分页返回
+func ListMembers(c *gin.Context) {
+	query := db.DB.Model(&models.Member{})
+
+	if segmentID := c.Query("segment_id"); segmentID != "" {
+		_, filter, err := loadSegmentFilter(db.DB, segmentID)
+		if err != nil {
+			c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), "Failed to load segment", err.Error()))
+			return
+		}
+		sql, args, _ := segment.Compile(filter, time.Now())
+		query = query.Where(sql, args...)
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM member_tags mt JOIN tags t ON t.id = mt.tag_id WHERE mt.member_id = members.id AND t.name = ?)", tag)
+	}
+
+	if keyword := strings.TrimSpace(c.Query("keyword")); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("members.phone LIKE ? OR members.name LIKE ? OR members.invitation_code = ?", like, like, keyword)
+	}
+	if level := c.Query("level"); level != "" {
+		query = query.Where("members.level = ?", level)
+	}
+	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
+		query = query.Where("members.is_active = ?", isActiveStr == "true")
+	}
+	if minBalanceStr := c.Query("min_balance"); minBalanceStr != "" {
+		if minBalance, err := strconv.ParseFloat(minBalanceStr, 64); err == nil {
+			query = query.Where("members.balance >= ?", minBalance)
+		}
+	}
+	if maxBalanceStr := c.Query("max_balance"); maxBalanceStr != "" {
+		if maxBalance, err := strconv.ParseFloat(maxBalanceStr, 64); err == nil {
+			query = query.Where("members.balance <= ?", maxBalance)
+		}
+	}
+
+	sortColumn, ok := memberSortColumns[c.Query("sort_by")]
+	if !ok {
+		sortColumn = memberSortColumns["created_at"]
+	}
+	sortDirection := "DESC"
+	if strings.EqualFold(c.Query("sort_order"), "asc") {
+		sortDirection = "ASC"
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count members", err.Error()))
+		return
+	}
+
+	members := make([]models.Member, 0)
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Tags").Order(sortColumn + " " + sortDirection).Limit(pageSize).Offset(offset).Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch members", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"members":   members,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, ""))
+}
+
+// GetMember 获取会员详情
+// 汇总会员档案、余额、最近预约与订单、推荐人及其邀请的会员
+func GetMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+	memberID := uint(id)
+
+	var member models.Member
+	if err := db.DB.Preload("Tags").First(&member, memberID).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	var referrer *models.Member
+	if member.ReferrerID != nil {
+		var r models.Member
+		if err := db.DB.First(&r, *member.ReferrerID).Error; err == nil {
+			referrer = &r
+		}
+	}
+
+	invitees := make([]models.Member, 0)
+	if err := db.DB.Where("referrer_id = ?", memberID).Order("created_at DESC").Find(&invitees).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch invitees", err.Error()))
+		return
+	}
+
+	recentAppointments := make([]models.Appointment, 0)
+	if err := db.DB.Where("member_id = ?", memberID).
+		Preload("Technician").
+		Preload("ServiceProduct").
+		Order("start_time DESC").
+		Limit(10).
+		Find(&recentAppointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch appointments", err.Error()))
+		return
+	}
+
+	recentOrders := make([]models.Order, 0)
+	if err := db.DB.Where("member_id = ?", memberID).
+		Preload("Inviter").
+		Order("created_at DESC").
+		Limit(10).
+		Find(&recentOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch orders", err.Error()))
+		return
+	}
+
+	var stats struct {
+		OrderCount        int64   `json:"order_count"`
+		TotalSpent        float64 `json:"total_spent"`
+		TotalCommission   float64 `json:"total_commission"`   // 作为推荐人累计获得且已计入佣金钱包的佣金
+		PendingCommission float64 `json:"pending_commission"` // 持有期内尚未入账的佣金
+	}
+	if err := db.DB.Model(&models.Order{}).
+		Where("member_id = ?", memberID).
+		Select("COUNT(*) as order_count, COALESCE(SUM(paid_amount), 0) as total_spent").
+		Scan(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize orders", err.Error()))
+		return
+	}
+	if err := db.DB.Model(&models.FissionLog{}).
+		Where("inviter_id = ? AND status = ?", memberID, referral.CommissionPaid).
+		Select("COALESCE(SUM(commission_amount), 0)").
+		Scan(&stats.TotalCommission).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize commission", err.Error()))
+		return
+	}
+	if err := db.DB.Model(&models.FissionLog{}).
+		Where("inviter_id = ? AND status IN ?", memberID, []string{referral.CommissionPending, referral.CommissionConfirmed}).
+		Select("COALESCE(SUM(commission_amount), 0)").
+		Scan(&stats.PendingCommission).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize commission", err.Error()))
+		return
+	}
+
+	levelChanges := make([]models.MemberLevelChange, 0)
+	if err := db.DB.Where("member_id = ?", memberID).Order("created_at DESC").Limit(10).Find(&levelChanges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch level changes", err.Error()))
+		return
+	}
+
+	pointsTransactions := make([]models.PointsTransaction, 0)
+	if err := db.DB.Where("member_id = ?", memberID).Order("created_at DESC, id DESC").Limit(10).Find(&pointsTransactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch points history", err.Error()))
+		return
+	}
+
+	coupons := make([]models.Coupon, 0)
+	if err := db.DB.Where("member_id = ? AND used_at IS NULL AND expires_at > ?", memberID, time.Now()).
+		Order("expires_at ASC").
+		Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch coupons", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"member":              member,
+		"referrer":            referrer,
+		"invitees":            invitees,
+		"recent_appointments": recentAppointments,
+		"recent_orders":       recentOrders,
+		"level_changes":       levelChanges,
+		"points_transactions": pointsTransactions,
+		"coupons":             coupons,
+		"stats":               stats,
+	}, ""))
+}
+
+// CreateMemberRequest represents the request body for registering a member
+type CreateMemberRequest struct {
+	Name             string `json:"name"`
+	Phone            string `json:"phone"`
+	ReferrerCode     string `json:"referrer_code"` // 推荐人的邀请码（可选）
+	Birthday         string `json:"birthday"`      // 生日，格式 YYYY-MM-DD（可选）
+	Gender           string `json:"gender" binding:"omitempty,oneof=male female"`
+	PreferredContact string `json:"preferred_contact" binding:"omitempty,oneof=sms wechat phone"`
+	MarketingOptIn   *bool  `json:"marketing_opt_in"` // 默认接收营销通知
+}
+
+// parseBirthday 解析 YYYY-MM-DD 格式的生日，空字符串返回 nil
+func parseBirthday(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+var (
+	errReferrerNotFound = errors.New("referrer not found")
+	errReferrerInactive = errors.New("referrer is deactivated")
+	errSelfReferral     = errors.New("member cannot refer itself")
+	errReferralCycle    = errors.New("referral would create a cycle")
+)
+
+// resolveReferrer 根据邀请码查找推荐人，并校验自我推荐与推荐环
+// memberID 为待绑定的会员ID，新注册会员传 0
+func resolveReferrer(tx *gorm.DB, code string, memberID uint) (*models.Member, error) {
+	var referrer models.Member
+	if err := tx.Where("UPPER(invitation_code) = ?", strings.ToUpper(strings.TrimSpace(code))).First(&referrer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errReferrerNotFound
+		}
+		return nil, err
+	}
+	if !referrer.IsActive {
+		return nil, errReferrerInactive
+	}
+	if memberID == 0 {
+		return &referrer, nil
+	}
+	if referrer.ID == memberID {
+		return nil, errSelfReferral
+	}
+
+	// 沿推荐链向上查找，若遇到待绑定会员则说明会形成环
+	ancestors, err := referralAncestors(tx, &referrer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ancestors[memberID] {
+		return nil, errReferralCycle
+	}
+	return &referrer, nil
+}
+
+// referralAncestors 返回从 startID 起沿推荐链向上的全部会员ID（含 startID 本身）
+func referralAncestors(tx *gorm.DB, startID *uint) (map[uint]bool, error) {
+	visited := make(map[uint]bool)
+	for next := startID; next != nil && !visited[*next]; {
+		visited[*next] = true
+		var ancestor models.Member
+		if err := tx.Unscoped().Select("id", "referrer_id").First(&ancestor, *next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		next = ancestor.ReferrerID
+	}
+	return visited, nil
+}
+
+// referralErrorStatus 将推荐关系校验错误映射为 HTTP 状态码
+func referralErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errReferrerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errReferrerInactive), errors.Is(err, errSelfReferral), errors.Is(err, errReferralCycle):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// currentOperatorID 返回当前登录用户ID（未认证时为 nil）
+func currentOperatorID(c *gin.Context) *uint {
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// CreateMember 创建会员
+// 邀请码由服务端生成；如提供推荐人邀请码则同时绑定推荐关系
+func CreateMember(c *gin.Context) {
+	var req CreateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = strings.TrimSpace(req.Phone)
+	if req.Name == "" || req.Phone == "" {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Name and Phone are required", nil))
+		return
+	}
+	birthday, err := parseBirthday(req.Birthday)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid birthday, expected YYYY-MM-DD", nil))
+		return
+	}
+
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count int64
+	if err := tx.Unscoped().Model(&models.Member{}).Where("phone = ?", req.Phone).Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to validate phone", err.Error()))
+		return
+	}
+	if count > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Phone already used by another member", nil))
+		return
+	}
+
+	code, err := db.GenerateUniqueInvitationCode(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to generate invitation code", err.Error()))
+		return
+	}
+
+	member := models.Member{
+		Name:             req.Name,
+		Phone:            req.Phone,
+		InvitationCode:   code,
+		Birthday:         birthday,
+		Gender:           req.Gender,
+		PreferredContact: req.PreferredContact,
+		IsActive:         true,
+		MarketingOptIn:   req.MarketingOptIn == nil || *req.MarketingOptIn,
+	}
+
+	if req.ReferrerCode != "" {
+		referrer, err := resolveReferrer(tx, req.ReferrerCode, 0)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(referralErrorStatus(err), response.Error(referralErrorStatus(err), "Invalid referrer code: "+err.Error(), nil))
+			return
+		}
+		now := time.Now()
+		member.ReferrerID = &referrer.ID
+		member.ReferralBoundAt = &now
+		member.ReferralBoundBy = currentOperatorID(c)
+	}
+
+	// 带默认值的布尔字段为 false 时 Create 会忽略该列并回填默认值，需在创建后显式更新
+	optIn := member.MarketingOptIn
+	if err := tx.Create(&member).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create member", nil))
+		return
+	}
+	if !optIn {
+		if err := tx.Model(&member).Update("marketing_opt_in", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create member", nil))
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to commit transaction", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(member, "Member created successfully"))
+}
+
+// BindReferrerRequest represents the request body for binding a referrer afterwards
+type BindReferrerRequest struct {
+	ReferrerCode string `json:"referrer_code" binding:"required"`
+}
+
+// BindMemberReferrer 为尚未绑定推荐人的会员补绑推荐关系（仅允许绑定一次）
+func BindMemberReferrer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+
+	var req BindReferrerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var member models.Member
+	if err := tx.First(&member, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+	if member.ReferrerID != nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Referrer already bound", gin.H{"referrer_id": *member.ReferrerID}))
+		return
+	}
+
+	referrer, err := resolveReferrer(tx, req.ReferrerCode, member.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(referralErrorStatus(err), response.Error(referralErrorStatus(err), "Invalid referrer code: "+err.Error(), nil))
+		return
+	}
+
+	now := time.Now()
+	member.ReferrerID = &referrer.ID
+	member.ReferralBoundAt = &now
+	member.ReferralBoundBy = currentOperatorID(c)
+	if err := tx.Model(&member).Updates(map[string]interface{}{
+		"referrer_id":       member.ReferrerID,
+		"referral_bound_at": member.ReferralBoundAt,
+		"referral_bound_by": member.ReferralBoundBy,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to bind referrer", err.Error()))
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to commit transaction", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(member, "Referrer bound successfully"))
+}
+
+// UpdateMemberRequest represents the request body for updating a member
+type UpdateMemberRequest struct {
+	Name             string `json:"name"`
+	Phone            string `json:"phone"`
+	IsActive         *bool  `json:"is_active"`
+	Birthday         string `json:"birthday"` // 生日，格式 YYYY-MM-DD，留空表示不修改
+	Gender           string `json:"gender" binding:"omitempty,oneof=male female"`
+	PreferredContact string `json:"preferred_contact" binding:"omitempty,oneof=sms wechat phone"`
+	MarketingOptIn   *bool  `json:"marketing_opt_in"`
+}
+
+// UpdateMember 更新会员资料（手机号需保持唯一）
+func UpdateMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+	if member.MergedIntoID != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Member has been merged into another record", gin.H{"merged_into_id": *member.MergedIntoID}))
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		member.Name = name
+	}
+	if phone := strings.TrimSpace(req.Phone); phone != "" && phone != member.Phone {
+		// 唯一索引同样覆盖软删除的记录，因此使用 Unscoped 校验
+		var count int64
+		if err := db.DB.Unscoped().Model(&models.Member{}).
+			Where("phone = ? AND id <> ?", phone, member.ID).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to validate phone", err.Error()))
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Phone already used by another member", nil))
+			return
+		}
+		member.Phone = phone
+	}
+	if req.IsActive != nil {
+		// 停用/启用会员仅限店长，与停用接口的权限一致
+		if role, _ := c.Get("role"); role != "manager" {
+			c.JSON(http.StatusForbidden, response.Error(http.StatusForbidden, "Only managers can change member status", nil))
+			return
+		}
+		member.IsActive = *req.IsActive
+	}
+	if req.Birthday != "" {
+		birthday, err := parseBirthday(req.Birthday)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid birthday, expected YYYY-MM-DD", nil))
+			return
+		}
+		member.Birthday = birthday
+	}
+	if req.Gender != "" {
+		member.Gender = req.Gender
+	}
+	if req.PreferredContact != "" {
+		member.PreferredContact = req.PreferredContact
+	}
+	if req.MarketingOptIn != nil {
+		member.MarketingOptIn = *req.MarketingOptIn
+	}
+
+	if err := db.DB.Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(member, "Member updated successfully"))
+}
+
+// DeactivateMember 停用会员（软停用，保留预约、订单等历史数据）
+func DeactivateMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	if !member.IsActive {
+		c.JSON(http.StatusOK, response.Success(member, "Member already deactivated"))
+		return
+	}
+
+	if err := db.DB.Model(&member).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to deactivate member", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(member, "Member deactivated"))
+}
+
+// MergeMemberRequest represents the request body for merging a duplicate member
+type MergeMemberRequest struct {
+	DuplicateID uint `json:"duplicate_id" binding:"required"`
+}
+
+// MergeMember 将重复登记的会员合并到当前会员
+// 预约、订单、库存记录、分销记录、推荐关系、标签、健康问卷、服务记录与余额在同一事务内迁移，被合并的会员停用并记录去向
+func MergeMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+	survivorID := uint(id)
+
+	var req MergeMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+	if req.DuplicateID == survivorID {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Cannot merge a member into itself", nil))
+		return
+	}
+
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var survivor, duplicate models.Member
+	if err := tx.First(&survivor, survivorID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+	if err := tx.First(&duplicate, req.DuplicateID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Duplicate member not found", nil))
+		return
+	}
+	if survivor.MergedIntoID != nil || duplicate.MergedIntoID != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Member has already been merged", nil))
+		return
+	}
+
+	moves := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.Appointment{}, "member_id"},
+		{&models.Order{}, "member_id"},
+		{&models.Order{}, "inviter_id"},
+		{&models.InventoryLog{}, "member_id"},
+		{&models.FissionLog{}, "inviter_id"},
+		{&models.FissionLog{}, "invitee_id"},
+		{&models.PointsTransaction{}, "member_id"},
+		{&models.Coupon{}, "member_id"},
+		{&models.TreatmentNote{}, "member_id"},
+		{&models.CommissionWithdrawal{}, "member_id"},
+	}
+	for _, m := range moves {
+		if err := tx.Model(m.model).Where(m.column+" = ?", duplicate.ID).Update(m.column, survivor.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move "+m.column+" records", err.Error()))
+			return
+		}
+	}
+
+	// 标签关联去重后迁移：保留会员已有的标签直接删除重复会员的关联
+	if err := tx.Exec("DELETE FROM member_tags WHERE member_id = ? AND tag_id IN (SELECT tag_id FROM member_tags WHERE member_id = ?)",
+		duplicate.ID, survivor.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move member tags", err.Error()))
+		return
+	}
+	if err := tx.Exec("UPDATE member_tags SET member_id = ? WHERE member_id = ?", survivor.ID, duplicate.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move member tags", err.Error()))
+		return
+	}
+
+	// 健康问卷按时间合并到保留会员，确保预约时的禁忌提示覆盖双方记录
+	if err := health.MergeIntakes(tx, survivor.ID, duplicate.ID, currentOperatorID(c)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move health intakes", err.Error()))
+		return
+	}
+
+	// 重复会员邀请的会员改由保留会员作为推荐人（保留会员自身除外，避免自我推荐）；
+	// 其中处于保留会员上级链路中的会员改挂后会形成推荐环，改为解除其推荐关系
+	survivorAncestors, err := referralAncestors(tx, survivor.ReferrerID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load referral chain", err.Error()))
+		return
+	}
+	cyclic := make([]uint, 0)
+	for id := range survivorAncestors {
+		if id != duplicate.ID {
+			cyclic = append(cyclic, id)
+		}
+	}
+	if len(cyclic) > 0 {
+		if err := tx.Model(&models.Member{}).
+			Where("referrer_id = ? AND id IN ?", duplicate.ID, cyclic).
+			Update("referrer_id", nil).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move referral links", err.Error()))
+			return
+		}
+	}
+	if err := tx.Model(&models.Member{}).
+		Where("referrer_id = ? AND id <> ?", duplicate.ID, survivor.ID).
+		Update("referrer_id", survivor.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to move referral links", err.Error()))
+		return
+	}
+	if survivor.ReferrerID != nil && *survivor.ReferrerID == duplicate.ID {
+		survivor.ReferrerID = nil
+	}
+	// 保留会员沿用重复会员的推荐人前同样检查推荐环：推荐人的上级链路中不能出现合并双方
+	if survivor.ReferrerID == nil && duplicate.ReferrerID != nil {
+		ancestors, err := referralAncestors(tx, duplicate.ReferrerID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load referral chain", err.Error()))
+			return
+		}
+		if !ancestors[survivor.ID] && !ancestors[duplicate.ID] {
+			survivor.ReferrerID = duplicate.ReferrerID
+		}
+	}
+
+	survivor.Balance = util.RoundMoney(survivor.Balance + duplicate.Balance)
+	survivor.CommissionBalance = util.RoundMoney(survivor.CommissionBalance + duplicate.CommissionBalance)
+	survivor.Points += duplicate.Points
+	survivor.YearlyTotalConsumption = util.RoundMoney(survivor.YearlyTotalConsumption + duplicate.YearlyTotalConsumption)
+	level, err := membership.LevelFor(tx, survivor.YearlyTotalConsumption)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load member tiers", err.Error()))
+		return
+	}
+	fromLevel := survivor.Level
+	survivor.Level = level
+	if err := tx.Save(&survivor).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update surviving member", err.Error()))
+		return
+	}
+	if level != fromLevel {
+		change := models.MemberLevelChange{
+			MemberID: survivor.ID, FromLevel: fromLevel, ToLevel: level, Consumption: survivor.YearlyTotalConsumption,
+			Source: membership.SourceMerge, Reason: fmt.Sprintf("合并重复会员 #%d，消费额合计 %.2f 元", duplicate.ID, survivor.YearlyTotalConsumption),
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to record level change", err.Error()))
+			return
+		}
+	}
+
+	if err := tx.Model(&duplicate).Updates(map[string]interface{}{
+		"balance":                  0,
+		"commission_balance":       0,
+		"points":                   0,
+		"yearly_total_consumption": 0,
+		"referrer_id":              nil,
+		"is_active":                false,
+		"merged_into_id":           survivor.ID,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to deactivate duplicate member", err.Error()))
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to commit transaction", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(survivor, "Members merged successfully"))
+}
+
+// GetMemberLevelHistory 获取会员等级变动记录
+func GetMemberLevelHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	changes := make([]models.MemberLevelChange, 0)
+	if err := db.DB.Where("member_id = ?", member.ID).Order("created_at DESC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch level changes", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"level":                    member.Level,
+		"yearly_total_consumption": member.YearlyTotalConsumption,
+		"level_grace_until":        member.LevelGraceUntil,
+		"window_start":             membership.WindowStart(time.Now()),
+		"changes":                  changes,
+	}, ""))
+}
+
+// RecalculateMemberLevels 立即按统计窗口重算所有会员等级（与定时任务逻辑一致）
+func RecalculateMemberLevels(c *gin.Context) {
+	summary, err := membership.RecalculateAll(db.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to recalculate member levels", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(summary, "Member levels recalculated"))
+}
+
+// GetMemberPoints 获取会员积分余额与积分流水（分页）
+func GetMemberPoints(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid member id", nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	query := db.DB.Model(&models.PointsTransaction{}).Where("member_id = ?", member.ID)
+	if txType := c.Query("type"); txType != "" {
+		query = query.Where("type = ?", txType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count points history", err.Error()))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	transactions := make([]models.PointsTransaction, 0)
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch points history", err.Error()))
+		return
+	}
+
+	// 即将过期的积分：30 天内到期的剩余积分
+	now := time.Now()
+	var expiringSoon int64
+	if err := db.DB.Model(&models.PointsTransaction{}).
+		Where("member_id = ? AND remaining > 0 AND expires_at <= ?", member.ID, now.AddDate(0, 0, 30)).
+		Select("COALESCE(SUM(remaining), 0)").
+		Scan(&expiringSoon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize expiring points", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"points":        member.Points,
+		"points_value":  membership.PointsValue(member.Points),
+		"expiring_soon": expiringSoon,
+		"transactions":  transactions,
+		"total":         total,
+		"page":          page,
+		"page_size":     pageSize,
+	}, ""))
+}
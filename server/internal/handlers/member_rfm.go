@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rfmSortColumns 分群会员列表允许的排序字段
+var rfmSortColumns = map[string]string{
+	"monetary":        "member_rfms.monetary",
+	"days_since_last": "member_rfms.days_since_last",
+	"order_count":     "member_rfms.order_count",
+}
+
+// GetMemberSegments 获取各 RFM 分群及流失风险会员数量
+func GetMemberSegments(c *gin.Context) {
+	type segmentCount struct {
+		Segment string `json:"segment"`
+		Count   int64  `json:"count"`
+	}
+	var rows []segmentCount
+	if err := db.DB.Model(&models.MemberRFM{}).
+		Select("segment, COUNT(*) as count").
+		Group("segment").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count segments", err.Error()))
+		return
+	}
+	counts := make(map[string]int64, len(membership.Segments))
+	for _, s := range membership.Segments {
+		counts[s] = 0
+	}
+	for _, r := range rows {
+		counts[r.Segment] = r.Count
+	}
+
+	var churnRisk int64
+	if err := db.DB.Model(&models.MemberRFM{}).Where("churn_risk = ?", true).Count(&churnRisk).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count churn risk", err.Error()))
+		return
+	}
+
+	var calculatedAt *time.Time
+	var latest models.MemberRFM
+	if err := db.DB.Order("calculated_at DESC").First(&latest).Error; err == nil {
+		calculatedAt = &latest.CalculatedAt
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"segments":      counts,
+		"churn_risk":    churnRisk,
+		"calculated_at": calculatedAt,
+	}, ""))
+}
+
+// ListSegmentMembers 按分群或流失风险列出会员，用于定向召回
+func ListSegmentMembers(c *gin.Context) {
+	query := db.DB.Model(&models.MemberRFM{})
+
+	if segment := c.Query("segment"); segment != "" {
+		query = query.Where("member_rfms.segment = ?", segment)
+	}
+	if c.Query("churn_risk") == "true" {
+		query = query.Where("member_rfms.churn_risk = ?", true)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count members", err.Error()))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	sortColumn, ok := rfmSortColumns[c.Query("sort_by")]
+	if !ok {
+		sortColumn = rfmSortColumns["monetary"]
+	}
+	sortOrder := "DESC"
+	if c.Query("sort_order") == "asc" {
+		sortOrder = "ASC"
+	}
+
+	records := make([]models.MemberRFM, 0)
+	if err := query.Preload("Member").
+		Order(sortColumn + " " + sortOrder).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch members", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"members":   records,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, ""))
+}
+
+// RecalculateMemberSegments 立即重算所有会员的 RFM 分群（与定时任务逻辑一致）
+func RecalculateMemberSegments(c *gin.Context) {
+	summary, err := membership.RecalculateRFM(db.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to recalculate member segments", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(summary, "Member segments recalculated"))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemberSegments_CountsAndChurnList(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	now := time.Now()
+	members := []models.Member{
+		{Name: "A", Phone: "13800000051", InvitationCode: "A0051"},
+		{Name: "B", Phone: "13800000052", InvitationCode: "B0052"},
+	}
+	for i := range members {
+		testDB.Create(&members[i])
+	}
+	testDB.Create(&models.MemberRFM{MemberID: members[0].ID, Segment: "at_risk", ChurnRisk: true, Monetary: 800, CalculatedAt: now})
+	testDB.Create(&models.MemberRFM{MemberID: members[1].ID, Segment: "champion", Monetary: 9000, CalculatedAt: now})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/member-segments", GetMemberSegments)
+	router.GET("/api/member-segments/members", ListSegmentMembers)
+
+	req, _ := http.NewRequest("GET", "/api/member-segments", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var summary struct {
+		Data struct {
+			Segments  map[string]int64 `json:"segments"`
+			ChurnRisk int64            `json:"churn_risk"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	if summary.Data.Segments["at_risk"] != 1 || summary.Data.Segments["lost"] != 0 || summary.Data.ChurnRisk != 1 {
+		t.Fatalf("unexpected segment summary: %+v", summary.Data)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/member-segments/members?churn_risk=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var list struct {
+		Data struct {
+			Members []models.MemberRFM `json:"members"`
+			Total   int64              `json:"total"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Data.Total != 1 || len(list.Data.Members) != 1 || list.Data.Members[0].Member.Phone != "13800000051" {
+		t.Fatalf("expected the at-risk member with profile, got %+v", list.Data)
+	}
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+	"server/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+func TestListMembers_SearchFilterAndPagination(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	members := []models.Member{
+		{Name: "张三", Phone: "13800000001", Level: "gold", Balance: 500, InvitationCode: "ZS0001"},
+		{Name: "李四", Phone: "13800000002", Level: "gold", Balance: 50, InvitationCode: "LS0002"},
+		{Name: "王五", Phone: "13900000003", Level: "basic", Balance: 0, InvitationCode: "WW0003"},
+	}
+	for i := range members {
+		testDB.Create(&members[i])
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/members", ListMembers)
+
+	type listResult struct {
+		Data struct {
+			Members []models.Member `json:"members"`
+			Total   int64           `json:"total"`
+		} `json:"data"`
+	}
+	doGet := func(url string) listResult {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d, body=%s", url, w.Code, w.Body.String())
+		}
+		var res listResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return res
+	}
+
+	if res := doGet("/api/members?keyword=138"); res.Data.Total != 2 {
+		t.Fatalf("expected 2 members matching phone prefix, got %d", res.Data.Total)
+	}
+	if res := doGet("/api/members?keyword=WW0003"); res.Data.Total != 1 || res.Data.Members[0].Name != "王五" {
+		t.Fatalf("expected invitation code lookup to find 王五, got %+v", res.Data)
+	}
+	if res := doGet("/api/members?level=gold&min_balance=100"); res.Data.Total != 1 || res.Data.Members[0].Name != "张三" {
+		t.Fatalf("expected level+balance filter to find 张三, got %+v", res.Data)
+	}
+	res := doGet("/api/members?page=2&page_size=2&sort_by=balance&sort_order=asc")
+	if res.Data.Total != 3 || len(res.Data.Members) != 1 || res.Data.Members[0].Name != "张三" {
+		t.Fatalf("expected last page to hold the richest member, got %+v", res.Data)
+	}
+}
+
+func TestGetMember_Detail(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	referrer := models.Member{Name: "Ref", Phone: "10000000041", InvitationCode: "code-10000000041"}
+	testDB.Create(&referrer)
+	member := models.Member{Name: "Alice", Phone: "10000000042", InvitationCode: "code-10000000042", Balance: 88, ReferrerID: &referrer.ID}
+	testDB.Create(&member)
+	invitee := models.Member{Name: "Inv", Phone: "10000000043", InvitationCode: "code-10000000043", ReferrerID: &member.ID}
+	testDB.Create(&invitee)
+
+	tech := models.Technician{Name: "Bob", Status: 0}
+	service := models.ServiceProduct{Name: "Massage", Duration: 60, Price: 100}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	appt := models.Appointment{
+		MemberID:    member.ID,
+		TechID:      tech.ID,
+		ServiceID:   service.ID,
+		StartTime:   time.Now().Add(-2 * time.Hour),
+		EndTime:     time.Now().Add(-1 * time.Hour),
+		Status:      "completed",
+		OriginPrice: 100,
+		ActualPrice: 100,
+	}
+	testDB.Create(&appt)
+	testDB.Create(&models.Order{MemberID: member.ID, InviterID: &referrer.ID, PaidAmount: 100, CommissionAmount: 10, OrderType: "service", AppointmentID: &appt.ID})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/members/:id", GetMember)
+
+	req, _ := http.NewRequest("GET", "/api/members/"+strconvUint(member.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var res struct {
+		Data struct {
+			Member             models.Member        `json:"member"`
+			Referrer           *models.Member       `json:"referrer"`
+			Invitees           []models.Member      `json:"invitees"`
+			RecentAppointments []models.Appointment `json:"recent_appointments"`
+			RecentOrders       []models.Order       `json:"recent_orders"`
+			Stats              struct {
+				OrderCount int64   `json:"order_count"`
+				TotalSpent float64 `json:"total_spent"`
+			} `json:"stats"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if res.Data.Member.Balance != 88 {
+		t.Fatalf("expected balance 88, got %.2f", res.Data.Member.Balance)
+	}
+	if res.Data.Referrer == nil || res.Data.Referrer.ID != referrer.ID {
+		t.Fatalf("expected referrer %d, got %+v", referrer.ID, res.Data.Referrer)
+	}
+	if len(res.Data.Invitees) != 1 || res.Data.Invitees[0].ID != invitee.ID {
+		t.Fatalf("expected one invitee, got %+v", res.Data.Invitees)
+	}
+	if len(res.Data.RecentAppointments) != 1 || res.Data.RecentAppointments[0].ServiceProduct.Name != "Massage" {
+		t.Fatalf("expected one recent appointment with service, got %+v", res.Data.RecentAppointments)
+	}
+	if len(res.Data.RecentOrders) != 1 || res.Data.Stats.OrderCount != 1 || res.Data.Stats.TotalSpent != 100 {
+		t.Fatalf("unexpected order summary: %+v / %+v", res.Data.RecentOrders, res.Data.Stats)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/members/999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown member, got %d", w.Code)
+	}
+}
+
+func TestUpdateMember_RejectsDuplicatePhone(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	a := models.Member{Name: "A", Phone: "10000000051", InvitationCode: "code-10000000051"}
+	b := models.Member{Name: "B", Phone: "10000000052", InvitationCode: "code-10000000052"}
+	testDB.Create(&a)
+	testDB.Create(&b)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/members/:id", UpdateMember)
+
+	doPut := func(body gin.H) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("PUT", "/api/members/"+strconvUint(a.ID), bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := doPut(gin.H{"phone": b.Phone}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate phone, got %d, body=%s", w.Code, w.Body.String())
+	}
+	if w := doPut(gin.H{"name": "A2", "phone": "10000000053"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	// 停用/启用会员仅限店长
+	if w := doPut(gin.H{"is_active": false}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for operator changing member status, got %d", w.Code)
+	}
+
+	var reloaded models.Member
+	testDB.First(&reloaded, a.ID)
+	if reloaded.Name != "A2" || reloaded.Phone != "10000000053" || !reloaded.IsActive {
+		t.Fatalf("member not updated: %+v", reloaded)
+	}
+}
+
+func TestMergeMember_MovesRecordsAndBalance(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	referrer := models.Member{Name: "Ref", Phone: "10000000061", InvitationCode: "code-10000000061"}
+	testDB.Create(&referrer)
+	survivor := models.Member{Name: "Alice", Phone: "10000000062", InvitationCode: "code-10000000062", Balance: 100, YearlyTotalConsumption: 600}
+	testDB.Create(&survivor)
+	duplicate := models.Member{Name: "Alice", Phone: "10000000063", InvitationCode: "code-10000000063", Balance: 50, CommissionBalance: 20, ReferrerID: &referrer.ID, YearlyTotalConsumption: 600}
+	testDB.Create(&duplicate)
+	invitee := models.Member{Name: "Inv", Phone: "10000000064", InvitationCode: "code-10000000064", ReferrerID: &duplicate.ID}
+	testDB.Create(&invitee)
+
+	tech := models.Technician{Name: "Bob", Status: 0}
+	service := models.ServiceProduct{Name: "Massage", Duration: 60, Price: 100}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	appt := models.Appointment{
+		MemberID:    duplicate.ID,
+		TechID:      tech.ID,
+		ServiceID:   service.ID,
+		StartTime:   time.Now().Add(-2 * time.Hour),
+		EndTime:     time.Now().Add(-1 * time.Hour),
+		Status:      "completed",
+		OriginPrice: 100,
+		ActualPrice: 100,
+	}
+	testDB.Create(&appt)
+	testDB.Create(&models.Order{MemberID: duplicate.ID, PaidAmount: 100, OrderType: "service", AppointmentID: &appt.ID})
+	testDB.Create(&models.FissionLog{InviterID: duplicate.ID, InviteeID: invitee.ID, CommissionAmount: 5})
+	testDB.Create(&models.TreatmentNote{AppointmentID: appt.ID, MemberID: duplicate.ID, TechID: tech.ID, Content: "肩颈紧张"})
+	testDB.Create(&models.CommissionWithdrawal{MemberID: duplicate.ID, Amount: 10, Status: "pending", Method: "cash"})
+	olderIntake := models.HealthIntake{MemberID: survivor.ID, Version: 1, Conditions: datatypes.JSON(`["allergy"]`), Allergies: "花粉"}
+	olderIntake.CreatedAt = time.Now().AddDate(0, -2, 0)
+	testDB.Create(&olderIntake)
+	newerIntake := models.HealthIntake{MemberID: duplicate.ID, Version: 1, Conditions: datatypes.JSON(`["pregnancy"]`), Allergies: "花粉"}
+	newerIntake.CreatedAt = time.Now().AddDate(0, -1, 0)
+	testDB.Create(&newerIntake)
+	vip := models.Tag{Name: "VIP"}
+	sensitive := models.Tag{Name: "敏感肌"}
+	testDB.Create(&vip)
+	testDB.Create(&sensitive)
+	testDB.Model(&survivor).Association("Tags").Append(&vip)
+	testDB.Model(&duplicate).Association("Tags").Append(&vip, &sensitive)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/members/:id/merge", MergeMember)
+
+	payload, _ := json.Marshal(gin.H{"duplicate_id": duplicate.ID})
+	req, _ := http.NewRequest("POST", "/api/members/"+strconvUint(survivor.ID)+"/merge", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var s, d, i models.Member
+	testDB.First(&s, survivor.ID)
+	testDB.First(&d, duplicate.ID)
+	testDB.First(&i, invitee.ID)
+	if s.Balance != 150 {
+		t.Fatalf("expected merged balance 150, got %.2f", s.Balance)
+	}
+	if s.CommissionBalance != 20 {
+		t.Fatalf("expected merged commission balance 20, got %.2f", s.CommissionBalance)
+	}
+	if s.ReferrerID == nil || *s.ReferrerID != referrer.ID {
+		t.Fatalf("expected survivor to inherit referrer, got %v", s.ReferrerID)
+	}
+	if d.IsActive || d.Balance != 0 || d.CommissionBalance != 0 || d.MergedIntoID == nil || *d.MergedIntoID != survivor.ID {
+		t.Fatalf("duplicate not retired correctly: %+v", d)
+	}
+	if i.ReferrerID == nil || *i.ReferrerID != survivor.ID {
+		t.Fatalf("expected invitee to be re-linked to survivor, got %v", i.ReferrerID)
+	}
+	// 合并后消费额 1200 达到白银门槛，升级需写入变动记录
+	var change models.MemberLevelChange
+	if err := testDB.Where("member_id = ?", survivor.ID).First(&change).Error; err != nil {
+		t.Fatalf("expected level change record after merge: %v", err)
+	}
+	if s.Level != "silver" || change.FromLevel != "basic" || change.ToLevel != "silver" || change.Source != "merge" {
+		t.Fatalf("unexpected level change after merge: level=%s change=%+v", s.Level, change)
+	}
+
+	var count int64
+	testDB.Model(&models.Appointment{}).Where("member_id = ?", survivor.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected appointment moved to survivor, got %d", count)
+	}
+	testDB.Model(&models.Order{}).Where("member_id = ?", survivor.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected order moved to survivor, got %d", count)
+	}
+	testDB.Model(&models.FissionLog{}).Where("inviter_id = ?", survivor.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected fission log moved to survivor, got %d", count)
+	}
+	var tags []models.Tag
+	testDB.Model(&survivor).Association("Tags").Find(&tags)
+	if len(tags) != 2 {
+		t.Fatalf("expected survivor to hold both tags once, got %+v", tags)
+	}
+	testDB.Table("member_tags").Where("member_id = ?", duplicate.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no tag links left on duplicate, got %d", count)
+	}
+	testDB.Model(&models.TreatmentNote{}).Where("member_id = ?", survivor.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected treatment note moved to survivor, got %d", count)
+	}
+	testDB.Model(&models.CommissionWithdrawal{}).Where("member_id = ?", survivor.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected commission withdrawal moved to survivor, got %d", count)
+	}
+	// 双方问卷按时间重新编号，并生成合并双方状况的最新版本
+	var intakes []models.HealthIntake
+	testDB.Where("member_id = ?", survivor.ID).Order("version ASC").Find(&intakes)
+	if len(intakes) != 3 || intakes[0].ID != olderIntake.ID || intakes[1].ID != newerIntake.ID {
+		t.Fatalf("expected both intake histories on survivor in time order, got %+v", intakes)
+	}
+	if latest := intakes[2]; string(latest.Conditions) != `["allergy","pregnancy"]` || latest.Allergies != "花粉" {
+		t.Fatalf("expected merged latest intake, got conditions=%s allergies=%s", latest.Conditions, latest.Allergies)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/members/"+strconvUint(survivor.ID)+"/merge", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when merging an already merged member, got %d", w.Code)
+	}
+}
+
+func TestMergeMember_DoesNotCreateReferralCycle(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	// 推荐链 S → X → D：D 合并到 S 后 X 若改挂 S 将形成 S ↔ X 环
+	d := models.Member{Name: "D", Phone: "10000000065", InvitationCode: "code-10000000065", IsActive: true}
+	testDB.Create(&d)
+	x := models.Member{Name: "X", Phone: "10000000066", InvitationCode: "code-10000000066", IsActive: true, ReferrerID: &d.ID}
+	testDB.Create(&x)
+	s := models.Member{Name: "S", Phone: "10000000067", InvitationCode: "code-10000000067", IsActive: true, ReferrerID: &x.ID}
+	testDB.Create(&s)
+	// 另一组：P 由 Q 推荐、Q 由 R 推荐，R 是 P 的重复档案；P 无推荐人时不能沿用 R 的推荐人 Q
+	r := models.Member{Name: "R", Phone: "10000000068", InvitationCode: "code-10000000068", IsActive: true}
+	testDB.Create(&r)
+	q := models.Member{Name: "Q", Phone: "10000000069", InvitationCode: "code-10000000069", IsActive: true}
+	testDB.Create(&q)
+	p := models.Member{Name: "P", Phone: "10000000070", InvitationCode: "code-10000000070", IsActive: true}
+	testDB.Create(&p)
+	testDB.Model(&q).Update("referrer_id", p.ID)
+	testDB.Model(&r).Update("referrer_id", q.ID)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/members/:id/merge", MergeMember)
+	merge := func(survivorID, duplicateID uint) {
+		payload, _ := json.Marshal(gin.H{"duplicate_id": duplicateID})
+		req, _ := http.NewRequest("POST", "/api/members/"+strconvUint(survivorID)+"/merge", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("merge: expected 200, got %d, body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	merge(s.ID, d.ID)
+	var savedS, savedX models.Member
+	testDB.First(&savedS, s.ID)
+	testDB.First(&savedX, x.ID)
+	if savedS.ReferrerID == nil || *savedS.ReferrerID != x.ID || savedX.ReferrerID != nil {
+		t.Fatalf("expected S→X kept and X unlinked, got S.referrer=%v X.referrer=%v", savedS.ReferrerID, savedX.ReferrerID)
+	}
+
+	merge(p.ID, r.ID)
+	var savedP models.Member
+	testDB.First(&savedP, p.ID)
+	if savedP.ReferrerID != nil {
+		t.Fatalf("expected P not to adopt its own invitee as referrer, got %v", *savedP.ReferrerID)
+	}
+}
+
+func TestCreateMember_GeneratesCodeAndBindsReferrer(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/members", func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		CreateMember(c)
+	})
+
+	doPost := func(body gin.H) (*httptest.ResponseRecorder, models.Member) {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/members", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var res struct {
+			Data models.Member `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res.Data
+	}
+
+	w, referrer := doPost(gin.H{"name": "Ref", "phone": "10000000071", "invitation_code": "HANDMADE"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	if len(referrer.InvitationCode) != util.InvitationCodeLength || referrer.InvitationCode == "HANDMADE" {
+		t.Fatalf("expected server generated invitation code, got %q", referrer.InvitationCode)
+	}
+
+	w, invitee := doPost(gin.H{"name": "Inv", "phone": "10000000072", "referrer_code": strings.ToLower(referrer.InvitationCode)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	if invitee.ReferrerID == nil || *invitee.ReferrerID != referrer.ID {
+		t.Fatalf("expected referrer %d, got %v", referrer.ID, invitee.ReferrerID)
+	}
+	if invitee.ReferralBoundAt == nil || invitee.ReferralBoundBy == nil || *invitee.ReferralBoundBy != 7 {
+		t.Fatalf("expected binding audit fields, got %+v", invitee)
+	}
+	if invitee.InvitationCode == referrer.InvitationCode {
+		t.Fatalf("expected unique invitation codes")
+	}
+
+	if w, _ := doPost(gin.H{"name": "X", "phone": "10000000073", "referrer_code": "NOPE99"}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown referrer code, got %d", w.Code)
+	}
+	if w, _ := doPost(gin.H{"name": "Dup", "phone": "10000000071"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate phone, got %d", w.Code)
+	}
+
+	// 退订营销通知的会员须以 false 入库，新会员默认有效
+	w, optedOut := doPost(gin.H{"name": "Quiet", "phone": "10000000074", "marketing_opt_in": false})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var stored models.Member
+	testDB.First(&stored, optedOut.ID)
+	if stored.MarketingOptIn || optedOut.MarketingOptIn {
+		t.Fatalf("expected marketing opt-out stored, got stored=%v response=%v", stored.MarketingOptIn, optedOut.MarketingOptIn)
+	}
+	if !stored.IsActive || !optedOut.IsActive {
+		t.Fatalf("expected new member active, got stored=%v response=%v", stored.IsActive, optedOut.IsActive)
+	}
+	var defaulted models.Member
+	testDB.First(&defaulted, referrer.ID)
+	if !defaulted.MarketingOptIn {
+		t.Fatalf("expected marketing opt-in by default")
+	}
+}
+
+func TestBindMemberReferrer_RejectsSelfAndCycles(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	a := models.Member{Name: "A", Phone: "10000000081", InvitationCode: "AAAAAA"}
+	testDB.Create(&a)
+	b := models.Member{Name: "B", Phone: "10000000082", InvitationCode: "BBBBBB", ReferrerID: &a.ID}
+	testDB.Create(&b)
+	c := models.Member{Name: "C", Phone: "10000000083", InvitationCode: "CCCCCC", ReferrerID: &b.ID}
+	testDB.Create(&c)
+	d := models.Member{Name: "D", Phone: "10000000084", InvitationCode: "DDDDDD"}
+	testDB.Create(&d)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/members/:id/referrer", BindMemberReferrer)
+
+	bind := func(memberID uint, code string) int {
+		payload, _ := json.Marshal(gin.H{"referrer_code": code})
+		req, _ := http.NewRequest("POST", "/api/members/"+strconvUint(memberID)+"/referrer", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := bind(a.ID, "AAAAAA"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for self referral, got %d", code)
+	}
+	if code := bind(a.ID, "CCCCCC"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for referral cycle, got %d", code)
+	}
+	if code := bind(b.ID, "DDDDDD"); code != http.StatusConflict {
+		t.Fatalf("expected 409 when referrer already bound, got %d", code)
+	}
+	if code := bind(d.ID, "CCCCCC"); code != http.StatusOK {
+		t.Fatalf("expected 200 for valid binding, got %d", code)
+	}
+
+	var reloaded models.Member
+	testDB.First(&reloaded, d.ID)
+	if reloaded.ReferrerID == nil || *reloaded.ReferrerID != c.ID || reloaded.ReferralBoundAt == nil {
+		t.Fatalf("expected D bound to C, got %+v", reloaded)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// MemberTierRequest 新增/修改会员等级的请求体
+type MemberTierRequest struct {
+	Name                 string   `json:"name" binding:"required,max=32"`
+	DisplayName          string   `json:"display_name" binding:"max=64"`
+	Threshold            float64  `json:"threshold" binding:"gte=0"`
+	ServiceDiscount      float64  `json:"service_discount" binding:"required,gt=0,lte=1"`
+	ProductDiscount      float64  `json:"product_discount" binding:"required,gt=0,lte=1"`
+	CommissionMultiplier *float64 `json:"commission_multiplier" binding:"omitempty,gte=0"`
+	PointsMultiplier     *float64 `json:"points_multiplier" binding:"omitempty,gte=0"`
+	Perks                []string `json:"perks"`
+}
+
+// apply 将请求内容写入等级记录
+func (req MemberTierRequest) apply(tier *models.MemberTier) {
+	tier.Name = req.Name
+	tier.DisplayName = req.DisplayName
+	tier.Threshold = req.Threshold
+	tier.ServiceDiscount = req.ServiceDiscount
+	tier.ProductDiscount = req.ProductDiscount
+	tier.CommissionMultiplier = 1
+	if req.CommissionMultiplier != nil {
+		tier.CommissionMultiplier = *req.CommissionMultiplier
+	}
+	tier.PointsMultiplier = 1
+	if req.PointsMultiplier != nil {
+		tier.PointsMultiplier = *req.PointsMultiplier
+	}
+	perks := req.Perks
+	if perks == nil {
+		perks = []string{}
+	}
+	raw, _ := json.Marshal(perks)
+	tier.Perks = datatypes.JSON(raw)
+}
+
+var errTierConflict = errors.New("tier name or threshold already exists")
+
+// checkTierConflict 校验等级名称与升级阈值不与其他等级重复
+func checkTierConflict(tx *gorm.DB, tier *models.MemberTier) error {
+	var count int64
+	if err := tx.Model(&models.MemberTier{}).
+		Where("id <> ? AND (name = ? OR threshold = ?)", tier.ID, tier.Name, tier.Threshold).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errTierConflict
+	}
+	return nil
+}
+
+// ListMemberTiers 获取会员等级列表（按升级阈值从低到高）
+func ListMemberTiers(c *gin.Context) {
+	tiers, err := membership.Tiers(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch member tiers", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(tiers, ""))
+}
+
+// CreateMemberTier 新增会员等级
+func CreateMemberTier(c *gin.Context) {
+	var req MemberTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var tier models.MemberTier
+	req.apply(&tier)
+
+	if err := checkTierConflict(db.DB, &tier); err != nil {
+		if errors.Is(err, errTierConflict) {
+			c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Tier name or threshold already exists", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to check member tiers", err.Error()))
+		return
+	}
+
+	if err := db.DB.Create(&tier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create member tier", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(tier, "Member tier created successfully"))
+}
+
+// UpdateMemberTier 修改会员等级
+// 修改等级名称时同步更新该等级下会员的 level 字段；调整阈值后可调用等级重算接口让会员等级立即生效
+func UpdateMemberTier(c *gin.Context) {
+	var req MemberTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var tier models.MemberTier
+	if err := tx.First(&tier, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member tier not found", nil))
+		return
+	}
+
+	oldName := tier.Name
+	req.apply(&tier)
+
+	if err := checkTierConflict(tx, &tier); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errTierConflict) {
+			c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Tier name or threshold already exists", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to check member tiers", err.Error()))
+		return
+	}
+
+	if err := tx.Save(&tier).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member tier", err.Error()))
+		return
+	}
+
+	if oldName != tier.Name {
+		if err := tx.Unscoped().Model(&models.Member{}).Where("level = ?", oldName).Update("level", tier.Name).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to rename member levels", err.Error()))
+			return
+		}
+	}
+
+	tx.Commit()
+	c.JSON(http.StatusOK, response.Success(tier, "Member tier updated successfully"))
+}
+
+// DeleteMemberTier 删除会员等级
+// 仍有会员处于该等级或只剩最后一个等级时拒绝删除
+func DeleteMemberTier(c *gin.Context) {
+	var tier models.MemberTier
+	if err := db.DB.First(&tier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member tier not found", nil))
+		return
+	}
+
+	var tierCount int64
+	if err := db.DB.Model(&models.MemberTier{}).Count(&tierCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count member tiers", err.Error()))
+		return
+	}
+	if tierCount <= 1 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Cannot delete the last member tier", nil))
+		return
+	}
+
+	var memberCount int64
+	if err := db.DB.Model(&models.Member{}).Where("level = ?", tier.Name).Count(&memberCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count tier members", err.Error()))
+		return
+	}
+	if memberCount > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Member tier is still in use", gin.H{"member_count": memberCount}))
+		return
+	}
+
+	// 物理删除，便于之后复用同名等级或相同阈值
+	if err := db.DB.Unscoped().Delete(&tier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete member tier", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(nil, "Member tier deleted successfully"))
+}
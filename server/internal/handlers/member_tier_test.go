@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemberTierCRUD_ConflictsRenameAndDelete(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	if err := membership.SeedDefaultTiers(testDB); err != nil {
+		t.Fatalf("seed tiers: %v", err)
+	}
+	member := models.Member{Name: "Gold", Phone: "13800000031", InvitationCode: "GD0031", Level: "gold"}
+	testDB.Create(&member)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/member-tiers", CreateMemberTier)
+	router.PUT("/api/member-tiers/:id", UpdateMemberTier)
+	router.DELETE("/api/member-tiers/:id", DeleteMemberTier)
+
+	do := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, url, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 新增等级
+	w := do("POST", "/api/member-tiers", gin.H{
+		"name": "diamond", "threshold": 20000, "service_discount": 0.7, "product_discount": 0.75,
+		"commission_multiplier": 1.5, "perks": []string{"free parking"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	// 阈值重复
+	if w := do("POST", "/api/member-tiers", gin.H{
+		"name": "vip", "threshold": 20000, "service_discount": 0.9, "product_discount": 0.9,
+	}); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate threshold: expected 409, got %d", w.Code)
+	}
+
+	// 折扣率越界
+	if w := do("POST", "/api/member-tiers", gin.H{
+		"name": "vip", "threshold": 30000, "service_discount": 1.2, "product_discount": 0.9,
+	}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid discount: expected 400, got %d", w.Code)
+	}
+
+	// 改名同步会员等级
+	var gold models.MemberTier
+	testDB.Where("name = ?", "gold").First(&gold)
+	if w := do("PUT", "/api/member-tiers/"+strconvUint(gold.ID), gin.H{
+		"name": "gold-plus", "threshold": gold.Threshold, "service_discount": 0.88, "product_discount": 0.9,
+	}); w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var reloaded models.Member
+	testDB.First(&reloaded, member.ID)
+	if reloaded.Level != "gold-plus" {
+		t.Fatalf("expected member level renamed to gold-plus, got %s", reloaded.Level)
+	}
+	if rate, _ := membership.ServiceDiscountRate(testDB, reloaded.Level); rate != 0.88 {
+		t.Fatalf("expected updated service discount 0.88, got %v", rate)
+	}
+
+	// 仍有会员的等级不能删除
+	if w := do("DELETE", "/api/member-tiers/"+strconvUint(gold.ID), nil); w.Code != http.StatusConflict {
+		t.Fatalf("delete in use: expected 409, got %d", w.Code)
+	}
+
+	var diamond models.MemberTier
+	testDB.Where("name = ?", "diamond").First(&diamond)
+	if w := do("DELETE", "/api/member-tiers/"+strconvUint(diamond.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+	var count int64
+	testDB.Unscoped().Model(&models.MemberTier{}).Where("name = ?", "diamond").Count(&count)
+	if count != 0 {
+		t.Fatalf("expected diamond tier to be removed, got %d", count)
+	}
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/membership"
 	"server/internal/models"
+	"server/internal/referral"
 	"server/internal/response"
-	"server/pkg/config"
-	"server/pkg/util"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -69,21 +72,21 @@ func CreateOrder(c *gin.Context) {
 			return
 		}
 
-		paidAmount := appt.ActualPrice
-		inviterID := appt.Member.ReferrerID
-		commissionAmount := 0.0
-		if inviterID != nil {
-			commissionAmount = util.CalculateRate(paidAmount, config.GlobalCommission.ReferralRate)
-		}
-
 		order := models.Order{
-			MemberID:         appt.MemberID,
-			InviterID:        inviterID,
-			PaidAmount:       paidAmount,
-			CommissionAmount: commissionAmount,
-			OrderType:        "service",
-			AppointmentID:    req.AppointmentID,
+			MemberID:      appt.MemberID,
+			InviterID:     appt.Member.ReferrerID,
+			PaidAmount:    appt.ActualPrice,
+			OrderType:     "service",
+			AppointmentID: req.AppointmentID,
+		}
+		// 补录订单只记录应得佣金，不重复发放
+		shares, err := referral.Compute(tx, &order, time.Now())
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to compute referral commission", err.Error()))
+			return
 		}
+		order.CommissionAmount = referral.Total(shares)
 		order.CreatedAt = appt.EndTime
 		order.UpdatedAt = appt.EndTime
 		if err := tx.Create(&order).Error; err != nil {
@@ -91,6 +94,11 @@ func CreateOrder(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to create order", err.Error()))
 			return
 		}
+		if _, err := membership.ApplySettledOrder(tx, &order, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member level", err.Error()))
+			return
+		}
 		tx.Commit()
 		c.JSON(http.StatusOK, response.Success(order, ""))
 		return
@@ -130,19 +138,20 @@ func CreateOrder(c *gin.Context) {
 		inviterID = invLog.Member.ReferrerID
 	}
 
-	commissionAmount := 0.0
-	if inviterID != nil {
-		commissionAmount = util.CalculateRate(paidAmount, config.GlobalCommission.ReferralRate)
-	}
-
 	order := models.Order{
-		MemberID:         *invLog.MemberID,
-		InviterID:        inviterID,
-		PaidAmount:       paidAmount,
-		CommissionAmount: commissionAmount,
-		OrderType:        "physical",
-		InventoryLogID:   req.InventoryLogID,
+		MemberID:       *invLog.MemberID,
+		InviterID:      inviterID,
+		PaidAmount:     paidAmount,
+		OrderType:      "physical",
+		InventoryLogID: req.InventoryLogID,
 	}
+	shares, err := referral.Compute(tx, &order, time.Now())
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to compute referral commission", err.Error()))
+		return
+	}
+	order.CommissionAmount = referral.Total(shares)
 	order.CreatedAt = invLog.CreatedAt
 	order.UpdatedAt = invLog.CreatedAt
 	if err := tx.Create(&order).Error; err != nil {
@@ -150,11 +159,111 @@ func CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Failed to create order", err.Error()))
 		return
 	}
+	if _, err := membership.ApplySettledOrder(tx, &order, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member level", err.Error()))
+		return
+	}
 
 	tx.Commit()
 	c.JSON(http.StatusOK, response.Success(order, ""))
 }
 
+// RefundOrderRequest 订单退款请求体
+type RefundOrderRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// RefundOrder 订单退款：标记订单已退款，退回余额支付部分，商品订单退回库存，
+// 退回抵扣的积分与优惠券、收回消费积分并重新评估会员等级，撤销持有期内尚未入账的推荐佣金
+// 以上在同一事务中完成；现金部分线下退回
+func RefundOrder(c *gin.Context) {
+	var req RefundOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+
+	now := time.Now()
+	var order models.Order
+	var reversed int
+	var benefits membership.RefundSummary
+	var returnLog *models.InventoryLog
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&order, c.Param("id")).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.Order{}).Where("id = ? AND refunded_at IS NULL", order.ID).
+			Updates(map[string]interface{}{"refunded_at": now, "refund_reason": req.Reason})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return referral.ErrOrderRefunded
+		}
+		order.RefundedAt = &now
+		order.RefundReason = req.Reason
+
+		// 服务订单的余额支付部分退回会员余额
+		if order.AppointmentID != nil {
+			var appt models.Appointment
+			if err := tx.Select("id", "paid_balance").First(&appt, *order.AppointmentID).Error; err != nil {
+				return err
+			}
+			if appt.PaidBalance > 0 {
+				if err := tx.Model(&models.Member{}).Where("id = ?", order.MemberID).
+					Update("balance", gorm.Expr("balance + ?", appt.PaidBalance)).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// 商品订单退回库存
+		if order.InventoryLogID != nil {
+			var saleLog models.InventoryLog
+			if err := tx.First(&saleLog, *order.InventoryLogID).Error; err != nil {
+				return err
+			}
+			var operatorID uint
+			if id := currentOperatorID(c); id != nil {
+				operatorID = *id
+			}
+			var err error
+			returnLog, err = inventory.ReturnSale(tx, &saleLog, operatorID, fmt.Sprintf("订单 #%d 退款退货", order.ID), now)
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if benefits, err = membership.ApplyRefundedOrder(tx, &order, now); err != nil {
+			return err
+		}
+		reversed, err = referral.ReverseForOrder(tx, &order, "order refunded: "+req.Reason, now)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Order not found", nil))
+		case errors.Is(err, referral.ErrOrderRefunded):
+			c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+		default:
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to refund order", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"order":                order,
+		"reversed_commissions": reversed,
+		"points_refunded":      benefits.PointsRefunded,
+		"points_revoked":       benefits.PointsRevoked,
+		"level_change":         benefits.LevelChange,
+		"return_log":           returnLog,
+	}, "Order refunded successfully"))
+}
+
 func ListOrders(c *gin.Context) {
 	database := db.GetDB()
 
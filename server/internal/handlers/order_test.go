@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"server/internal/db"
+	"server/internal/membership"
 	"server/internal/models"
 	"server/pkg/config"
 	"server/pkg/util"
@@ -48,6 +49,24 @@ func setupOrderTestDB(t *testing.T) *gorm.DB {
 		&models.InventoryLog{},
 		&models.Order{},
 		&models.FissionLog{},
+		&models.MemberLevelChange{},
+		&models.MemberTier{},
+		&models.PointsTransaction{},
+		&models.Coupon{},
+		&models.CampaignIssue{},
+		&models.MemberRFM{},
+		&models.Tag{},
+		&models.SavedSegment{},
+		&models.SegmentActionLog{},
+		&models.HealthIntake{},
+		&models.TreatmentNote{},
+		&models.ReferralProgram{},
+		&models.CommissionWithdrawal{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.ProductBatch{},
+		&models.BatchConsumption{}, &models.StockAlert{}, &models.Stocktake{}, &models.StocktakeLine{}, &models.ServiceMaterial{}, &models.ProductCategory{}, &models.ParentProduct{},
 	); err != nil {
 		t.Fatalf("Failed to migrate: %v", err)
 	}
@@ -336,12 +355,187 @@ func TestCompleteAppointment_CreatesServiceOrder(t *testing.T) {
 	if order.InviterID == nil || *order.InviterID != referrer.ID {
 		t.Fatalf("expected inviter_id %d, got %v", referrer.ID, order.InviterID)
 	}
-	expectedCommission := util.CalculateRate(appt.ActualPrice, config.GlobalCommission.ReferralRate)
+	expectedCommission := util.CalculateRate(appt.ActualPrice, config.DefaultReferralProgram.ServiceRates[0])
 	if util.ToCents(order.CommissionAmount) != util.ToCents(expectedCommission) {
 		t.Fatalf("expected commission %.2f, got %.2f", expectedCommission, order.CommissionAmount)
 	}
 }
 
+func TestRefundOrder_ReturnsBalanceAndReversesPendingCommission(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	referrer := models.Member{Name: "Ref", Phone: "10000000031", InvitationCode: "code-10000000031"}
+	testDB.Create(&referrer)
+	invitee := models.Member{Name: "Inv", Phone: "10000000032", InvitationCode: "code-10000000032", Balance: 100, ReferrerID: &referrer.ID}
+	testDB.Create(&invitee)
+	tech := models.Technician{Name: "Bob", Status: 0}
+	service := models.ServiceProduct{Name: "Massage", Duration: 60, Price: 100}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	appt := models.Appointment{MemberID: invitee.ID, TechID: tech.ID, ServiceID: service.ID, StartTime: time.Now().Add(-2 * time.Hour), EndTime: time.Now().Add(-1 * time.Hour), Status: "pending", OriginPrice: 100, ActualPrice: 100}
+	testDB.Create(&appt)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/appointments/:id/complete", CompleteAppointment)
+	router.POST("/api/orders/:id/refund", RefundOrder)
+
+	payBody, _ := json.Marshal(gin.H{"payment_method": "balance", "balance_amount": 100, "cash_amount": 0})
+	req, _ := http.NewRequest("PUT", "/api/appointments/"+strconvUint(appt.ID)+"/complete", bytes.NewReader(payBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var order models.Order
+	testDB.Where("appointment_id = ?", appt.ID).First(&order)
+	var fissionLog models.FissionLog
+	if err := testDB.Where("order_id = ?", order.ID).First(&fissionLog).Error; err != nil || fissionLog.Status != "pending" {
+		t.Fatalf("expected pending commission, got %+v err=%v", fissionLog, err)
+	}
+
+	refund := func() int {
+		body, _ := json.Marshal(gin.H{"reason": "customer complaint"})
+		req, _ := http.NewRequest("POST", "/api/orders/"+strconvUint(order.ID)+"/refund", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := refund(); code != http.StatusOK {
+		t.Fatalf("refund: expected 200, got %d", code)
+	}
+	if code := refund(); code != http.StatusConflict {
+		t.Fatalf("second refund: expected 409, got %d", code)
+	}
+
+	testDB.First(&fissionLog, fissionLog.ID)
+	testDB.First(&order, order.ID)
+	if fissionLog.Status != "reversed" || order.CommissionAmount != 0 || order.RefundedAt == nil {
+		t.Fatalf("expected reversed commission on refunded order, log=%+v order=%+v", fissionLog, order)
+	}
+	var buyer models.Member
+	testDB.First(&buyer, invitee.ID)
+	if buyer.Balance != 100 {
+		t.Fatalf("expected balance refunded to 100, got %v", buyer.Balance)
+	}
+}
+
+func TestRefundOrder_ReversesPointsCouponLevelAndStock(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-refund", PasswordHash: "x", Role: "manager", IsActive: true}
+	testDB.Create(&operator)
+	member := models.Member{Name: "Rfd", Phone: "10000000391", InvitationCode: "code-10000000391", Level: "basic", Points: 3000, IsActive: true}
+	testDB.Create(&member)
+	expires := time.Now().AddDate(0, 6, 0)
+	testDB.Create(&models.PointsTransaction{MemberID: member.ID, Type: "earn", Points: 3000, Remaining: 3000, BalanceAfter: 3000, ExpiresAt: &expires})
+	coupon, _ := membership.IssueCoupon(testDB, member.ID, "满减", 30, 0, time.Now().AddDate(0, 0, 30))
+
+	tech := models.Technician{Name: "Tech", Status: 0}
+	service := models.ServiceProduct{Name: "Package", Duration: 60, Price: 1200}
+	product := models.PhysicalProduct{Name: "Cream", Stock: 0, RetailPrice: 50, CostPrice: 20, IsActive: true}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	testDB.Create(&product)
+	appt := models.Appointment{
+		MemberID: member.ID, TechID: tech.ID, ServiceID: service.ID,
+		StartTime: time.Now().Add(-2 * time.Hour), EndTime: time.Now().Add(-1 * time.Hour),
+		Status: "pending", OriginPrice: 1200, ActualPrice: 1200,
+	}
+	testDB.Create(&appt)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.PUT("/api/appointments/:id/complete", CompleteAppointment)
+	router.POST("/api/inventory/change", CreateInventoryChange)
+	router.POST("/api/orders/:id/refund", RefundOrder)
+
+	send := func(method, path string, body gin.H) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 2000 积分抵扣 20 元 + 30 元优惠券，现金 1150 元，消费额达到白银门槛
+	if w := send("PUT", "/api/appointments/"+strconvUint(appt.ID)+"/complete", gin.H{
+		"payment_method": "mixed", "cash_amount": 1150, "points_used": 2000, "coupon_id": coupon.ID,
+	}); w.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var order models.Order
+	testDB.Where("appointment_id = ?", appt.ID).First(&order)
+	var settled models.Member
+	testDB.First(&settled, member.ID)
+	if settled.Level != "silver" || settled.Points <= 1000 {
+		t.Fatalf("expected silver member with earned points after settlement, got %+v", settled)
+	}
+
+	if w := send("POST", "/api/orders/"+strconvUint(order.ID)+"/refund", gin.H{"reason": "service not delivered"}); w.Code != http.StatusOK {
+		t.Fatalf("refund: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var refunded models.Member
+	testDB.First(&refunded, member.ID)
+	if refunded.Points != 3000 {
+		t.Fatalf("expected points restored to 3000, got %d", refunded.Points)
+	}
+	if refunded.Level != "basic" || refunded.YearlyTotalConsumption != 0 {
+		t.Fatalf("expected basic level with zero consumption after refund, got level=%s consumption=%v", refunded.Level, refunded.YearlyTotalConsumption)
+	}
+	var change models.MemberLevelChange
+	if err := testDB.Where("member_id = ? AND source = ?", member.ID, "refund").First(&change).Error; err != nil || change.FromLevel != "silver" || change.ToLevel != "basic" {
+		t.Fatalf("expected silver->basic refund level change, got %+v err=%v", change, err)
+	}
+	var released models.Coupon
+	testDB.First(&released, coupon.ID)
+	if released.UsedAt != nil || released.OrderID != nil {
+		t.Fatalf("expected coupon released after refund, got %+v", released)
+	}
+
+	// 商品订单退款退回库存，并恢复出库时扣减的批次
+	later := time.Now().AddDate(0, 0, 200).Format("2006-01-02")
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": 5, "action_type": "restock", "batch_no": "B1", "expiry_date": later}); w.Code != http.StatusOK {
+		t.Fatalf("restock: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -2, "action_type": "sale", "member_id": member.ID}); w.Code != http.StatusOK {
+		t.Fatalf("sale: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var sale models.Order
+	testDB.Where("member_id = ? AND order_type = ?", member.ID, "physical").First(&sale)
+	if w := send("POST", "/api/orders/"+strconvUint(sale.ID)+"/refund", gin.H{"reason": "returned"}); w.Code != http.StatusOK {
+		t.Fatalf("refund sale: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	testDB.First(&product, product.ID)
+	if product.Stock != 5 {
+		t.Fatalf("expected stock restored to 5, got %d", product.Stock)
+	}
+	var batch models.ProductBatch
+	testDB.Where("product_id = ? AND batch_no = ?", product.ID, "B1").First(&batch)
+	if batch.Remaining != 5 {
+		t.Fatalf("expected batch remaining restored to 5, got %d", batch.Remaining)
+	}
+	var returnLog models.InventoryLog
+	if err := testDB.Where("product_id = ? AND action_type = ?", product.ID, "return").First(&returnLog).Error; err != nil || returnLog.ChangeAmount != 2 {
+		t.Fatalf("expected return inventory log of 2, got %+v err=%v", returnLog, err)
+	}
+	testDB.First(&refunded, member.ID)
+	if refunded.Points != 3000 {
+		t.Fatalf("expected sale points revoked on refund, got %d", refunded.Points)
+	}
+}
+
 func strconvUint(v uint) string {
 	return strconv.FormatUint(uint64(v), 10)
 }
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompleteAppointment_RedeemsAndEarnsPoints(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	member := models.Member{Name: "Pts", Phone: "10000000041", InvitationCode: "code-10000000041", Level: "basic", Points: 3000}
+	testDB.Create(&member)
+	expires := time.Now().AddDate(0, 6, 0)
+	testDB.Create(&models.PointsTransaction{MemberID: member.ID, Type: "earn", Points: 3000, Remaining: 3000, BalanceAfter: 3000, ExpiresAt: &expires})
+
+	tech := models.Technician{Name: "Tech", Status: 0}
+	service := models.ServiceProduct{Name: "Facial", Duration: 60, Price: 100}
+	testDB.Create(&tech)
+	testDB.Create(&service)
+	appt := models.Appointment{
+		MemberID: member.ID, TechID: tech.ID, ServiceID: service.ID,
+		StartTime: time.Now().Add(-2 * time.Hour), EndTime: time.Now().Add(-1 * time.Hour),
+		Status: "pending", OriginPrice: 100, ActualPrice: 100,
+	}
+	testDB.Create(&appt)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/appointments/:id/complete", CompleteAppointment)
+
+	complete := func(body gin.H) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("PUT", "/api/appointments/"+strconvUint(appt.ID)+"/complete", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 超出单笔抵扣上限（50%）
+	if w := complete(gin.H{"payment_method": "mixed", "cash_amount": 40, "points_used": 6000}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for over-limit redemption, got %d", w.Code)
+	}
+
+	// 2000 积分抵扣 20 元，现金支付 80 元
+	if w := complete(gin.H{"payment_method": "mixed", "cash_amount": 80, "points_used": 2000}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var order models.Order
+	testDB.Where("appointment_id = ?", appt.ID).First(&order)
+	if order.PaidAmount != 80 || order.PointsDeduction != 20 {
+		t.Fatalf("expected paid 80 with 20 deduction, got %+v", order)
+	}
+
+	// 3000 - 2000 + 80（实付金额按普通会员 1 倍积分）
+	var reloaded models.Member
+	testDB.First(&reloaded, member.ID)
+	if reloaded.Points != 1080 {
+		t.Fatalf("expected 1080 points, got %d", reloaded.Points)
+	}
+	if reloaded.YearlyTotalConsumption != 80 {
+		t.Fatalf("expected consumption 80 excluding points deduction, got %v", reloaded.YearlyTotalConsumption)
+	}
+}
+
+func TestCreateInventoryChange_RejectsPointsOutsideSale(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-points", PasswordHash: "x", Role: "manager", IsActive: true}
+	product := models.PhysicalProduct{Name: "Toner", Stock: 10, RetailPrice: 80, CostPrice: 30, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/inventory/change", CreateInventoryChange)
+
+	for _, action := range []string{"restock", "adjustment"} {
+		payload, _ := json.Marshal(gin.H{"product_id": product.ID, "action_type": action, "change_amount": 2, "points_used": 500})
+		req, _ := http.NewRequest("POST", "/api/inventory/change", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s with points_used: expected 400, got %d body=%s", action, w.Code, w.Body.String())
+		}
+	}
+
+	var reloaded models.PhysicalProduct
+	testDB.First(&reloaded, product.ID)
+	if reloaded.Stock != 10 {
+		t.Fatalf("expected stock untouched, got %d", reloaded.Stock)
+	}
+}
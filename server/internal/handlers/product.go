@@ -1,25 +1,43 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"server/internal/db"
+	"server/internal/inventory"
 	"server/internal/models"
 	"server/internal/response"
+	"server/pkg/config"
+	"server/pkg/util"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // CreateProductRequest represents the request body for creating a product
 type CreateProductRequest struct {
-	Name        string  `json:"name" binding:"required"`
+	Name        string  `json:"name" binding:"required_without=ParentID"`
 	Stock       int     `json:"stock" binding:"required,min=0"`
 	RetailPrice float64 `json:"retail_price" binding:"required,min=0"`
 	CostPrice   float64 `json:"cost_price" binding:"required,min=0"`
 	Description string  `json:"description"`
 	ImageURL    string  `json:"image_url"`
 	IsActive    bool    `json:"is_active"`
+	// ReorderPoint 补货点，未指定时使用默认值
+	ReorderPoint *int   `json:"reorder_point" binding:"omitempty,min=0"`
+	TargetStock  int    `json:"target_stock" binding:"min=0"`
+	CategoryID   *uint  `json:"category_id"`
+	SKU          string `json:"sku" binding:"max=64"`
+	Barcode      string `json:"barcode" binding:"max=64"`
+	// ParentID 所属父商品，设置后名称、分类、描述、图片取自父商品，须填写规格名称
+	ParentID    *uint          `json:"parent_id"`
+	VariantName string         `json:"variant_name" binding:"max=64"`
+	Options     datatypes.JSON `json:"options"` // 规格属性，如 {"size":"10ml","scent":"lavender"}
 }
 
 // UpdateProductRequest represents the request body for updating a product
@@ -30,30 +48,153 @@ type UpdateProductRequest struct {
 	Description string  `json:"description"`
 	ImageURL    string  `json:"image_url"`
 	IsActive    *bool   `json:"is_active"`
+	// ReorderPoint/TargetStock 为空时不修改，补货点可设为 0（售罄才提醒），目标库存设为 0 表示按销售速度估算
+	ReorderPoint *int `json:"reorder_point" binding:"omitempty,min=0"`
+	TargetStock  *int `json:"target_stock" binding:"omitempty,min=0"`
+	// CategoryID/SKU/Barcode 为空时不修改；category_id 传 0、sku/barcode 传空字符串表示清除
+	CategoryID *uint   `json:"category_id"`
+	SKU        *string `json:"sku" binding:"omitempty,max=64"`
+	Barcode    *string `json:"barcode" binding:"omitempty,max=64"`
+	// ParentID/VariantName/Options 为空时不修改；parent_id 传 0 表示转为独立商品
+	ParentID    *uint          `json:"parent_id"`
+	VariantName *string        `json:"variant_name" binding:"omitempty,max=64"`
+	Options     datatypes.JSON `json:"options"`
 }
 
-// ListProducts returns all physical products
+var (
+	errUnknownCategory  = errors.New("category not found")
+	errDuplicateSKU     = errors.New("SKU is already used by another product")
+	errDuplicateBarcode = errors.New("barcode is already used by another product")
+)
+
+// normalizeProductCode 去除编码首尾空白，空编码存为 NULL
+func normalizeProductCode(code string) *string {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil
+	}
+	return &code
+}
+
+// validateProductCatalog 校验分类存在，且 SKU、条码未被其他商品占用
+func validateProductCatalog(tx *gorm.DB, product *models.PhysicalProduct) error {
+	if product.CategoryID != nil {
+		var count int64
+		if err := tx.Model(&models.ProductCategory{}).Where("id = ?", *product.CategoryID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errUnknownCategory
+		}
+	}
+	codes := []struct {
+		column string
+		value  *string
+		err    error
+	}{{"sku", product.SKU, errDuplicateSKU}, {"barcode", product.Barcode, errDuplicateBarcode}}
+	for _, code := range codes {
+		if code.value == nil {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.PhysicalProduct{}).
+			Where(code.column+" = ? AND id <> ?", *code.value, product.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return code.err
+		}
+	}
+	return nil
+}
+
+// writeCatalogError 将分类、SKU、条码校验错误转换为对应的 HTTP 响应
+func writeCatalogError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errUnknownCategory):
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+	case errors.Is(err, errDuplicateSKU), errors.Is(err, errDuplicateBarcode):
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, fallback, err.Error()))
+	}
+}
+
+// ListProducts returns physical products
+// GET /api/products?q=oil&category_id=1&is_active=true&page=1&page_size=20
+// q 按名称、SKU、条码模糊搜索；category_id=0 查询未分类商品；parent_id 查询某父商品的规格，parent_id=0 查询独立商品；
+// 未传 page/page_size 时返回全部商品
 func ListProducts(c *gin.Context) {
-	var products []models.PhysicalProduct
+	products := make([]models.PhysicalProduct, 0)
 	database := db.GetDB()
 
-	query := database.Order("created_at DESC")
+	query := database.Model(&models.PhysicalProduct{})
 
 	// Filter by active status if specified
 	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
 		isActive := isActiveStr == "true"
 		query = query.Where("is_active = ?", isActive)
 	}
+	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
+		categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid category_id", nil))
+			return
+		}
+		if categoryID == 0 {
+			query = query.Where("category_id IS NULL")
+		} else {
+			query = query.Where("category_id = ?", uint(categoryID))
+		}
+	}
+	if parentIDStr := c.Query("parent_id"); parentIDStr != "" {
+		parentID, err := strconv.ParseUint(parentIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid parent_id", nil))
+			return
+		}
+		if parentID == 0 {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", uint(parentID))
+		}
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("name LIKE ? OR sku LIKE ? OR barcode LIKE ?", like, like, like)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count products", nil))
+		return
+	}
+
+	result := gin.H{}
+	query = query.Preload("Category").Preload("Parent").Order("created_at DESC, id DESC")
+	if c.Query("page") != "" || c.Query("page_size") != "" {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 || pageSize > 100 {
+			pageSize = 20
+		}
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+		result["page"] = page
+		result["page_size"] = pageSize
+	}
 
 	if err := query.Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch products", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.Success(gin.H{
-		"products": products,
-		"total":    len(products),
-	}, ""))
+	result["products"] = products
+	result["total"] = total
+	c.JSON(http.StatusOK, response.Success(result, ""))
 }
 
 // GetProduct returns a single product by ID
@@ -67,7 +208,7 @@ func GetProduct(c *gin.Context) {
 	var product models.PhysicalProduct
 	database := db.GetDB()
 
-	if err := database.First(&product, id).Error; err != nil {
+	if err := database.Preload("Category").Preload("Parent").First(&product, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Product not found", nil))
 		return
 	}
@@ -75,6 +216,28 @@ func GetProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, response.Success(product, ""))
 }
 
+// GetProductByBarcode 前台扫码按条码查询商品
+// GET /api/products/barcode/:code
+func GetProductByBarcode(c *gin.Context) {
+	code := strings.TrimSpace(c.Param("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Barcode is required", nil))
+		return
+	}
+
+	var product models.PhysicalProduct
+	if err := db.GetDB().Preload("Category").Where("barcode = ?", code).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "No product with this barcode", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch product", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(product, ""))
+}
+
 // CreateProduct creates a new physical product
 func CreateProduct(c *gin.Context) {
 	var req CreateProductRequest
@@ -91,16 +254,39 @@ func CreateProduct(c *gin.Context) {
 	}
 
 	database := db.GetDB()
+	var err error
+
+	reorderPoint := config.GlobalInventoryPolicy.DefaultReorderPoint
+	if req.ReorderPoint != nil {
+		reorderPoint = *req.ReorderPoint
+	}
 
 	// Create product
 	product := models.PhysicalProduct{
-		Name:        req.Name,
-		Stock:       req.Stock,
-		RetailPrice: req.RetailPrice,
-		CostPrice:   req.CostPrice,
-		Description: req.Description,
-		ImageURL:    req.ImageURL,
-		IsActive:    req.IsActive,
+		Name:         req.Name,
+		Stock:        req.Stock,
+		RetailPrice:  req.RetailPrice,
+		CostPrice:    req.CostPrice,
+		Description:  req.Description,
+		ImageURL:     req.ImageURL,
+		IsActive:     req.IsActive,
+		ReorderPoint: reorderPoint,
+		TargetStock:  req.TargetStock,
+		CategoryID:   req.CategoryID,
+		SKU:          normalizeProductCode(req.SKU),
+		Barcode:      normalizeProductCode(req.Barcode),
+		ParentID:     req.ParentID,
+		VariantName:  req.VariantName,
+	}
+	if product.Options, err = normalizeVariantOptions(req.Options); err == nil {
+		err = attachToParent(database, &product)
+	}
+	if err == nil {
+		err = validateProductCatalog(database, &product)
+	}
+	if err != nil {
+		writeParentError(c, err, "Failed to validate product")
+		return
 	}
 
 	// Use transaction to ensure product and initial inventory log are created together
@@ -116,6 +302,14 @@ func CreateProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create product", nil))
 		return
 	}
+	// 补货点列带默认值，创建时零值会被默认值替换，显式指定为 0 时需单独写入
+	if reorderPoint == 0 {
+		if err := tx.Model(&product).Update("reorder_point", 0).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create product", nil))
+			return
+		}
+	}
 
 	// Create initial inventory log if stock > 0
 	if req.Stock > 0 {
@@ -126,6 +320,7 @@ func CreateProduct(c *gin.Context) {
 			ActionType:   "restock",
 			BeforeStock:  0,
 			AfterStock:   req.Stock,
+			UnitCost:     &product.CostPrice,
 			Remark:       "Initial stock",
 		}
 		if err := tx.Create(&inventoryLog).Error; err != nil {
@@ -133,6 +328,11 @@ func CreateProduct(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create inventory log", nil))
 			return
 		}
+		if _, err := inventory.CreateBatch(tx, &inventoryLog, inventory.BatchInput{}, time.Now()); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create batch", nil))
+			return
+		}
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -184,8 +384,55 @@ func UpdateProduct(c *gin.Context) {
 	if req.IsActive != nil {
 		product.IsActive = *req.IsActive
 	}
+	if req.ReorderPoint != nil {
+		product.ReorderPoint = *req.ReorderPoint
+	}
+	if req.TargetStock != nil {
+		product.TargetStock = *req.TargetStock
+	}
+	if req.CategoryID != nil {
+		product.CategoryID = req.CategoryID
+		if *req.CategoryID == 0 {
+			product.CategoryID = nil
+		}
+	}
+	if req.SKU != nil {
+		product.SKU = normalizeProductCode(*req.SKU)
+	}
+	if req.Barcode != nil {
+		product.Barcode = normalizeProductCode(*req.Barcode)
+	}
+	if req.ParentID != nil {
+		product.ParentID = req.ParentID
+		if *req.ParentID == 0 {
+			product.ParentID = nil
+		}
+	}
+	if req.VariantName != nil {
+		product.VariantName = *req.VariantName
+	}
+	if req.Options != nil {
+		if product.Options, err = normalizeVariantOptions(req.Options); err != nil {
+			writeParentError(c, err, "Failed to validate product")
+			return
+		}
+	}
+	// 规格的目录信息始终取自父商品；转为独立商品时清除规格名称与属性
+	if product.ParentID == nil {
+		product.VariantName = ""
+		product.Options = nil
+	}
+	if err := attachToParent(database, &product); err != nil {
+		writeParentError(c, err, "Failed to validate product")
+		return
+	}
+	if err := validateProductCatalog(database, &product); err != nil {
+		writeCatalogError(c, err, "Failed to validate product")
+		return
+	}
 
-	if err := database.Save(&product).Error; err != nil {
+	// 库存只能通过库存变更接口修改，保存时排除 stock，避免覆盖并发销售的扣减
+	if err := database.Omit("stock").Save(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update product", nil))
 		return
 	}
@@ -209,6 +456,12 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
+	// 软删除前释放 SKU 与条码，便于新商品复用
+	if err := database.Model(&product).Updates(map[string]interface{}{"sku": nil, "barcode": nil}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete product", nil))
+		return
+	}
+
 	// Soft delete
 	if err := database.Delete(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete product", nil))
@@ -219,6 +472,7 @@ func DeleteProduct(c *gin.Context) {
 }
 
 // GetProductStats returns statistics about products
+// 毛利统计可按 start/end 限定销售时间范围，默认统计全部未退款的商品订单
 func GetProductStats(c *gin.Context) {
 	database := db.GetDB()
 
@@ -226,8 +480,13 @@ func GetProductStats(c *gin.Context) {
 		TotalProducts   int64   `json:"total_products"`
 		ActiveProducts  int64   `json:"active_products"`
 		TotalValue      float64 `json:"total_value"`        // 库存总价值（按零售价）
-		LowStockCount   int64   `json:"low_stock_count"`    // 低库存商品数
+		TotalCostValue  float64 `json:"total_cost_value"`   // 库存总成本（按移动加权平均成本）
+		LowStockCount   int64   `json:"low_stock_count"`    // 低库存商品数（库存不高于补货点）
 		OutOfStockCount int64   `json:"out_of_stock_count"` // 零库存商品数
+		SalesRevenue    float64 `json:"sales_revenue"`      // 商品销售收入
+		CostOfGoods     float64 `json:"cost_of_goods"`      // 商品销售成本
+		GrossProfit     float64 `json:"gross_profit"`       // 毛利
+		GrossMargin     float64 `json:"gross_margin"`       // 毛利率（%）
 	}
 
 	// Total products
@@ -241,11 +500,40 @@ func GetProductStats(c *gin.Context) {
 	database.Find(&products)
 	for _, p := range products {
 		stats.TotalValue += float64(p.Stock) * p.RetailPrice
+		stats.TotalCostValue += inventory.CostOfGoods(p.Stock, p.CostPrice)
 	}
+	stats.TotalValue = util.RoundMoney(stats.TotalValue)
+	stats.TotalCostValue = util.RoundMoney(stats.TotalCostValue)
 
-	// Low stock (< 10) and out of stock
-	database.Model(&models.PhysicalProduct{}).Where("stock < ? AND stock > 0", 10).Count(&stats.LowStockCount)
+	// Low stock (at or below each product's reorder point) and out of stock
+	database.Model(&models.PhysicalProduct{}).Where("stock <= reorder_point AND stock > 0").Count(&stats.LowStockCount)
 	database.Model(&models.PhysicalProduct{}).Where("stock = 0").Count(&stats.OutOfStockCount)
 
+	// 销售毛利：成本取下单时记录的成本，历史订单按商品当前成本估算
+	salesQuery := database.Model(&models.Order{}).
+		Joins("JOIN inventory_logs ON inventory_logs.id = orders.inventory_log_id").
+		Joins("JOIN physical_products ON physical_products.id = inventory_logs.product_id").
+		Where("orders.order_type = ? AND orders.refunded_at IS NULL", "physical")
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+	if !start.IsZero() {
+		salesQuery = salesQuery.Where("orders.created_at >= ?", start)
+	}
+	if !end.IsZero() {
+		salesQuery = salesQuery.Where("orders.created_at < ?", end)
+	}
+	var sales struct {
+		Revenue float64
+		Cost    float64
+	}
+	if err := salesQuery.
+		Select("COALESCE(SUM(orders.paid_amount), 0) as revenue, COALESCE(SUM(COALESCE(orders.cost_amount, -inventory_logs.change_amount * physical_products.cost_price)), 0) as cost").
+		Scan(&sales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize product margin", err.Error()))
+		return
+	}
+	stats.SalesRevenue = util.RoundMoney(sales.Revenue)
+	stats.CostOfGoods = util.RoundMoney(sales.Cost)
+	stats.GrossProfit, stats.GrossMargin = inventory.GrossMargin(stats.SalesRevenue, stats.CostOfGoods)
+
 	c.JSON(http.StatusOK, response.Success(stats, ""))
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProductCatalog_CategoriesCodesSearchAndBarcodeLookup(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-catalog", PasswordHash: "x", Role: "manager", IsActive: true}
+	testDB.Create(&operator)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.GET("/api/products", ListProducts)
+	router.GET("/api/products/:id", GetProduct)
+	router.GET("/api/products/barcode/:code", GetProductByBarcode)
+	router.POST("/api/products", CreateProduct)
+	router.PUT("/api/products/:id", UpdateProduct)
+	router.DELETE("/api/products/:id", DeleteProduct)
+	router.GET("/api/product-categories", ListProductCategories)
+	router.POST("/api/product-categories", CreateProductCategory)
+	router.DELETE("/api/product-categories/:id", DeleteProductCategory)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type productResp struct {
+		Data models.PhysicalProduct `json:"data"`
+	}
+
+	var category struct {
+		Data models.ProductCategory `json:"data"`
+	}
+	w := send("POST", "/api/product-categories", gin.H{"name": "Skin Care"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create category: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &category)
+	if w := send("POST", "/api/product-categories", gin.H{"name": "Skin Care"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate category name, got %d", w.Code)
+	}
+
+	var serum productResp
+	w = send("POST", "/api/products", gin.H{"name": "Vitamin C Serum", "stock": 3, "retail_price": 120, "cost_price": 50, "is_active": true,
+		"category_id": category.Data.ID, "sku": " SK-001 ", "barcode": "6901234567890"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create product: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &serum)
+	if serum.Data.SKU == nil || *serum.Data.SKU != "SK-001" {
+		t.Fatalf("expected trimmed SKU, got %+v", serum.Data.SKU)
+	}
+	// 未填写编码的商品可以有多个
+	for _, name := range []string{"Hand Towel", "Bath Towel"} {
+		if w := send("POST", "/api/products", gin.H{"name": name, "stock": 1, "retail_price": 10, "cost_price": 2, "is_active": true}); w.Code != http.StatusOK {
+			t.Fatalf("create %s: expected 200, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+	if w := send("POST", "/api/products", gin.H{"name": "Copy", "stock": 1, "retail_price": 1, "cost_price": 1, "sku": "SK-001"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate SKU, got %d", w.Code)
+	}
+	if w := send("POST", "/api/products", gin.H{"name": "Copy", "stock": 1, "retail_price": 1, "cost_price": 1, "category_id": 9999}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown category, got %d", w.Code)
+	}
+
+	var found productResp
+	w = send("GET", "/api/products/barcode/6901234567890", nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	if w.Code != http.StatusOK || found.Data.ID != serum.Data.ID || found.Data.Category == nil || found.Data.Category.Name != "Skin Care" {
+		t.Fatalf("barcode lookup: got %d %+v", w.Code, found.Data)
+	}
+	if w := send("GET", "/api/products/barcode/000", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown barcode, got %d", w.Code)
+	}
+	if w := send("GET", fmt.Sprintf("/api/products/%d", serum.Data.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("expected product by id alongside barcode route, got %d", w.Code)
+	}
+
+	var list struct {
+		Data struct {
+			Products []models.PhysicalProduct `json:"products"`
+			Total    int64                    `json:"total"`
+			Page     int                      `json:"page"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", "/api/products?q=towel&page=1&page_size=1", nil).Body.Bytes(), &list)
+	if list.Data.Total != 2 || len(list.Data.Products) != 1 || list.Data.Page != 1 {
+		t.Fatalf("unexpected paginated search: %+v", list.Data)
+	}
+	json.Unmarshal(send("GET", "/api/products?q=SK-0", nil).Body.Bytes(), &list)
+	if list.Data.Total != 1 || list.Data.Products[0].ID != serum.Data.ID {
+		t.Fatalf("expected SKU search to find serum, got %+v", list.Data)
+	}
+	json.Unmarshal(send("GET", fmt.Sprintf("/api/products?category_id=%d", category.Data.ID), nil).Body.Bytes(), &list)
+	if list.Data.Total != 1 {
+		t.Fatalf("expected 1 product in category, got %+v", list.Data)
+	}
+	json.Unmarshal(send("GET", "/api/products?category_id=0", nil).Body.Bytes(), &list)
+	if list.Data.Total != 2 || len(list.Data.Products) != 2 {
+		t.Fatalf("expected 2 uncategorized products, got %+v", list.Data)
+	}
+
+	categoryPath := fmt.Sprintf("/api/product-categories/%d", category.Data.ID)
+	if w := send("DELETE", categoryPath, nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting a category in use, got %d", w.Code)
+	}
+	// 清除分类和条码后，分类可删除、条码可被新商品复用
+	productPath := fmt.Sprintf("/api/products/%d", serum.Data.ID)
+	if w := send("PUT", productPath, gin.H{"category_id": 0, "barcode": ""}); w.Code != http.StatusOK {
+		t.Fatalf("update product: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("DELETE", categoryPath, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete category: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("POST", "/api/products", gin.H{"name": "New Serum", "stock": 1, "retail_price": 1, "cost_price": 1, "barcode": "6901234567890"}); w.Code != http.StatusOK {
+		t.Fatalf("expected freed barcode to be reusable, got %d body=%s", w.Code, w.Body.String())
+	}
+	// 删除商品释放 SKU
+	send("DELETE", productPath, nil)
+	if w := send("POST", "/api/products", gin.H{"name": "Serum v2", "stock": 1, "retail_price": 1, "cost_price": 1, "sku": "SK-001"}); w.Code != http.StatusOK {
+		t.Fatalf("expected SKU of deleted product to be reusable, got %d body=%s", w.Code, w.Body.String())
+	}
+}
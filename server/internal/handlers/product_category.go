@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/internal/db"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductCategoryRequest 创建/修改商品分类的请求体
+type ProductCategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=64"`
+	Description string `json:"description" binding:"max=255"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// productCategoryNameTaken 检查分类名称是否已被其他分类使用
+func productCategoryNameTaken(name string, excludeID uint) (bool, error) {
+	var count int64
+	err := db.DB.Model(&models.ProductCategory{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+// ListProductCategories 查询商品分类及各分类下的商品数
+// GET /api/product-categories
+func ListProductCategories(c *gin.Context) {
+	type categoryWithCount struct {
+		models.ProductCategory
+		ProductCount int64 `json:"product_count"`
+	}
+
+	categories := make([]models.ProductCategory, 0)
+	if err := db.DB.Order("sort_order ASC, id ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch categories", err.Error()))
+		return
+	}
+
+	type countRow struct {
+		CategoryID uint
+		Count      int64
+	}
+	var rows []countRow
+	if err := db.DB.Model(&models.PhysicalProduct{}).
+		Select("category_id, COUNT(*) as count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count products", err.Error()))
+		return
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		counts[r.CategoryID] = r.Count
+	}
+
+	result := make([]categoryWithCount, 0, len(categories))
+	for _, category := range categories {
+		result = append(result, categoryWithCount{ProductCategory: category, ProductCount: counts[category.ID]})
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"categories": result,
+		"total":      len(result),
+	}, ""))
+}
+
+// CreateProductCategory 创建商品分类
+// POST /api/product-categories
+func CreateProductCategory(c *gin.Context) {
+	var req ProductCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if taken, err := productCategoryNameTaken(req.Name, 0); err != nil || taken {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create category", err.Error()))
+			return
+		}
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Category name already exists", nil))
+		return
+	}
+
+	category := models.ProductCategory{Name: req.Name, Description: req.Description, SortOrder: req.SortOrder}
+	if err := db.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create category", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(category, "Category created successfully"))
+}
+
+// UpdateProductCategory 修改商品分类
+// PUT /api/product-categories/:id
+func UpdateProductCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid category ID", nil))
+		return
+	}
+	var req ProductCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var category models.ProductCategory
+	if err := db.DB.First(&category, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Category not found", nil))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if taken, err := productCategoryNameTaken(req.Name, category.ID); err != nil || taken {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update category", err.Error()))
+			return
+		}
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Category name already exists", nil))
+		return
+	}
+
+	category.Name = req.Name
+	category.Description = req.Description
+	category.SortOrder = req.SortOrder
+	if err := db.DB.Save(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update category", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(category, "Category updated successfully"))
+}
+
+// DeleteProductCategory 删除商品分类；分类下仍有商品或父商品时不可删除
+// DELETE /api/product-categories/:id
+func DeleteProductCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid category ID", nil))
+		return
+	}
+
+	var category models.ProductCategory
+	if err := db.DB.First(&category, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Category not found", nil))
+		return
+	}
+	var inUse int64
+	if err := db.DB.Model(&models.PhysicalProduct{}).Where("category_id = ?", category.ID).Count(&inUse).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete category", err.Error()))
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Category still has products", gin.H{"product_count": inUse}))
+		return
+	}
+	if err := db.DB.Model(&models.ParentProduct{}).Where("category_id = ?", category.ID).Count(&inUse).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete category", err.Error()))
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Category still has parent products", gin.H{"parent_count": inUse}))
+		return
+	}
+
+	// 物理删除，释放分类名称的唯一索引
+	if err := db.DB.Unscoped().Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete category", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Category deleted successfully"))
+}
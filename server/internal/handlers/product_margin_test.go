@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProductMargin_CostCapturedOnSaleSurvivesCostChange(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-margin", PasswordHash: "x", Role: "manager", IsActive: true}
+	member := models.Member{Name: "Alice", Phone: "13800000101", InvitationCode: "M0101", IsActive: true}
+	product := models.PhysicalProduct{Name: "Oil", Stock: 10, RetailPrice: 50, CostPrice: 20, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&member)
+	testDB.Create(&product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/inventory/change", CreateInventoryChange)
+	router.GET("/api/products/stats", GetProductStats)
+	router.GET("/api/dashboard/product-sales", NewDashboardHandler(testDB).GetProductSalesOverview)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d body=%s", method, path, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	// 10 件 × 20 元 + 10 件 × 30 元 → 平均成本 25
+	send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": 10, "action_type": "restock", "unit_cost": 30})
+	var saved models.PhysicalProduct
+	testDB.First(&saved, product.ID)
+	if saved.CostPrice != 25 {
+		t.Fatalf("expected moving-average cost 25, got %v", saved.CostPrice)
+	}
+
+	send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -2, "action_type": "sale", "member_id": member.ID})
+	var order models.Order
+	testDB.Preload("InventoryLog").First(&order)
+	if order.CostAmount == nil || *order.CostAmount != 50 || order.InventoryLog.CostAmount == nil || *order.InventoryLog.CostAmount != 50 {
+		t.Fatalf("expected cost of goods 50 on order and log, got %+v", order)
+	}
+
+	// 之后成本变化不影响已售订单的毛利
+	testDB.Model(&models.PhysicalProduct{}).Where("id = ?", product.ID).Update("cost_price", 40)
+
+	var overview struct {
+		Data struct {
+			TotalRevenue float64 `json:"totalRevenue"`
+			TotalCost    float64 `json:"totalCost"`
+			GrossProfit  float64 `json:"grossProfit"`
+			GrossMargin  float64 `json:"grossMargin"`
+			TopProducts  []struct {
+				GrossProfit float64 `json:"gross_profit"`
+			} `json:"topProducts"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", "/api/dashboard/product-sales", nil).Body.Bytes(), &overview)
+	o := overview.Data
+	if o.TotalRevenue != 100 || o.TotalCost != 50 || o.GrossProfit != 50 || o.GrossMargin != 50 || len(o.TopProducts) != 1 || o.TopProducts[0].GrossProfit != 50 {
+		t.Fatalf("unexpected product sales overview: %+v", o)
+	}
+
+	var stats struct {
+		Data struct {
+			TotalCostValue float64 `json:"total_cost_value"`
+			CostOfGoods    float64 `json:"cost_of_goods"`
+			GrossMargin    float64 `json:"gross_margin"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", "/api/products/stats", nil).Body.Bytes(), &stats)
+	if stats.Data.TotalCostValue != 720 || stats.Data.CostOfGoods != 50 || stats.Data.GrossMargin != 50 {
+		t.Fatalf("unexpected product stats: %+v", stats.Data)
+	}
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+	"server/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ParentProductRequest 创建/修改父商品的请求体，修改后同步到所有规格
+type ParentProductRequest struct {
+	Name        string `json:"name" binding:"required,max=128"`
+	Description string `json:"description" binding:"max=500"`
+	ImageURL    string `json:"image_url" binding:"max=255"`
+	CategoryID  *uint  `json:"category_id"`
+}
+
+var (
+	errUnknownParent      = errors.New("parent product not found")
+	errVariantNameMissing = errors.New("variant_name is required for a variant")
+	errDuplicateVariant   = errors.New("parent product already has a variant with this name")
+	errInvalidOptions     = errors.New("options must be a JSON object of string values")
+)
+
+// variantDisplayName 规格的展示名称：父商品名称 + 规格名称
+func variantDisplayName(parentName, variantName string) string {
+	return strings.TrimSpace(parentName + " " + variantName)
+}
+
+// applyParentCatalog 规格的名称、分类、描述、图片取自父商品
+func applyParentCatalog(product *models.PhysicalProduct, parent *models.ParentProduct) {
+	product.Name = variantDisplayName(parent.Name, product.VariantName)
+	product.CategoryID = parent.CategoryID
+	product.Description = parent.Description
+	product.ImageURL = parent.ImageURL
+}
+
+// normalizeVariantOptions 校验规格属性为字符串键值对，空值存为 NULL
+func normalizeVariantOptions(options datatypes.JSON) (datatypes.JSON, error) {
+	if len(options) == 0 || string(options) == "null" {
+		return nil, nil
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		return nil, errInvalidOptions
+	}
+	normalized, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(normalized), nil
+}
+
+// attachToParent 将商品设为父商品的规格：校验父商品存在、规格名称在父商品下唯一，并同步目录信息
+func attachToParent(tx *gorm.DB, product *models.PhysicalProduct) error {
+	if product.ParentID == nil {
+		return nil
+	}
+	product.VariantName = strings.TrimSpace(product.VariantName)
+	if product.VariantName == "" {
+		return errVariantNameMissing
+	}
+	var parent models.ParentProduct
+	if err := tx.First(&parent, *product.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUnknownParent
+		}
+		return err
+	}
+	var count int64
+	if err := tx.Model(&models.PhysicalProduct{}).
+		Where("parent_id = ? AND variant_name = ? AND id <> ?", parent.ID, product.VariantName, product.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errDuplicateVariant
+	}
+	applyParentCatalog(product, &parent)
+	return nil
+}
+
+// writeParentError 将父商品/规格相关错误转换为对应的 HTTP 响应
+func writeParentError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errUnknownParent), errors.Is(err, errVariantNameMissing), errors.Is(err, errInvalidOptions):
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+	case errors.Is(err, errDuplicateVariant):
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+	default:
+		writeCatalogError(c, err, fallback)
+	}
+}
+
+// ParentStockSummary 父商品下各规格的库存汇总
+type ParentStockSummary struct {
+	VariantCount int     `json:"variant_count"`
+	TotalStock   int     `json:"total_stock"`
+	StockValue   float64 `json:"stock_value"` // 库存成本（按移动加权平均成本）
+	MinPrice     float64 `json:"min_price"`
+	MaxPrice     float64 `json:"max_price"`
+}
+
+// summarizeVariants 汇总规格的库存与价格区间
+func summarizeVariants(variants []models.PhysicalProduct) ParentStockSummary {
+	summary := ParentStockSummary{VariantCount: len(variants)}
+	var valueCents int64
+	for i, v := range variants {
+		summary.TotalStock += v.Stock
+		valueCents += util.ToCents(v.CostPrice) * int64(v.Stock)
+		if i == 0 || v.RetailPrice < summary.MinPrice {
+			summary.MinPrice = v.RetailPrice
+		}
+		if v.RetailPrice > summary.MaxPrice {
+			summary.MaxPrice = v.RetailPrice
+		}
+	}
+	summary.StockValue = util.CentsToYuan(valueCents)
+	return summary
+}
+
+// parentWithSummary 父商品及其规格汇总
+type parentWithSummary struct {
+	models.ParentProduct
+	Summary ParentStockSummary `json:"summary"`
+}
+
+// preloadVariants 预加载规格，按ID排序
+func preloadVariants(q *gorm.DB) *gorm.DB {
+	return q.Order("id ASC")
+}
+
+// ListParentProducts 查询父商品及其规格
+// GET /api/product-parents?q=oil&category_id=1
+func ListParentProducts(c *gin.Context) {
+	query := db.DB.Model(&models.ParentProduct{})
+	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
+		categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid category_id", nil))
+			return
+		}
+		query = query.Where("category_id = ?", uint(categoryID))
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		query = query.Where("name LIKE ?", "%"+keyword+"%")
+	}
+
+	parents := make([]models.ParentProduct, 0)
+	if err := query.Preload("Category").Preload("Variants", preloadVariants).
+		Order("name ASC, id ASC").Find(&parents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch parent products", err.Error()))
+		return
+	}
+
+	result := make([]parentWithSummary, 0, len(parents))
+	for _, p := range parents {
+		result = append(result, parentWithSummary{ParentProduct: p, Summary: summarizeVariants(p.Variants)})
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"parents": result,
+		"total":   len(result),
+	}, ""))
+}
+
+// VariantSales 单个规格的销售汇总
+type VariantSales struct {
+	ProductID    uint    `json:"product_id"`
+	VariantName  string  `json:"variant_name"`
+	SalesCount   int64   `json:"sales_count"` // 订单数
+	Quantity     int64   `json:"quantity"`    // 销售件数
+	TotalRevenue float64 `json:"total_revenue"`
+	TotalCost    float64 `json:"total_cost"`
+	GrossProfit  float64 `json:"gross_profit"`
+	GrossMargin  float64 `json:"gross_margin"`
+}
+
+// GetParentProduct 查询父商品详情：各规格库存、库存汇总，以及按规格拆分并汇总到父商品的销售
+// GET /api/product-parents/:id?start=2026-01-01&end=2026-02-01
+func GetParentProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid parent product ID", nil))
+		return
+	}
+	var parent models.ParentProduct
+	if err := db.DB.Preload("Category").Preload("Variants", preloadVariants).First(&parent, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Parent product not found", nil))
+		return
+	}
+
+	salesQuery := db.DB.Model(&models.Order{}).
+		Joins("JOIN inventory_logs ON inventory_logs.id = orders.inventory_log_id").
+		Joins("JOIN physical_products ON physical_products.id = inventory_logs.product_id").
+		Where("orders.order_type = ? AND orders.refunded_at IS NULL AND physical_products.parent_id = ?", "physical", parent.ID)
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+	if !start.IsZero() {
+		salesQuery = salesQuery.Where("orders.created_at >= ?", start)
+	}
+	if !end.IsZero() {
+		salesQuery = salesQuery.Where("orders.created_at < ?", end)
+	}
+	variantSales := make([]VariantSales, 0)
+	if err := salesQuery.
+		Select("physical_products.id as product_id, physical_products.variant_name as variant_name, COUNT(orders.id) as sales_count, " +
+			"COALESCE(SUM(-inventory_logs.change_amount), 0) as quantity, COALESCE(SUM(orders.paid_amount), 0) as total_revenue, " +
+			"COALESCE(SUM(" + costExpr + "), 0) as total_cost").
+		Group("physical_products.id, physical_products.variant_name").
+		Order("total_revenue DESC").
+		Scan(&variantSales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize variant sales", err.Error()))
+		return
+	}
+
+	var total VariantSales
+	for i := range variantSales {
+		v := &variantSales[i]
+		v.TotalRevenue = util.RoundMoney(v.TotalRevenue)
+		v.TotalCost = util.RoundMoney(v.TotalCost)
+		v.GrossProfit, v.GrossMargin = inventory.GrossMargin(v.TotalRevenue, v.TotalCost)
+		total.SalesCount += v.SalesCount
+		total.Quantity += v.Quantity
+		total.TotalRevenue += v.TotalRevenue
+		total.TotalCost += v.TotalCost
+	}
+	total.TotalRevenue = util.RoundMoney(total.TotalRevenue)
+	total.TotalCost = util.RoundMoney(total.TotalCost)
+	total.GrossProfit, total.GrossMargin = inventory.GrossMargin(total.TotalRevenue, total.TotalCost)
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"parent":        parent,
+		"summary":       summarizeVariants(parent.Variants),
+		"variant_sales": variantSales,
+		"sales": gin.H{
+			"sales_count":   total.SalesCount,
+			"quantity":      total.Quantity,
+			"total_revenue": total.TotalRevenue,
+			"total_cost":    total.TotalCost,
+			"gross_profit":  total.GrossProfit,
+			"gross_margin":  total.GrossMargin,
+		},
+	}, ""))
+}
+
+// CreateParentProduct 创建父商品，之后通过创建/修改商品时指定 parent_id 添加规格
+// POST /api/product-parents
+func CreateParentProduct(c *gin.Context) {
+	var req ParentProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	parent := models.ParentProduct{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		ImageURL:    req.ImageURL,
+		CategoryID:  req.CategoryID,
+	}
+	if err := validateProductCatalog(db.DB, &models.PhysicalProduct{CategoryID: parent.CategoryID}); err != nil {
+		writeCatalogError(c, err, "Failed to validate parent product")
+		return
+	}
+	if err := db.DB.Create(&parent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create parent product", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(parent, "Parent product created successfully"))
+}
+
+// UpdateParentProduct 修改父商品的目录信息，并同步到所有规格的名称、分类、描述和图片
+// PUT /api/product-parents/:id
+func UpdateParentProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid parent product ID", nil))
+		return
+	}
+	var req ParentProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var parent models.ParentProduct
+	if err := db.DB.First(&parent, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Parent product not found", nil))
+		return
+	}
+	parent.Name = strings.TrimSpace(req.Name)
+	parent.Description = req.Description
+	parent.ImageURL = req.ImageURL
+	parent.CategoryID = req.CategoryID
+	if err := validateProductCatalog(db.DB, &models.PhysicalProduct{CategoryID: parent.CategoryID}); err != nil {
+		writeCatalogError(c, err, "Failed to validate parent product")
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&parent).Error; err != nil {
+			return err
+		}
+		var variants []models.PhysicalProduct
+		if err := tx.Where("parent_id = ?", parent.ID).Find(&variants).Error; err != nil {
+			return err
+		}
+		for i := range variants {
+			applyParentCatalog(&variants[i], &parent)
+			if err := tx.Model(&variants[i]).Select("name", "category_id", "description", "image_url").
+				Updates(&variants[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update parent product", err.Error()))
+		return
+	}
+
+	db.DB.Preload("Category").Preload("Variants", preloadVariants).First(&parent, parent.ID)
+	c.JSON(http.StatusOK, response.Success(parent, "Parent product updated successfully"))
+}
+
+// DeleteParentProduct 删除没有规格的父商品
+// DELETE /api/product-parents/:id
+func DeleteParentProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid parent product ID", nil))
+		return
+	}
+	var parent models.ParentProduct
+	if err := db.DB.First(&parent, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Parent product not found", nil))
+		return
+	}
+	var variants int64
+	if err := db.DB.Model(&models.PhysicalProduct{}).Where("parent_id = ?", parent.ID).Count(&variants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete parent product", err.Error()))
+		return
+	}
+	if variants > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Parent product still has variants", gin.H{"variant_count": variants}))
+		return
+	}
+	if err := db.DB.Delete(&parent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete parent product", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Parent product deleted successfully"))
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParentProducts_VariantsStockAndSalesRollUp(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-variants", PasswordHash: "x", Role: "manager", IsActive: true}
+	testDB.Create(&operator)
+	member := models.Member{Name: "Ivy", Phone: "13800000501", InvitationCode: "M0501", IsActive: true}
+	testDB.Create(&member)
+	category := models.ProductCategory{Name: "Essential Oils"}
+	testDB.Create(&category)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.GET("/api/products", ListProducts)
+	router.POST("/api/products", CreateProduct)
+	router.PUT("/api/products/:id", UpdateProduct)
+	router.POST("/api/inventory/change", CreateInventoryChange)
+	router.GET("/api/product-parents", ListParentProducts)
+	router.GET("/api/product-parents/:id", GetParentProduct)
+	router.POST("/api/product-parents", CreateParentProduct)
+	router.PUT("/api/product-parents/:id", UpdateParentProduct)
+	router.DELETE("/api/product-parents/:id", DeleteParentProduct)
+	router.DELETE("/api/product-categories/:id", DeleteProductCategory)
+	router.GET("/api/dashboard/product-sales", NewDashboardHandler(testDB).GetProductSalesOverview)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type productResp struct {
+		Data models.PhysicalProduct `json:"data"`
+	}
+
+	var parent struct {
+		Data models.ParentProduct `json:"data"`
+	}
+	w := send("POST", "/api/product-parents", gin.H{"name": "Lavender Oil", "description": "Pure lavender", "category_id": category.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create parent: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &parent)
+
+	// 规格的名称、分类、描述取自父商品，库存、价格、SKU 在规格上
+	var small, large productResp
+	w = send("POST", "/api/products", gin.H{"parent_id": parent.Data.ID, "variant_name": "10ml", "options": gin.H{"size": "10ml"},
+		"stock": 10, "retail_price": 50, "cost_price": 20, "is_active": true, "sku": "LAV-10"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create variant: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &small)
+	if small.Data.Name != "Lavender Oil 10ml" || small.Data.CategoryID == nil || *small.Data.CategoryID != category.ID ||
+		small.Data.Description != "Pure lavender" {
+		t.Fatalf("expected catalog info from parent, got %+v", small.Data)
+	}
+	w = send("POST", "/api/products", gin.H{"parent_id": parent.Data.ID, "variant_name": "30ml",
+		"stock": 5, "retail_price": 120, "cost_price": 45, "is_active": true, "sku": "LAV-30"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create variant: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &large)
+
+	if w := send("POST", "/api/products", gin.H{"parent_id": parent.Data.ID, "variant_name": "10ml", "stock": 1, "retail_price": 1, "cost_price": 1}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate variant name, got %d", w.Code)
+	}
+	if w := send("POST", "/api/products", gin.H{"parent_id": parent.Data.ID, "stock": 1, "retail_price": 1, "cost_price": 1}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without variant_name, got %d", w.Code)
+	}
+	if w := send("POST", "/api/products", gin.H{"parent_id": 9999, "variant_name": "x", "stock": 1, "retail_price": 1, "cost_price": 1}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown parent, got %d", w.Code)
+	}
+	if w := send("POST", "/api/products", gin.H{"stock": 1, "retail_price": 1, "cost_price": 1}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for product without name or parent, got %d", w.Code)
+	}
+	if w := send("POST", "/api/products", gin.H{"parent_id": parent.Data.ID, "variant_name": "50ml", "options": []string{"bad"},
+		"stock": 1, "retail_price": 1, "cost_price": 1}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-object options, got %d", w.Code)
+	}
+
+	// 规格各自销售，库存日志与订单记录在规格上
+	for _, sale := range []struct {
+		productID uint
+		quantity  int
+	}{{small.Data.ID, 2}, {small.Data.ID, 1}, {large.Data.ID, 1}} {
+		w := send("POST", "/api/inventory/change", gin.H{"product_id": sale.productID, "change_amount": -sale.quantity, "action_type": "sale", "member_id": member.ID})
+		if w.Code != http.StatusOK {
+			t.Fatalf("sale: expected 200, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	var detail struct {
+		Data struct {
+			Parent       models.ParentProduct `json:"parent"`
+			Summary      ParentStockSummary   `json:"summary"`
+			VariantSales []VariantSales       `json:"variant_sales"`
+			Sales        VariantSales         `json:"sales"`
+		} `json:"data"`
+	}
+	w = send("GET", fmt.Sprintf("/api/product-parents/%d", parent.Data.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get parent: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	if len(detail.Data.Parent.Variants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(detail.Data.Parent.Variants))
+	}
+	if s := detail.Data.Summary; s.VariantCount != 2 || s.TotalStock != 11 || s.MinPrice != 50 || s.MaxPrice != 120 {
+		t.Fatalf("unexpected stock summary: %+v", s)
+	}
+	if len(detail.Data.VariantSales) != 2 {
+		t.Fatalf("expected sales for 2 variants, got %+v", detail.Data.VariantSales)
+	}
+	// 10ml：3 件 × 50 = 150，成本 60；30ml：1 件 × 120 = 120，成本 45
+	if s := detail.Data.Sales; s.SalesCount != 3 || s.Quantity != 4 || s.TotalRevenue != 270 || s.TotalCost != 105 || s.GrossProfit != 165 {
+		t.Fatalf("unexpected parent sales roll-up: %+v", s)
+	}
+
+	var overview struct {
+		Data struct {
+			TopParents []struct {
+				ParentID     uint    `json:"parent_id"`
+				SalesCount   int64   `json:"sales_count"`
+				TotalRevenue float64 `json:"total_revenue"`
+			} `json:"topParents"`
+		} `json:"data"`
+	}
+	w = send("GET", "/api/dashboard/product-sales", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("product sales: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &overview)
+	if len(overview.Data.TopParents) != 1 || overview.Data.TopParents[0].ParentID != parent.Data.ID ||
+		overview.Data.TopParents[0].SalesCount != 3 || overview.Data.TopParents[0].TotalRevenue != 270 {
+		t.Fatalf("unexpected parent roll-up on dashboard: %+v", overview.Data.TopParents)
+	}
+
+	// 修改父商品同步到所有规格
+	if w := send("PUT", fmt.Sprintf("/api/product-parents/%d", parent.Data.ID), gin.H{"name": "Lavender Essential Oil", "category_id": category.ID}); w.Code != http.StatusOK {
+		t.Fatalf("update parent: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var refreshed models.PhysicalProduct
+	testDB.First(&refreshed, large.Data.ID)
+	if refreshed.Name != "Lavender Essential Oil 30ml" || refreshed.Description != "" || refreshed.Stock != 4 {
+		t.Fatalf("expected parent changes on variant without touching stock, got %+v", refreshed)
+	}
+
+	var variants struct {
+		Data struct {
+			Total int64 `json:"total"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", fmt.Sprintf("/api/products?parent_id=%d", parent.Data.ID), nil).Body.Bytes(), &variants)
+	if variants.Data.Total != 2 {
+		t.Fatalf("expected 2 variants by parent_id filter, got %d", variants.Data.Total)
+	}
+
+	if w := send("DELETE", fmt.Sprintf("/api/product-categories/%d", category.ID), nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting category used by variants, got %d", w.Code)
+	}
+	if w := send("DELETE", fmt.Sprintf("/api/product-parents/%d", parent.Data.ID), nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting parent with variants, got %d", w.Code)
+	}
+
+	// 规格转为独立商品后可删除父商品
+	for _, id := range []uint{small.Data.ID, large.Data.ID} {
+		w := send("PUT", fmt.Sprintf("/api/products/%d", id), gin.H{"parent_id": 0, "name": fmt.Sprintf("Standalone %d", id)})
+		if w.Code != http.StatusOK {
+			t.Fatalf("detach variant: expected 200, got %d body=%s", w.Code, w.Body.String())
+		}
+		var detached productResp
+		json.Unmarshal(w.Body.Bytes(), &detached)
+		if detached.Data.ParentID != nil || detached.Data.VariantName != "" {
+			t.Fatalf("expected detached product, got %+v", detached.Data)
+		}
+	}
+	if w := send("DELETE", fmt.Sprintf("/api/product-parents/%d", parent.Data.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("delete parent: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+}
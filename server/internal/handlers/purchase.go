@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SupplierRequest 创建/修改供应商的请求体
+type SupplierRequest struct {
+	Name        string `json:"name" binding:"required,max=128"`
+	ContactName string `json:"contact_name" binding:"max=64"`
+	Phone       string `json:"phone" binding:"max=32"`
+	Email       string `json:"email" binding:"omitempty,email,max=128"`
+	Address     string `json:"address" binding:"max=255"`
+	Note        string `json:"note" binding:"max=255"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// PurchaseOrderLineRequest 采购单明细
+type PurchaseOrderLineRequest struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitCost  float64 `json:"unit_cost" binding:"gte=0"`
+}
+
+// PurchaseOrderRequest 创建/修改采购单的请求体
+type PurchaseOrderRequest struct {
+	SupplierID uint                       `json:"supplier_id" binding:"required"`
+	ExpectedAt *time.Time                 `json:"expected_at"`
+	Remark     string                     `json:"remark" binding:"max=255"`
+	Lines      []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// ReceivePurchaseOrderRequest 采购到货的请求体
+type ReceivePurchaseOrderRequest struct {
+	Items  []inventory.Receipt `json:"items" binding:"required,min=1,dive"`
+	Remark string              `json:"remark" binding:"max=200"`
+}
+
+// writePurchaseError 将采购流程中的错误转换为对应的 HTTP 响应
+func writePurchaseError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Purchase order not found", nil))
+	case errors.Is(err, inventory.ErrUnknownPurchaseLine), errors.Is(err, inventory.ErrOverReceipt),
+		errors.Is(err, errUnknownSupplier), errors.Is(err, errInactiveSupplier), errors.Is(err, errUnknownProduct):
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+	case errors.Is(err, inventory.ErrPurchaseOrderState):
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, fallback, err.Error()))
+	}
+}
+
+// ListSuppliers 查询供应商列表
+// GET /api/suppliers?is_active=true
+func ListSuppliers(c *gin.Context) {
+	query := db.DB.Order("name ASC")
+	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
+		query = query.Where("is_active = ?", isActiveStr == "true")
+	}
+	suppliers := make([]models.Supplier, 0)
+	if err := query.Find(&suppliers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch suppliers", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"suppliers": suppliers,
+		"total":     len(suppliers),
+	}, ""))
+}
+
+// CreateSupplier 创建供应商
+func CreateSupplier(c *gin.Context) {
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	supplier := models.Supplier{
+		Name:        req.Name,
+		ContactName: req.ContactName,
+		Phone:       req.Phone,
+		Email:       req.Email,
+		Address:     req.Address,
+		Note:        req.Note,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	// is_active 带默认值，为 false 时 Create 会写入默认值，需在同一事务中显式更新
+	active := supplier.IsActive
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&supplier).Error; err != nil {
+			return err
+		}
+		if !active {
+			supplier.IsActive = false
+			return tx.Model(&supplier).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Failed to create supplier", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(supplier, "Supplier created successfully"))
+}
+
+// UpdateSupplier 修改供应商信息
+func UpdateSupplier(c *gin.Context) {
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Supplier not found", nil))
+		return
+	}
+	supplier.Name = req.Name
+	supplier.ContactName = req.ContactName
+	supplier.Phone = req.Phone
+	supplier.Email = req.Email
+	supplier.Address = req.Address
+	supplier.Note = req.Note
+	if req.IsActive != nil {
+		supplier.IsActive = *req.IsActive
+	}
+	if err := db.DB.Save(&supplier).Error; err != nil {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Failed to update supplier", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(supplier, "Supplier updated successfully"))
+}
+
+// DeleteSupplier 删除供应商；仍有未完结采购单时拒绝删除
+func DeleteSupplier(c *gin.Context) {
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Supplier not found", nil))
+		return
+	}
+	var open int64
+	if err := db.DB.Model(&models.PurchaseOrder{}).
+		Where("supplier_id = ? AND status IN ?", supplier.ID, []string{inventory.PurchaseDraft, inventory.PurchaseOrdered, inventory.PurchasePartiallyReceived}).
+		Count(&open).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to check purchase orders", err.Error()))
+		return
+	}
+	if open > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Supplier has open purchase orders", gin.H{"open_orders": open}))
+		return
+	}
+	if err := db.DB.Delete(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete supplier", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Supplier deleted successfully"))
+}
+
+var (
+	errUnknownSupplier  = errors.New("supplier not found")
+	errInactiveSupplier = errors.New("supplier is inactive")
+	errUnknownProduct   = errors.New("product not found")
+)
+
+// buildPurchaseLines 校验供应商可用、商品存在，并构造采购明细
+func buildPurchaseLines(tx *gorm.DB, req PurchaseOrderRequest) ([]models.PurchaseOrderLine, error) {
+	var supplier models.Supplier
+	if err := tx.First(&supplier, req.SupplierID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUnknownSupplier
+		}
+		return nil, err
+	}
+	if !supplier.IsActive {
+		return nil, errInactiveSupplier
+	}
+
+	lines := make([]models.PurchaseOrderLine, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		var product models.PhysicalProduct
+		if err := tx.Select("id").First(&product, l.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errUnknownProduct
+			}
+			return nil, err
+		}
+		lines = append(lines, models.PurchaseOrderLine{ProductID: l.ProductID, Quantity: l.Quantity, UnitCost: l.UnitCost})
+	}
+	return lines, nil
+}
+
+// loadPurchaseOrder 加载采购单及其供应商、明细和商品
+func loadPurchaseOrder(tx *gorm.DB, id interface{}) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := tx.Preload("Supplier").Preload("Lines", func(q *gorm.DB) *gorm.DB {
+		return q.Order("id ASC")
+	}).Preload("Lines.Product").First(&po, id).Error; err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+// ListPurchaseOrders 分页查询采购单
+// GET /api/purchase-orders?status=ordered&supplier_id=1&page=1&page_size=20
+func ListPurchaseOrders(c *gin.Context) {
+	query := db.DB.Model(&models.PurchaseOrder{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierIDStr := c.Query("supplier_id"); supplierIDStr != "" {
+		supplierID, err := strconv.ParseUint(supplierIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid supplier_id", nil))
+			return
+		}
+		query = query.Where("supplier_id = ?", uint(supplierID))
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count purchase orders", err.Error()))
+		return
+	}
+	orders := make([]models.PurchaseOrder, 0)
+	if err := query.Preload("Supplier").Preload("Lines").
+		Order("created_at DESC, id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch purchase orders", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"purchase_orders": orders,
+		"total":           total,
+		"page":            page,
+		"page_size":       pageSize,
+	}, ""))
+}
+
+// GetPurchaseOrder 查询采购单详情
+func GetPurchaseOrder(c *gin.Context) {
+	po, err := loadPurchaseOrder(db.DB, c.Param("id"))
+	if err != nil {
+		writePurchaseError(c, err, "Failed to fetch purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(po, ""))
+}
+
+// CreatePurchaseOrder 创建草稿采购单
+func CreatePurchaseOrder(c *gin.Context) {
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var po models.PurchaseOrder
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		lines, err := buildPurchaseLines(tx, req)
+		if err != nil {
+			return err
+		}
+		po = models.PurchaseOrder{
+			SupplierID:  req.SupplierID,
+			Status:      inventory.PurchaseDraft,
+			ExpectedAt:  req.ExpectedAt,
+			Remark:      req.Remark,
+			TotalAmount: inventory.PurchaseTotal(lines),
+			CreatedBy:   currentOperatorID(c),
+			Lines:       lines,
+		}
+		if err := tx.Create(&po).Error; err != nil {
+			return err
+		}
+		po.OrderNo = inventory.PurchaseOrderNo(&po)
+		return tx.Model(&po).Update("order_no", po.OrderNo).Error
+	})
+	if err != nil {
+		writePurchaseError(c, err, "Failed to create purchase order")
+		return
+	}
+
+	created, err := loadPurchaseOrder(db.DB, po.ID)
+	if err != nil {
+		writePurchaseError(c, err, "Failed to fetch purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(created, "Purchase order created successfully"))
+}
+
+// UpdatePurchaseOrder 修改草稿采购单，明细整体替换
+func UpdatePurchaseOrder(c *gin.Context) {
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var po models.PurchaseOrder
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&po, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if po.Status != inventory.PurchaseDraft {
+			return inventory.ErrPurchaseOrderState
+		}
+		lines, err := buildPurchaseLines(tx, req)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", po.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].PurchaseOrderID = po.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		return tx.Model(&po).Updates(map[string]interface{}{
+			"supplier_id":  req.SupplierID,
+			"expected_at":  req.ExpectedAt,
+			"remark":       req.Remark,
+			"total_amount": inventory.PurchaseTotal(lines),
+		}).Error
+	})
+	if err != nil {
+		writePurchaseError(c, err, "Failed to update purchase order")
+		return
+	}
+
+	updated, err := loadPurchaseOrder(db.DB, po.ID)
+	if err != nil {
+		writePurchaseError(c, err, "Failed to fetch purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(updated, "Purchase order updated successfully"))
+}
+
+// purchaseOrderID 解析路径中的采购单ID
+func purchaseOrderID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid purchase order ID", nil))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// SubmitPurchaseOrder 向供应商下单：草稿 -> 已下单
+// POST /api/purchase-orders/:id/submit
+func SubmitPurchaseOrder(c *gin.Context) {
+	id, ok := purchaseOrderID(c)
+	if !ok {
+		return
+	}
+	err := inventory.TransitionPurchaseOrder(db.DB, id, []string{inventory.PurchaseDraft},
+		map[string]interface{}{"status": inventory.PurchaseOrdered, "ordered_at": time.Now()})
+	if err != nil {
+		writePurchaseError(c, err, "Failed to submit purchase order")
+		return
+	}
+	po, err := loadPurchaseOrder(db.DB, id)
+	if err != nil {
+		writePurchaseError(c, err, "Failed to fetch purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(po, "Purchase order submitted"))
+}
+
+// CancelPurchaseOrder 取消尚未到货的采购单；已部分到货的采购单不可取消
+// POST /api/purchase-orders/:id/cancel
+func CancelPurchaseOrder(c *gin.Context) {
+	id, ok := purchaseOrderID(c)
+	if !ok {
+		return
+	}
+	err := inventory.TransitionPurchaseOrder(db.DB, id, []string{inventory.PurchaseDraft, inventory.PurchaseOrdered},
+		map[string]interface{}{"status": inventory.PurchaseCancelled})
+	if err != nil {
+		writePurchaseError(c, err, "Failed to cancel purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Purchase order cancelled"))
+}
+
+// ReceivePurchaseOrder 登记采购到货，按明细增加库存并生成入库日志
+// POST /api/purchase-orders/:id/receive
+func ReceivePurchaseOrder(c *gin.Context) {
+	id, ok := purchaseOrderID(c)
+	if !ok {
+		return
+	}
+	var req ReceivePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid request: "+err.Error(), nil))
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.Error(http.StatusUnauthorized, "User not authenticated", nil))
+		return
+	}
+
+	var logs []models.InventoryLog
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		logs, err = inventory.ReceivePurchaseOrder(tx, id, req.Items, userID.(uint), req.Remark, time.Now())
+		return err
+	})
+	if err != nil {
+		writePurchaseError(c, err, "Failed to receive purchase order")
+		return
+	}
+
+	po, err := loadPurchaseOrder(db.DB, id)
+	if err != nil {
+		writePurchaseError(c, err, "Failed to fetch purchase order")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"purchase_order": po,
+		"logs":           logs,
+	}, "Goods received successfully"))
+}
+
+// GetOnOrderQuantities 各商品已下单未到货的数量
+// GET /api/purchase-orders/on-order?product_id=1
+func GetOnOrderQuantities(c *gin.Context) {
+	var productIDs []uint
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		productID, err := strconv.ParseUint(productIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid product_id", nil))
+			return
+		}
+		productIDs = append(productIDs, uint(productID))
+	}
+	onOrder, err := inventory.OnOrder(db.DB, productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize on-order quantities", err.Error()))
+		return
+	}
+
+	type onOrderItem struct {
+		ProductID uint `json:"product_id"`
+		Quantity  int  `json:"quantity"`
+	}
+	items := make([]onOrderItem, 0, len(onOrder))
+	for productID, quantity := range onOrder {
+		if quantity > 0 {
+			items = append(items, onOrderItem{ProductID: productID, Quantity: quantity})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+	c.JSON(http.StatusOK, response.Success(gin.H{"items": items}, ""))
+}
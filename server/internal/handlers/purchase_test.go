@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPurchaseOrder_CreateSubmitReceive(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "buyer", PasswordHash: "x", Role: "manager", IsActive: true}
+	product := models.PhysicalProduct{Name: "Oil", Stock: 1, RetailPrice: 50, CostPrice: 15, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/suppliers", CreateSupplier)
+	router.DELETE("/api/suppliers/:id", DeleteSupplier)
+	router.POST("/api/purchase-orders", CreatePurchaseOrder)
+	router.PUT("/api/purchase-orders/:id", UpdatePurchaseOrder)
+	router.POST("/api/purchase-orders/:id/submit", SubmitPurchaseOrder)
+	router.POST("/api/purchase-orders/:id/receive", ReceivePurchaseOrder)
+	router.GET("/api/purchase-orders/on-order", GetOnOrderQuantities)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/suppliers", gin.H{"name": "Acme", "contact_name": "Zhang"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 creating supplier, got %d body=%s", w.Code, w.Body.String())
+	}
+	var supplier struct {
+		Data models.Supplier `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &supplier)
+
+	// 停用状态创建的供应商须以停用入库
+	var inactive struct {
+		Data models.Supplier `json:"data"`
+	}
+	json.Unmarshal(send("POST", "/api/suppliers", gin.H{"name": "Dormant", "is_active": false}).Body.Bytes(), &inactive)
+	var storedInactive models.Supplier
+	testDB.First(&storedInactive, inactive.Data.ID)
+	if storedInactive.IsActive || inactive.Data.IsActive {
+		t.Fatalf("expected supplier created inactive, got stored=%v response=%v", storedInactive.IsActive, inactive.Data.IsActive)
+	}
+
+	if w := send("POST", "/api/purchase-orders", gin.H{"supplier_id": supplier.Data.ID, "lines": []gin.H{{"product_id": product.ID + 100, "quantity": 1, "unit_cost": 1}}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown product, got %d", w.Code)
+	}
+	w = send("POST", "/api/purchase-orders", gin.H{"supplier_id": supplier.Data.ID, "lines": []gin.H{{"product_id": product.ID, "quantity": 10, "unit_cost": 16.5}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 creating purchase order, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data models.PurchaseOrder `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	po := created.Data
+	if po.OrderNo == "" || po.Status != "draft" || po.TotalAmount != 165 || len(po.Lines) != 1 {
+		t.Fatalf("unexpected purchase order: %+v", po)
+	}
+
+	poPath := "/api/purchase-orders/" + strconvUint(po.ID)
+	w = send("PUT", poPath, gin.H{"supplier_id": supplier.Data.ID, "lines": []gin.H{{"product_id": product.ID, "quantity": 12, "unit_cost": 16}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 updating draft, got %d body=%s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	line := created.Data.Lines[0]
+	if created.Data.TotalAmount != 192 || line.Quantity != 12 {
+		t.Fatalf("unexpected updated purchase order: %+v", created.Data)
+	}
+
+	if w := send("POST", poPath+"/receive", gin.H{"items": []gin.H{{"line_id": line.ID, "quantity": 1}}}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 receiving a draft, got %d", w.Code)
+	}
+	if w := send("POST", poPath+"/submit", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 submitting, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("PUT", poPath, gin.H{"supplier_id": supplier.Data.ID, "lines": []gin.H{{"product_id": product.ID, "quantity": 1, "unit_cost": 1}}}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 editing an ordered purchase order, got %d", w.Code)
+	}
+	if w := send("DELETE", "/api/suppliers/"+strconvUint(supplier.Data.ID), nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting supplier with open orders, got %d", w.Code)
+	}
+
+	w = send("POST", poPath+"/receive", gin.H{"items": []gin.H{{"line_id": line.ID, "quantity": 7}}, "remark": "carton 1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 receiving goods, got %d body=%s", w.Code, w.Body.String())
+	}
+	var inventoryLog models.InventoryLog
+	testDB.Where("purchase_order_line_id = ?", line.ID).First(&inventoryLog)
+	if inventoryLog.ChangeAmount != 7 || inventoryLog.AfterStock != 8 || inventoryLog.OperatorID != operator.ID {
+		t.Fatalf("unexpected restock log: %+v", inventoryLog)
+	}
+
+	w = send("GET", "/api/purchase-orders/on-order", nil)
+	var onOrder struct {
+		Data struct {
+			Items []struct {
+				ProductID uint `json:"product_id"`
+				Quantity  int  `json:"quantity"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &onOrder)
+	if len(onOrder.Data.Items) != 1 || onOrder.Data.Items[0].Quantity != 5 {
+		t.Fatalf("expected 5 units still on order, got %+v", onOrder.Data.Items)
+	}
+}
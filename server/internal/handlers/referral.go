@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"server/internal/db"
+	"server/internal/models"
+	"server/internal/referral"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ReferralProgramRequest 修改推荐分佣方案的请求体
+type ReferralProgramRequest struct {
+	ServiceRates []float64 `json:"service_rates" binding:"required,max=5,dive,gte=0,lte=1"`
+	ProductRates []float64 `json:"product_rates" binding:"required,max=5,dive,gte=0,lte=1"`
+	MaxPerOrder  float64   `json:"max_per_order" binding:"gte=0"`
+	MaxPerPeriod float64   `json:"max_per_period" binding:"gte=0"`
+	PeriodDays   int       `json:"period_days" binding:"gte=0,lte=366"`
+}
+
+var errRatesTooHigh = errors.New("sum of referral rates must not exceed 1")
+
+// validate 校验各级比例合计不超过 100%，且周期上限需指定统计天数
+func (req ReferralProgramRequest) validate() error {
+	for _, rates := range [][]float64{req.ServiceRates, req.ProductRates} {
+		var sum float64
+		for _, r := range rates {
+			sum += r
+		}
+		if sum > 1 {
+			return errRatesTooHigh
+		}
+	}
+	if req.MaxPerPeriod > 0 && req.PeriodDays <= 0 {
+		return errors.New("period_days is required when max_per_period is set")
+	}
+	return nil
+}
+
+// GetReferralProgram 获取当前推荐分佣方案（未配置时返回默认方案）
+func GetReferralProgram(c *gin.Context) {
+	program, err := referral.Program(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch referral program", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(program, ""))
+}
+
+// UpdateReferralProgram 修改推荐分佣方案，仅对之后结算的订单生效
+func UpdateReferralProgram(c *gin.Context) {
+	var req ReferralProgramRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	serviceRates, _ := json.Marshal(req.ServiceRates)
+	productRates, _ := json.Marshal(req.ProductRates)
+
+	var program models.ReferralProgram
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Order("id ASC").First(&program).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		program.ServiceRates = datatypes.JSON(serviceRates)
+		program.ProductRates = datatypes.JSON(productRates)
+		program.MaxPerOrder = req.MaxPerOrder
+		program.MaxPerPeriod = req.MaxPerPeriod
+		program.PeriodDays = req.PeriodDays
+		return tx.Save(&program).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update referral program", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(program, "Referral program updated successfully"))
+}
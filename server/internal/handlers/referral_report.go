@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"server/internal/models"
+	"server/internal/referral"
+	"server/internal/response"
+	"server/pkg/util"
+)
+
+// ReferralNode 推荐树中的一个会员节点
+type ReferralNode struct {
+	ID               uint            `json:"id"`
+	Name             string          `json:"name"`
+	Phone            string          `json:"phone"`
+	Level            string          `json:"level"`
+	IsActive         bool            `json:"is_active"`
+	JoinedAt         time.Time       `json:"joined_at"`
+	Depth            int             `json:"depth"`              // 相对根会员的推荐层级，根为 0
+	Spend            float64         `json:"spend"`              // 本人实付消费（不含退款订单）
+	CommissionToRoot float64         `json:"commission_to_root"` // 本人订单为根会员产生的佣金（不含已撤销）
+	BranchSize       int             `json:"branch_size"`        // 本分支下级会员数（不含本人）
+	BranchSpend      float64         `json:"branch_spend"`       // 本分支（含本人）实付消费合计
+	BranchCommission float64         `json:"branch_commission"`  // 本分支（含本人）为根会员产生的佣金合计
+	Children         []*ReferralNode `json:"children"`
+}
+
+// GetReferralTree returns a member's referral tree with spend and commission per branch
+// GET /api/members/:id/referral-tree?depth=3
+func (h *DashboardHandler) GetReferralTree(c *gin.Context) {
+	depth, _ := strconv.Atoi(c.DefaultQuery("depth", "3"))
+	if depth < 1 || depth > 10 {
+		depth = 3
+	}
+
+	var root models.Member
+	if err := h.db.First(&root, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	newNode := func(m models.Member, d int) *ReferralNode {
+		return &ReferralNode{ID: m.ID, Name: m.Name, Phone: m.Phone, Level: m.Level, IsActive: m.IsActive, JoinedAt: m.CreatedAt, Depth: d, Children: []*ReferralNode{}}
+	}
+
+	// 逐层加载下级会员；nodes 兼作已访问集合，防止推荐关系成环
+	rootNode := newNode(root, 0)
+	nodes := map[uint]*ReferralNode{root.ID: rootNode}
+	ids := []uint{root.ID}
+	frontier := []uint{root.ID}
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		var invitees []models.Member
+		if err := h.db.Where("referrer_id IN ?", frontier).Order("created_at ASC").Find(&invitees).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to load referral tree", err.Error()))
+			return
+		}
+		frontier = frontier[:0]
+		for _, m := range invitees {
+			if _, seen := nodes[m.ID]; seen {
+				continue
+			}
+			node := newNode(m, d)
+			nodes[m.ID] = node
+			nodes[*m.ReferrerID].Children = append(nodes[*m.ReferrerID].Children, node)
+			ids = append(ids, m.ID)
+			frontier = append(frontier, m.ID)
+		}
+	}
+
+	type amountRow struct {
+		MemberID uint
+		Amount   float64
+	}
+	var spends []amountRow
+	if err := h.db.Model(&models.Order{}).
+		Select("member_id, COALESCE(SUM(paid_amount), 0) as amount").
+		Where("member_id IN ? AND refunded_at IS NULL", ids).
+		Group("member_id").Scan(&spends).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize spend", err.Error()))
+		return
+	}
+	for _, r := range spends {
+		nodes[r.MemberID].Spend = r.Amount
+	}
+	var commissions []amountRow
+	if err := h.db.Model(&models.FissionLog{}).
+		Select("invitee_id as member_id, COALESCE(SUM(commission_amount), 0) as amount").
+		Where("inviter_id = ? AND invitee_id IN ? AND status <> ?", root.ID, ids, referral.CommissionReversed).
+		Group("invitee_id").Scan(&commissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to summarize commission", err.Error()))
+		return
+	}
+	for _, r := range commissions {
+		nodes[r.MemberID].CommissionToRoot = r.Amount
+	}
+
+	var rollUp func(n *ReferralNode)
+	rollUp = func(n *ReferralNode) {
+		n.BranchSpend = n.Spend
+		n.BranchCommission = n.CommissionToRoot
+		for _, child := range n.Children {
+			rollUp(child)
+			n.BranchSize += child.BranchSize + 1
+			n.BranchSpend += child.BranchSpend
+			n.BranchCommission += child.BranchCommission
+		}
+		n.BranchSpend = util.RoundMoney(n.BranchSpend)
+		n.BranchCommission = util.RoundMoney(n.BranchCommission)
+	}
+	rollUp(rootNode)
+
+	c.JSON(http.StatusOK, response.Success(rootNode, ""))
+}
+
+// GetReferralAttribution compares revenue from referred members against organic members
+// 订单发生时会员已绑定推荐人才计为推荐带来的收入；退款订单不计入
+// GET /api/dashboard/referral-attribution?start=2026-01-01&end=2026-01-31
+func (h *DashboardHandler) GetReferralAttribution(c *gin.Context) {
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+
+	type channelStats struct {
+		Revenue    float64 `json:"revenue"`
+		OrderCount int64   `json:"order_count"`
+		BuyerCount int64   `json:"buyer_count"`
+		NewMembers int64   `json:"new_members"`
+	}
+	referredCond := "members.referrer_id IS NOT NULL AND (members.referral_bound_at IS NULL OR members.referral_bound_at <= orders.created_at)"
+
+	ordersQuery := func(cond string) *gorm.DB {
+		q := h.db.Model(&models.Order{}).
+			Joins("JOIN members ON members.id = orders.member_id").
+			Where("orders.refunded_at IS NULL").
+			Where(cond)
+		if !start.IsZero() {
+			q = q.Where("orders.created_at >= ?", start)
+		}
+		if !end.IsZero() {
+			q = q.Where("orders.created_at < ?", end)
+		}
+		return q
+	}
+	membersQuery := func() *gorm.DB {
+		q := h.db.Model(&models.Member{})
+		if !start.IsZero() {
+			q = q.Where("created_at >= ?", start)
+		}
+		if !end.IsZero() {
+			q = q.Where("created_at < ?", end)
+		}
+		return q
+	}
+
+	var referred, organic channelStats
+	for _, item := range []struct {
+		stats *channelStats
+		cond  string
+	}{
+		{&referred, referredCond},
+		{&organic, "NOT (" + referredCond + ")"},
+	} {
+		if err := ordersQuery(item.cond).
+			Select("COALESCE(SUM(orders.paid_amount), 0) as revenue, COUNT(*) as order_count, COUNT(DISTINCT orders.member_id) as buyer_count").
+			Scan(item.stats).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to query referral attribution", err.Error()))
+			return
+		}
+	}
+	if err := membersQuery().Where("referrer_id IS NOT NULL").Count(&referred.NewMembers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count new members", err.Error()))
+		return
+	}
+	if err := membersQuery().Where("referrer_id IS NULL").Count(&organic.NewMembers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count new members", err.Error()))
+		return
+	}
+
+	// 佣金成本：区间内产生且未撤销的佣金
+	commissionQuery := h.db.Model(&models.FissionLog{}).Where("status <> ?", referral.CommissionReversed)
+	if !start.IsZero() {
+		commissionQuery = commissionQuery.Where("created_at >= ?", start)
+	}
+	if !end.IsZero() {
+		commissionQuery = commissionQuery.Where("created_at < ?", end)
+	}
+	var commissionCost float64
+	if err := commissionQuery.Select("COALESCE(SUM(commission_amount), 0)").Scan(&commissionCost).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to sum commission", err.Error()))
+		return
+	}
+
+	referralShare := 0.0
+	if total := referred.Revenue + organic.Revenue; total > 0 {
+		referralShare = util.RoundMoney(referred.Revenue / total * 100)
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"referred":        referred,
+		"organic":         organic,
+		"commission_cost": util.RoundMoney(commissionCost),
+		"referral_share":  referralShare, // 推荐收入占比（%）
+	}, ""))
+}
+
+// ListFissionLogs audits commission records with filters and pagination
+// GET /api/fission/logs?inviter_id=1&status=paid&start=2026-01-01&end=2026-01-31&page=1&page_size=20
+func (h *DashboardHandler) ListFissionLogs(c *gin.Context) {
+	query := h.db.Model(&models.FissionLog{}).
+		Joins("LEFT JOIN members AS inviters ON inviters.id = fission_logs.inviter_id").
+		Joins("LEFT JOIN members AS invitees ON invitees.id = fission_logs.invitee_id")
+
+	for param, column := range map[string]string{
+		"inviter_id": "fission_logs.inviter_id",
+		"invitee_id": "fission_logs.invitee_id",
+		"order_id":   "fission_logs.order_id",
+		"level":      "fission_logs.level",
+	} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid "+param, nil))
+				return
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("fission_logs.status = ?", status)
+	}
+	start, end := parseTimeRange(c.Query("start"), c.Query("end"))
+	if !start.IsZero() {
+		query = query.Where("fission_logs.created_at >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("fission_logs.created_at < ?", end)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var summary struct {
+		Total       int64   `json:"total"`
+		TotalAmount float64 `json:"total_amount"`
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("COUNT(*) as total, COALESCE(SUM(fission_logs.commission_amount), 0) as total_amount").
+		Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count fission logs", err.Error()))
+		return
+	}
+
+	type fissionLogRow struct {
+		models.FissionLog
+		InviterName string `json:"inviter_name"`
+		InviteeName string `json:"invitee_name"`
+	}
+	logs := make([]fissionLogRow, 0)
+	if err := query.Session(&gorm.Session{}).
+		Select("fission_logs.*, inviters.name as inviter_name, invitees.name as invitee_name").
+		Order("fission_logs.created_at DESC, fission_logs.id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Scan(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch fission logs", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"logs":         logs,
+		"total":        summary.Total,
+		"total_amount": util.RoundMoney(summary.TotalAmount),
+		"page":         page,
+		"page_size":    pageSize,
+	}, ""))
+}
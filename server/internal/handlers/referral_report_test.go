@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReferralReports_TreeAttributionAndAudit(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	h := NewDashboardHandler(testDB)
+
+	// A 推荐 B、D；B 推荐 C；E 为自然到店会员
+	root := models.Member{Name: "A", Phone: "13800000081", InvitationCode: "R0081"}
+	testDB.Create(&root)
+	b := models.Member{Name: "B", Phone: "13800000082", InvitationCode: "R0082", ReferrerID: &root.ID}
+	d := models.Member{Name: "D", Phone: "13800000084", InvitationCode: "R0084", ReferrerID: &root.ID}
+	testDB.Create(&b)
+	testDB.Create(&d)
+	cm := models.Member{Name: "C", Phone: "13800000083", InvitationCode: "R0083", ReferrerID: &b.ID}
+	organicMember := models.Member{Name: "E", Phone: "13800000085", InvitationCode: "R0085"}
+	testDB.Create(&cm)
+	testDB.Create(&organicMember)
+
+	seq := uint(0)
+	createPaidOrder := func(memberID uint, amount float64) models.Order {
+		seq++
+		id := seq
+		order := models.Order{MemberID: memberID, PaidAmount: amount, OrderType: "service", AppointmentID: &id}
+		testDB.Create(&order)
+		return order
+	}
+	ob := createPaidOrder(b.ID, 100)
+	oc := createPaidOrder(cm.ID, 200)
+	createPaidOrder(organicMember.ID, 300)
+	refunded := createPaidOrder(d.ID, 400)
+	now := time.Now()
+	testDB.Model(&models.Order{}).Where("id = ?", refunded.ID).Update("refunded_at", now)
+
+	testDB.Create(&models.FissionLog{InviterID: root.ID, InviteeID: b.ID, OrderID: &ob.ID, Level: 1, CommissionAmount: 10, Status: "paid"})
+	testDB.Create(&models.FissionLog{InviterID: b.ID, InviteeID: cm.ID, OrderID: &oc.ID, Level: 1, CommissionAmount: 20, Status: "pending"})
+	testDB.Create(&models.FissionLog{InviterID: root.ID, InviteeID: cm.ID, OrderID: &oc.ID, Level: 2, CommissionAmount: 8, Status: "pending"})
+	testDB.Create(&models.FissionLog{InviterID: root.ID, InviteeID: d.ID, OrderID: &refunded.ID, Level: 1, CommissionAmount: 40, Status: "reversed"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/members/:id/referral-tree", h.GetReferralTree)
+	router.GET("/api/dashboard/referral-attribution", h.GetReferralAttribution)
+	router.GET("/api/fission/logs", h.ListFissionLogs)
+	router.GET("/api/fission/ranking", h.GetFissionRanking)
+
+	get := func(path string, out interface{}) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d, body=%s", path, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+	}
+
+	var tree struct {
+		Data ReferralNode `json:"data"`
+	}
+	get("/api/members/"+strconvUint(root.ID)+"/referral-tree", &tree)
+	if tree.Data.BranchSize != 3 || tree.Data.BranchSpend != 300 || tree.Data.BranchCommission != 18 {
+		t.Fatalf("unexpected root totals: size=%d spend=%v commission=%v", tree.Data.BranchSize, tree.Data.BranchSpend, tree.Data.BranchCommission)
+	}
+	if len(tree.Data.Children) != 2 || tree.Data.Children[0].ID != b.ID {
+		t.Fatalf("expected B and D as direct children, got %+v", tree.Data.Children)
+	}
+	branchB := tree.Data.Children[0]
+	if branchB.BranchSpend != 300 || branchB.BranchCommission != 18 || len(branchB.Children) != 1 || branchB.Children[0].Depth != 2 {
+		t.Fatalf("unexpected branch B: %+v", branchB)
+	}
+
+	var attribution struct {
+		Data struct {
+			Referred struct {
+				Revenue    float64 `json:"revenue"`
+				OrderCount int64   `json:"order_count"`
+			} `json:"referred"`
+			Organic struct {
+				Revenue float64 `json:"revenue"`
+			} `json:"organic"`
+			CommissionCost float64 `json:"commission_cost"`
+			ReferralShare  float64 `json:"referral_share"`
+		} `json:"data"`
+	}
+	get("/api/dashboard/referral-attribution", &attribution)
+	a := attribution.Data
+	if a.Referred.Revenue != 300 || a.Referred.OrderCount != 2 || a.Organic.Revenue != 300 || a.CommissionCost != 38 || a.ReferralShare != 50 {
+		t.Fatalf("unexpected attribution: %+v", a)
+	}
+
+	var audit struct {
+		Data struct {
+			Logs []struct {
+				InviteeName string `json:"invitee_name"`
+			} `json:"logs"`
+			Total       int64   `json:"total"`
+			TotalAmount float64 `json:"total_amount"`
+		} `json:"data"`
+	}
+	get("/api/fission/logs?inviter_id="+strconvUint(root.ID)+"&page_size=2", &audit)
+	if audit.Data.Total != 3 || audit.Data.TotalAmount != 58 || len(audit.Data.Logs) != 2 {
+		t.Fatalf("unexpected audit page: %+v", audit.Data)
+	}
+	get("/api/fission/logs?inviter_id="+strconvUint(root.ID)+"&status=reversed", &audit)
+	if audit.Data.Total != 1 || audit.Data.Logs[0].InviteeName != "D" {
+		t.Fatalf("expected one reversed log for D, got %+v", audit.Data)
+	}
+
+	var ranking struct {
+		Data []struct {
+			ID              uint    `json:"id"`
+			InviteCount     int64   `json:"inviteCount"`
+			TotalCommission float64 `json:"totalCommission"`
+		} `json:"data"`
+	}
+	get("/api/fission/ranking", &ranking)
+	if len(ranking.Data) != 2 || ranking.Data[0].ID != root.ID || ranking.Data[0].InviteCount != 2 || ranking.Data[0].TotalCommission != 18 {
+		t.Fatalf("unexpected ranking: %+v", ranking.Data)
+	}
+}
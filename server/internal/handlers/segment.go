@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/internal/db"
+	"server/internal/membership"
+	"server/internal/models"
+	"server/internal/response"
+	"server/internal/segment"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var errSegmentNotFound = errors.New("segment not found")
+
+// loadSegmentFilter 读取已保存分群的筛选条件
+func loadSegmentFilter(database *gorm.DB, id string) (*models.SavedSegment, *segment.Filter, error) {
+	var saved models.SavedSegment
+	if err := database.First(&saved, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errSegmentNotFound
+		}
+		return nil, nil, err
+	}
+	filter, err := segment.Parse(saved.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &saved, filter, nil
+}
+
+// segmentErrorStatus 将分群相关错误映射为 HTTP 状态码
+func segmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errSegmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, segment.ErrInvalidFilter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListTags 获取全部标签及使用人数
+func ListTags(c *gin.Context) {
+	type tagWithCount struct {
+		models.Tag
+		MemberCount int64 `json:"member_count"`
+	}
+	tags := make([]tagWithCount, 0)
+	if err := db.DB.Model(&models.Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM member_tags mt WHERE mt.tag_id = tags.id) as member_count").
+		Order("tags.name ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch tags", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(tags, ""))
+}
+
+// CreateTag 新建标签
+func CreateTag(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name" binding:"required,max=64"`
+		Color string `json:"color" binding:"max=16"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	tag := models.Tag{Name: strings.TrimSpace(req.Name), Color: req.Color}
+	var count int64
+	if err := db.DB.Unscoped().Model(&models.Tag{}).Where("name = ?", tag.Name).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to validate tag", err.Error()))
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Tag already exists", nil))
+		return
+	}
+	if err := db.DB.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to create tag", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(tag, "Tag created successfully"))
+}
+
+// DeleteTag 删除标签并解除与会员的关联
+func DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := db.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Tag not found", nil))
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM member_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		// 物理删除，便于之后重新创建同名标签
+		return tx.Unscoped().Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete tag", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Tag deleted successfully"))
+}
+
+// findOrCreateTags 按名称查找标签，不存在的自动创建
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		var tag models.Tag
+		if err := tx.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// SetMemberTags 设置会员的标签（整体替换，不存在的标签自动创建）
+func SetMemberTags(c *gin.Context) {
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var member models.Member
+	if err := db.DB.First(&member, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Member not found", nil))
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := findOrCreateTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&member).Association("Tags").Replace(tags)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update member tags", err.Error()))
+		return
+	}
+
+	db.DB.Preload("Tags").First(&member, member.ID)
+	c.JSON(http.StatusOK, response.Success(member, "Member tags updated successfully"))
+}
+
+// SegmentRequest 新增/修改分群的请求体
+type SegmentRequest struct {
+	Name        string          `json:"name" binding:"required,max=64"`
+	Description string          `json:"description" binding:"max=255"`
+	Filter      json.RawMessage `json:"filter" binding:"required"`
+}
+
+// ListSegments 获取已保存的分群（含当前会员数）
+func ListSegments(c *gin.Context) {
+	var saved []models.SavedSegment
+	if err := db.DB.Order("name ASC").Find(&saved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch segments", err.Error()))
+		return
+	}
+
+	type segmentWithCount struct {
+		models.SavedSegment
+		MemberCount int64 `json:"member_count"`
+	}
+	now := time.Now()
+	result := make([]segmentWithCount, 0, len(saved))
+	for _, s := range saved {
+		item := segmentWithCount{SavedSegment: s}
+		filter, err := segment.Parse(s.Filter)
+		if err != nil {
+			c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), fmt.Sprintf("Invalid filter in segment %q", s.Name), err.Error()))
+			return
+		}
+		sql, args, err := segment.Compile(filter, now)
+		if err != nil {
+			c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), fmt.Sprintf("Invalid filter in segment %q", s.Name), err.Error()))
+			return
+		}
+		if err := db.DB.Model(&models.Member{}).Where(sql, args...).Count(&item.MemberCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count segment members", err.Error()))
+			return
+		}
+		result = append(result, item)
+	}
+	c.JSON(http.StatusOK, response.Success(result, ""))
+}
+
+// CreateSegment 保存分群
+func CreateSegment(c *gin.Context) {
+	var req SegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if _, err := segment.Parse(req.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	saved := models.SavedSegment{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Filter:      datatypes.JSON(req.Filter),
+		CreatedBy:   currentOperatorID(c),
+	}
+	if err := db.DB.Create(&saved).Error; err != nil {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Failed to create segment", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(saved, "Segment created successfully"))
+}
+
+// UpdateSegment 修改分群名称或筛选条件
+func UpdateSegment(c *gin.Context) {
+	var req SegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if _, err := segment.Parse(req.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var saved models.SavedSegment
+	if err := db.DB.First(&saved, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Segment not found", nil))
+		return
+	}
+	saved.Name = strings.TrimSpace(req.Name)
+	saved.Description = req.Description
+	saved.Filter = datatypes.JSON(req.Filter)
+	if err := db.DB.Save(&saved).Error; err != nil {
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, "Failed to update segment", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(saved, "Segment updated successfully"))
+}
+
+// DeleteSegment 删除分群
+func DeleteSegment(c *gin.Context) {
+	if err := db.DB.Unscoped().Delete(&models.SavedSegment{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to delete segment", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Segment deleted successfully"))
+}
+
+// PreviewSegment 试算筛选条件命中的会员数量（不保存）
+func PreviewSegment(c *gin.Context) {
+	var req struct {
+		Filter json.RawMessage `json:"filter" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	filter, err := segment.Parse(req.Filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	sql, args, err := segment.Compile(filter, time.Now())
+	if err != nil {
+		c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), err.Error(), nil))
+		return
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.Member{}).Where(sql, args...).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to evaluate segment", err.Error()))
+		return
+	}
+	sample := make([]models.Member, 0)
+	if err := db.DB.Where(sql, args...).Order("members.created_at DESC").Limit(10).Find(&sample).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to evaluate segment", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{"member_count": count, "sample": sample}, ""))
+}
+
+// SegmentActionRequest 分群批量操作的请求体
+type SegmentActionRequest struct {
+	Action    string  `json:"action" binding:"required,oneof=add_tag remove_tag gift_balance grant_points issue_coupon"`
+	Tag       string  `json:"tag"`        // add_tag/remove_tag
+	Amount    float64 `json:"amount"`     // gift_balance/issue_coupon（单位：元）
+	Points    int     `json:"points"`     // grant_points
+	Title     string  `json:"title"`      // issue_coupon
+	MinSpend  float64 `json:"min_spend"`  // issue_coupon
+	ValidDays int     `json:"valid_days"` // issue_coupon
+	Remark    string  `json:"remark"`
+}
+
+// validate 校验不同批量操作所需的参数
+func (req SegmentActionRequest) validate() error {
+	switch req.Action {
+	case "add_tag", "remove_tag":
+		if strings.TrimSpace(req.Tag) == "" {
+			return fmt.Errorf("tag is required")
+		}
+	case "gift_balance":
+		if req.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+	case "grant_points":
+		if req.Points <= 0 {
+			return fmt.Errorf("points must be positive")
+		}
+	case "issue_coupon":
+		if req.Amount <= 0 || req.ValidDays <= 0 || strings.TrimSpace(req.Title) == "" {
+			return fmt.Errorf("title, amount and valid_days are required")
+		}
+	}
+	return nil
+}
+
+// RunSegmentAction 对分群内的全部有效会员执行批量操作（打标签、赠送余额/积分、发优惠券），并记录操作日志
+func RunSegmentAction(c *gin.Context) {
+	var req SegmentActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	saved, filter, err := loadSegmentFilter(db.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), "Failed to load segment", err.Error()))
+		return
+	}
+
+	now := time.Now()
+	sql, args, err := segment.Compile(filter, now)
+	if err != nil {
+		c.JSON(segmentErrorStatus(err), response.Error(segmentErrorStatus(err), "Failed to compile segment filter", err.Error()))
+		return
+	}
+
+	var actionLog models.SegmentActionLog
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var members []models.Member
+		if err := tx.Where(sql, args...).Where("members.is_active = ?", true).Find(&members).Error; err != nil {
+			return err
+		}
+
+		var tag models.Tag
+		if req.Action == "add_tag" || req.Action == "remove_tag" {
+			tags, err := findOrCreateTags(tx, []string{req.Tag})
+			if err != nil {
+				return err
+			}
+			tag = tags[0]
+		}
+
+		for i := range members {
+			member := &members[i]
+			switch req.Action {
+			case "add_tag":
+				if err := tx.Model(member).Association("Tags").Append(&tag); err != nil {
+					return err
+				}
+			case "remove_tag":
+				if err := tx.Model(member).Association("Tags").Delete(&tag); err != nil {
+					return err
+				}
+			case "gift_balance":
+				if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).
+					Update("balance", gorm.Expr("balance + ?", req.Amount)).Error; err != nil {
+					return err
+				}
+			case "grant_points":
+				remark := req.Remark
+				if remark == "" {
+					remark = "分群活动赠送：" + saved.Name
+				}
+				if _, err := membership.GrantPoints(tx, member, req.Points, remark, now); err != nil {
+					return err
+				}
+			case "issue_coupon":
+				if _, err := membership.IssueCoupon(tx, member.ID, req.Title, req.Amount, req.MinSpend, now.AddDate(0, 0, req.ValidDays)); err != nil {
+					return err
+				}
+			}
+		}
+
+		params, _ := json.Marshal(req)
+		actionLog = models.SegmentActionLog{
+			SegmentID:   saved.ID,
+			Action:      req.Action,
+			Params:      datatypes.JSON(params),
+			MemberCount: len(members),
+			OperatorID:  currentOperatorID(c),
+		}
+		return tx.Create(&actionLog).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to run segment action", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(actionLog, "Segment action completed"))
+}
+
+// ListSegmentActionLogs 获取分群批量操作记录
+func ListSegmentActionLogs(c *gin.Context) {
+	logs := make([]models.SegmentActionLog, 0)
+	if err := db.DB.Where("segment_id = ?", c.Param("id")).Order("created_at DESC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch segment action logs", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(logs, ""))
+}
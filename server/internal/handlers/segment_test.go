@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+func TestSegments_FilterMembersAndRunAction(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-seg", PasswordHash: "x", Role: "operator", IsActive: true}
+	product := models.PhysicalProduct{Name: "Serum", Stock: 10, RetailPrice: 100, CostPrice: 40, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&product)
+
+	members := []models.Member{
+		{Name: "Recent gold", Phone: "13800000061", InvitationCode: "S0061", Level: "gold", IsActive: true},
+		{Name: "Old gold", Phone: "13800000062", InvitationCode: "S0062", Level: "gold", IsActive: true},
+		{Name: "Recent silver", Phone: "13800000063", InvitationCode: "S0063", Level: "silver", IsActive: true},
+	}
+	for i := range members {
+		testDB.Create(&members[i])
+	}
+
+	// 三人都买过该商品，但 Old gold 的购买在 90 天之外
+	now := time.Now()
+	boughtAt := []time.Time{now.AddDate(0, 0, -10), now.AddDate(0, 0, -120), now.AddDate(0, 0, -5)}
+	for i := range members {
+		amount := 100.0
+		invLog := models.InventoryLog{ProductID: product.ID, OperatorID: operator.ID, MemberID: &members[i].ID, ChangeAmount: -1, ActionType: "sale", BeforeStock: 10, AfterStock: 9, SaleAmount: &amount}
+		testDB.Create(&invLog)
+		order := models.Order{MemberID: members[i].ID, PaidAmount: 100, OrderType: "physical", InventoryLogID: &invLog.ID}
+		order.CreatedAt = boughtAt[i]
+		testDB.Create(&order)
+	}
+
+	filter := fmt.Sprintf(`{"all": [
+		{"field": "level", "op": "eq", "value": "gold"},
+		{"field": "purchased_product", "op": "eq", "value": %d, "within_days": 90}
+	]}`, product.ID)
+	saved := models.SavedSegment{Name: "近期购买精华的金卡", Filter: datatypes.JSON(filter)}
+	if err := testDB.Create(&saved).Error; err != nil {
+		t.Fatalf("create segment: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/members", ListMembers)
+	router.POST("/api/segments/:id/actions", func(c *gin.Context) {
+		c.Set("user_id", operator.ID)
+		RunSegmentAction(c)
+	})
+
+	listIDs := func(query string) []uint {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/api/members?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list members %s: expected 200, got %d, body=%s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data struct {
+				Members []models.Member `json:"members"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		ids := make([]uint, 0, len(resp.Data.Members))
+		for _, m := range resp.Data.Members {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	if ids := listIDs("segment_id=" + strconvUint(saved.ID)); len(ids) != 1 || ids[0] != members[0].ID {
+		t.Fatalf("expected only recent gold member in segment, got %v", ids)
+	}
+
+	// 已退款订单不计入订单类条件：Recent silver 的订单退款后不再属于近期消费会员
+	refundedAt := now.Add(-time.Hour)
+	testDB.Model(&models.Order{}).Where("member_id = ?", members[2].ID).Update("refunded_at", refundedAt)
+	recent := models.SavedSegment{Name: "近期消费会员", Filter: datatypes.JSON(`{"field": "order_count", "op": "gte", "value": 1, "within_days": 90}`)}
+	if err := testDB.Create(&recent).Error; err != nil {
+		t.Fatalf("create segment: %v", err)
+	}
+	if ids := listIDs("segment_id=" + strconvUint(recent.ID)); len(ids) != 1 || ids[0] != members[0].ID {
+		t.Fatalf("expected refunded order to be excluded from order_count, got %v", ids)
+	}
+
+	body, _ := json.Marshal(gin.H{"action": "add_tag", "tag": "精华复购"})
+	req, _ := http.NewRequest("POST", "/api/segments/"+strconvUint(saved.ID)+"/actions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", w.Code, w.Body.String())
+	}
+
+	if ids := listIDs("tag=精华复购"); len(ids) != 1 || ids[0] != members[0].ID {
+		t.Fatalf("expected tag applied to recent gold member only, got %v", ids)
+	}
+
+	var logEntry models.SegmentActionLog
+	if err := testDB.Where("segment_id = ?", saved.ID).First(&logEntry).Error; err != nil {
+		t.Fatalf("expected action log: %v", err)
+	}
+	if logEntry.MemberCount != 1 || logEntry.Action != "add_tag" || logEntry.OperatorID == nil {
+		t.Fatalf("unexpected action log: %+v", logEntry)
+	}
+
+	body, _ = json.Marshal(gin.H{"action": "grant_points"})
+	req, _ = http.NewRequest("POST", "/api/segments/"+strconvUint(saved.ID)+"/actions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for grant_points without points, got %d", w.Code)
+	}
+}
+
+func TestSegments_StoredFilterThatNoLongerCompilesIsRejected(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	testDB.Create(&models.Member{Name: "M", Phone: "10000000369", InvitationCode: "code-10000000369", IsActive: true})
+	broken := models.SavedSegment{Name: "broken", Filter: datatypes.JSON(`{"field":"retired_field","op":"eq","value":1}`)}
+	testDB.Create(&broken)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/segments", ListSegments)
+	router.POST("/api/segments/:id/actions", RunSegmentAction)
+	router.GET("/api/dashboard/marketing", NewDashboardHandler(testDB).GetMarketingMetrics)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("GET", "/api/segments", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("list: expected 400 for a segment that no longer compiles, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("POST", "/api/segments/"+strconvUint(broken.ID)+"/actions", gin.H{"action": "gift_balance", "amount": 10}); w.Code != http.StatusBadRequest {
+		t.Fatalf("action: expected 400, got %d body=%s", w.Code, w.Body.String())
+	}
+	var member models.Member
+	testDB.First(&member)
+	if member.Balance != 0 {
+		t.Fatalf("broken segment must not touch members, balance=%v", member.Balance)
+	}
+	if w := send("GET", "/api/dashboard/marketing?segment_id="+strconvUint(broken.ID), nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("marketing: expected 400, got %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errDuplicateMaterial 同一耗材在物料清单中重复出现
+var errDuplicateMaterial = errors.New("duplicate product in materials")
+
+// ServiceMaterialRequest 物料清单中的一种耗材
+type ServiceMaterialRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// UpdateServiceMaterialsRequest 整体替换服务物料清单的请求体，materials 为空表示清空
+type UpdateServiceMaterialsRequest struct {
+	Materials []ServiceMaterialRequest `json:"materials" binding:"dive"`
+}
+
+// loadServiceMaterials 查询服务的物料清单并按当前成本估算单次耗材成本
+func loadServiceMaterials(tx *gorm.DB, serviceID uint) (gin.H, error) {
+	materials := make([]models.ServiceMaterial, 0)
+	if err := tx.Preload("Product").Where("service_id = ?", serviceID).Order("id ASC").Find(&materials).Error; err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"service_id":    serviceID,
+		"materials":     materials,
+		"material_cost": inventory.MaterialCost(materials),
+	}, nil
+}
+
+// serviceIDParam 解析路径中的服务项目ID并确认服务存在
+func serviceIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid service ID", nil))
+		return 0, false
+	}
+	var count int64
+	if err := db.DB.Model(&models.ServiceProduct{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch service item", err.Error()))
+		return 0, false
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Service item not found", nil))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetServiceMaterials 查询服务项目的耗材物料清单
+// GET /api/services/:id/materials
+func GetServiceMaterials(c *gin.Context) {
+	id, ok := serviceIDParam(c)
+	if !ok {
+		return
+	}
+	result, err := loadServiceMaterials(db.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch service materials", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(result, ""))
+}
+
+// UpdateServiceMaterials 整体替换服务项目的物料清单，之后结算的预约按新清单扣减耗材
+// PUT /api/services/:id/materials
+func UpdateServiceMaterials(c *gin.Context) {
+	id, ok := serviceIDParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateServiceMaterialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		materials := make([]models.ServiceMaterial, 0, len(req.Materials))
+		seen := make(map[uint]bool, len(req.Materials))
+		for _, m := range req.Materials {
+			if seen[m.ProductID] {
+				return errDuplicateMaterial
+			}
+			seen[m.ProductID] = true
+			var count int64
+			if err := tx.Model(&models.PhysicalProduct{}).Where("id = ?", m.ProductID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return errUnknownProduct
+			}
+			materials = append(materials, models.ServiceMaterial{ServiceID: id, ProductID: m.ProductID, Quantity: m.Quantity})
+		}
+		// 物料清单整体替换；物理删除旧明细，避免软删除记录占用唯一索引
+		if err := tx.Unscoped().Where("service_id = ?", id).Delete(&models.ServiceMaterial{}).Error; err != nil {
+			return err
+		}
+		if len(materials) == 0 {
+			return nil
+		}
+		return tx.Create(&materials).Error
+	})
+	if err != nil {
+		if errors.Is(err, errUnknownProduct) || errors.Is(err, errDuplicateMaterial) {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to update service materials", err.Error()))
+		return
+	}
+
+	result, err := loadServiceMaterials(db.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch service materials", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(result, "Service materials updated successfully"))
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServiceMaterials_ConsumedWhenAppointmentCompletes(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-bom", PasswordHash: "x", Role: "manager", IsActive: true}
+	member := models.Member{Name: "Carol", Phone: "13800000481", InvitationCode: "M0481", IsActive: true}
+	tech := models.Technician{Name: "Dan", Status: 0}
+	service := models.ServiceProduct{Name: "Aroma Massage", Duration: 60, Price: 200}
+	oil := models.PhysicalProduct{Name: "Massage Oil", Stock: 5, RetailPrice: 80, CostPrice: 6.5, ReorderPoint: 3, IsActive: true}
+	towel := models.PhysicalProduct{Name: "Towel", Stock: 1, RetailPrice: 10, CostPrice: 2, ReorderPoint: 0, IsActive: true}
+	for _, v := range []interface{}{&operator, &member, &tech, &service, &oil, &towel} {
+		testDB.Create(v)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.GET("/api/services/:id/materials", GetServiceMaterials)
+	router.PUT("/api/services/:id/materials", UpdateServiceMaterials)
+	router.POST("/api/appointments/:id/complete", CompleteAppointment)
+	router.GET("/api/dashboard/service-ranking", NewDashboardHandler(testDB).GetServiceRanking)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	newAppointment := func() models.Appointment {
+		appt := models.Appointment{MemberID: member.ID, TechID: tech.ID, ServiceID: service.ID,
+			StartTime: time.Now().Add(-2 * time.Hour), EndTime: time.Now().Add(-time.Hour),
+			Status: "pending", OriginPrice: 200, ActualPrice: 200}
+		testDB.Create(&appt)
+		return appt
+	}
+	materialsPath := fmt.Sprintf("/api/services/%d/materials", service.ID)
+
+	if w := send("PUT", materialsPath, gin.H{"materials": []gin.H{{"product_id": oil.ID, "quantity": 1}, {"product_id": oil.ID, "quantity": 2}}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for duplicate material, got %d", w.Code)
+	}
+	if w := send("PUT", materialsPath, gin.H{"materials": []gin.H{{"product_id": 9999, "quantity": 1}}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown product, got %d", w.Code)
+	}
+	if w := send("PUT", "/api/services/9999/materials", gin.H{"materials": []gin.H{}}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown service, got %d", w.Code)
+	}
+	// 先设置一次再整体替换，确认旧清单被替换而不是追加
+	send("PUT", materialsPath, gin.H{"materials": []gin.H{{"product_id": towel.ID, "quantity": 3}}})
+	w := send("PUT", materialsPath, gin.H{"materials": []gin.H{{"product_id": oil.ID, "quantity": 2}, {"product_id": towel.ID, "quantity": 1}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update materials: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var bom struct {
+		Data struct {
+			Materials    []models.ServiceMaterial `json:"materials"`
+			MaterialCost float64                  `json:"material_cost"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", materialsPath, nil).Body.Bytes(), &bom)
+	if len(bom.Data.Materials) != 2 || bom.Data.MaterialCost != 15 {
+		t.Fatalf("expected 2 materials costing 15, got %+v", bom.Data)
+	}
+
+	appt := newAppointment()
+	w = send("POST", fmt.Sprintf("/api/appointments/%d/complete", appt.ID), gin.H{"payment_method": "cash", "cash_amount": 200})
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	var logs []models.InventoryLog
+	testDB.Where("appointment_id = ?", appt.ID).Order("id ASC").Find(&logs)
+	if len(logs) != 2 || logs[0].ActionType != "consumption" || logs[0].ChangeAmount != -2 || logs[0].CostAmount == nil || *logs[0].CostAmount != 13 {
+		t.Fatalf("unexpected consumption logs: %+v", logs)
+	}
+	var order models.Order
+	testDB.Where("appointment_id = ?", appt.ID).First(&order)
+	if order.CostAmount == nil || *order.CostAmount != 15 {
+		t.Fatalf("expected service order cost 15, got %+v", order.CostAmount)
+	}
+	var savedOil models.PhysicalProduct
+	testDB.First(&savedOil, oil.ID)
+	if savedOil.Stock != 3 {
+		t.Fatalf("expected oil stock 3, got %d", savedOil.Stock)
+	}
+	var alerts int64
+	testDB.Model(&models.StockAlert{}).Where("product_id = ?", oil.ID).Count(&alerts)
+	if alerts != 1 {
+		t.Fatalf("expected consumption to raise a reorder alert, got %d", alerts)
+	}
+
+	var ranking struct {
+		Data []struct {
+			TotalRevenue float64 `json:"total_revenue"`
+			TotalCost    float64 `json:"total_cost"`
+			GrossProfit  float64 `json:"gross_profit"`
+		} `json:"data"`
+	}
+	json.Unmarshal(send("GET", "/api/dashboard/service-ranking", nil).Body.Bytes(), &ranking)
+	if len(ranking.Data) != 1 || ranking.Data[0].TotalCost != 15 || ranking.Data[0].GrossProfit != 185 {
+		t.Fatalf("unexpected service ranking: %+v", ranking.Data)
+	}
+
+	// 毛巾账面库存已用完：结算不受影响，精油照常扣减，毛巾记为短缺并保持补货提醒
+	second := newAppointment()
+	w = send("POST", fmt.Sprintf("/api/appointments/%d/complete", second.ID), gin.H{"payment_method": "cash", "cash_amount": 200})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when materials are short, got %d body=%s", w.Code, w.Body.String())
+	}
+	var settled struct {
+		Data struct {
+			MaterialShortfalls []struct {
+				ProductID uint `json:"product_id"`
+				Required  int  `json:"required"`
+				Deducted  int  `json:"deducted"`
+			} `json:"material_shortfalls"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &settled)
+	if got := settled.Data.MaterialShortfalls; len(got) != 1 || got[0].ProductID != towel.ID || got[0].Required != 1 || got[0].Deducted != 0 {
+		t.Fatalf("expected towel shortfall in response, got %+v", got)
+	}
+	testDB.First(&savedOil, oil.ID)
+	var savedTowel models.PhysicalProduct
+	testDB.First(&savedTowel, towel.ID)
+	var reloaded models.Appointment
+	testDB.First(&reloaded, second.ID)
+	if savedOil.Stock != 1 || savedTowel.Stock != 0 || reloaded.Status != "completed" {
+		t.Fatalf("expected settlement with oil stock 1 and towel stock 0, got oil %d towel %d status %s", savedOil.Stock, savedTowel.Stock, reloaded.Status)
+	}
+	var towelLog models.InventoryLog
+	testDB.Where("appointment_id = ? AND product_id = ?", second.ID, towel.ID).First(&towelLog)
+	if towelLog.ChangeAmount != 0 || towelLog.CostAmount == nil || *towelLog.CostAmount != 2 {
+		t.Fatalf("expected towel shortfall log with full material cost, got %+v", towelLog)
+	}
+	testDB.Where("appointment_id = ?", second.ID).First(&order)
+	if order.CostAmount == nil || *order.CostAmount != 15 {
+		t.Fatalf("expected service order cost 15 despite shortfall, got %+v", order.CostAmount)
+	}
+	testDB.Model(&models.StockAlert{}).Where("product_id = ? AND status = ?", towel.ID, "open").Count(&alerts)
+	if alerts != 1 {
+		t.Fatalf("expected one open alert for the short material, got %d", alerts)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+	"server/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLowStockProducts 列出库存不高于补货点的上架商品，附日均销量、在途数量和建议补货量
+// GET /api/inventory/low-stock
+func GetLowStockProducts(c *gin.Context) {
+	items, err := inventory.LowStock(db.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch low stock products", err.Error()))
+		return
+	}
+
+	var suggested int
+	for _, item := range items {
+		suggested += item.SuggestedQuantity
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"products":             items,
+		"total":                len(items),
+		"total_suggested":      suggested,
+		"velocity_window_days": config.GlobalInventoryPolicy.VelocityWindowDays,
+		"cover_days":           config.GlobalInventoryPolicy.CoverDays,
+	}, ""))
+}
+
+// ListStockAlerts 分页查询补货提醒，默认只返回未处理的提醒
+// GET /api/inventory/alerts?status=open&product_id=1&page=1&page_size=20
+func ListStockAlerts(c *gin.Context) {
+	query := db.DB.Model(&models.StockAlert{})
+	switch status := c.DefaultQuery("status", inventory.AlertOpen); status {
+	case "all":
+	case inventory.AlertOpen, inventory.AlertResolved:
+		query = query.Where("status = ?", status)
+	default:
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "status must be open, resolved or all", nil))
+		return
+	}
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		productID, err := strconv.ParseUint(productIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid product_id", nil))
+			return
+		}
+		query = query.Where("product_id = ?", uint(productID))
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count stock alerts", err.Error()))
+		return
+	}
+	alerts := make([]models.StockAlert, 0)
+	if err := query.Preload("Product").Order("created_at DESC, id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch stock alerts", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"alerts":    alerts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, ""))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStockAlerts_SaleCrossingReorderPointAndLowStockList(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-reorder", PasswordHash: "x", Role: "manager", IsActive: true}
+	member := models.Member{Name: "Bob", Phone: "13800000461", InvitationCode: "M0461", IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&member)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/products", CreateProduct)
+	router.PUT("/api/products/:id", UpdateProduct)
+	router.GET("/api/products/stats", GetProductStats)
+	router.POST("/api/inventory/change", CreateInventoryChange)
+	router.GET("/api/inventory/low-stock", GetLowStockProducts)
+	router.GET("/api/inventory/alerts", ListStockAlerts)
+
+	send := func(method, path string, body interface{}, out interface{}) {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d body=%s", method, path, w.Code, w.Body.String())
+		}
+		if out != nil {
+			json.Unmarshal(w.Body.Bytes(), out)
+		}
+	}
+
+	var created struct {
+		Data models.PhysicalProduct `json:"data"`
+	}
+	send("POST", "/api/products", gin.H{"name": "Cleanser", "stock": 8, "retail_price": 50, "cost_price": 20, "is_active": true, "reorder_point": 5, "target_stock": 20}, &created)
+	product := created.Data
+	if product.ReorderPoint != 5 || product.TargetStock != 20 {
+		t.Fatalf("expected reorder settings saved, got %+v", product)
+	}
+	var zero struct {
+		Data models.PhysicalProduct `json:"data"`
+	}
+	send("POST", "/api/products", gin.H{"name": "Sample", "stock": 1, "retail_price": 0.01, "cost_price": 0.01, "is_active": true, "reorder_point": 0}, &zero)
+	var saved models.PhysicalProduct
+	testDB.First(&saved, zero.Data.ID)
+	if saved.ReorderPoint != 0 {
+		t.Fatalf("expected explicit reorder point 0 to be kept, got %d", saved.ReorderPoint)
+	}
+
+	// 8 → 6 未跌破，6 → 4 跌破补货点 5
+	send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -2, "action_type": "sale", "member_id": member.ID}, nil)
+	var alerts struct {
+		Data struct {
+			Alerts []models.StockAlert `json:"alerts"`
+			Total  int64               `json:"total"`
+		} `json:"data"`
+	}
+	send("GET", "/api/inventory/alerts", nil, &alerts)
+	if alerts.Data.Total != 0 {
+		t.Fatalf("expected no alert above reorder point, got %+v", alerts.Data.Alerts)
+	}
+	send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": -2, "action_type": "sale", "member_id": member.ID}, nil)
+	send("GET", "/api/inventory/alerts", nil, &alerts)
+	if alerts.Data.Total != 1 || alerts.Data.Alerts[0].ProductID != product.ID || alerts.Data.Alerts[0].Stock != 4 || alerts.Data.Alerts[0].InventoryLogID == nil {
+		t.Fatalf("expected one open alert at stock 4, got %+v", alerts.Data)
+	}
+
+	var lowStock struct {
+		Data struct {
+			Products []struct {
+				ID                uint    `json:"id"`
+				DailyVelocity     float64 `json:"daily_velocity"`
+				SuggestedQuantity int     `json:"suggested_quantity"`
+			} `json:"products"`
+		} `json:"data"`
+	}
+	send("GET", "/api/inventory/low-stock", nil, &lowStock)
+	if len(lowStock.Data.Products) != 1 || lowStock.Data.Products[0].ID != product.ID || lowStock.Data.Products[0].SuggestedQuantity != 16 {
+		t.Fatalf("expected cleanser with 16 suggested, got %+v", lowStock.Data.Products)
+	}
+
+	var stats struct {
+		Data struct {
+			LowStockCount int64 `json:"low_stock_count"`
+		} `json:"data"`
+	}
+	send("GET", "/api/products/stats", nil, &stats)
+	if stats.Data.LowStockCount != 1 {
+		t.Fatalf("expected 1 low stock product, got %d", stats.Data.LowStockCount)
+	}
+
+	// 补货点下调后不再属于低库存；到货回到补货点以上关闭提醒
+	send("PUT", fmt.Sprintf("/api/products/%d", product.ID), gin.H{"reorder_point": 3}, nil)
+	send("GET", "/api/inventory/low-stock", nil, &lowStock)
+	if len(lowStock.Data.Products) != 0 {
+		t.Fatalf("expected no low stock products after lowering reorder point, got %+v", lowStock.Data.Products)
+	}
+	send("POST", "/api/inventory/change", gin.H{"product_id": product.ID, "change_amount": 10, "action_type": "restock"}, nil)
+	send("GET", "/api/inventory/alerts?status=resolved", nil, &alerts)
+	if alerts.Data.Total != 1 || alerts.Data.Alerts[0].ResolvedAt == nil {
+		t.Fatalf("expected alert resolved after restock, got %+v", alerts.Data)
+	}
+}
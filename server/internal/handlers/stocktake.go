@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/inventory"
+	"server/internal/models"
+	"server/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateStocktakeRequest 创建盘点单的请求体，product_ids 为空时盘点全部上架商品
+type CreateStocktakeRequest struct {
+	ProductIDs []uint `json:"product_ids"`
+	Remark     string `json:"remark" binding:"max=255"`
+}
+
+// SaveStocktakeCountsRequest 录入实盘数量的请求体
+type SaveStocktakeCountsRequest struct {
+	Counts []inventory.Count `json:"counts" binding:"required,min=1,dive"`
+}
+
+// writeStocktakeError 将盘点流程中的错误转换为对应的 HTTP 响应
+func writeStocktakeError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, response.Error(http.StatusNotFound, "Stocktake or product not found", nil))
+	case errors.Is(err, inventory.ErrNoStocktakeProducts), errors.Is(err, inventory.ErrUnknownStocktakeProduct),
+		errors.Is(err, inventory.ErrMissingReasonCode), errors.Is(err, inventory.ErrInsufficientStock):
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+	case errors.Is(err, inventory.ErrStocktakeState):
+		c.JSON(http.StatusConflict, response.Error(http.StatusConflict, err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, fallback, err.Error()))
+	}
+}
+
+// loadStocktakeReport 加载盘点单及明细并计算差异
+func loadStocktakeReport(tx *gorm.DB, id uint) (*inventory.StocktakeReport, error) {
+	var st models.Stocktake
+	if err := tx.Preload("Lines", func(q *gorm.DB) *gorm.DB {
+		return q.Order("id ASC")
+	}).Preload("Lines.Product").First(&st, id).Error; err != nil {
+		return nil, err
+	}
+	report := inventory.BuildStocktakeReport(&st)
+	return &report, nil
+}
+
+// stocktakeID 解析路径中的盘点单ID
+func stocktakeID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, "Invalid stocktake ID", nil))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// CreateStocktake 开始盘点：快照所选商品（默认全部上架商品）的账面库存
+// POST /api/stocktakes
+func CreateStocktake(c *gin.Context) {
+	var req CreateStocktakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	var st *models.Stocktake
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		st, err = inventory.OpenStocktake(tx, req.ProductIDs, req.Remark, currentOperatorID(c))
+		return err
+	})
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to create stocktake")
+		return
+	}
+
+	report, err := loadStocktakeReport(db.DB, st.ID)
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to fetch stocktake")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(report, "Stocktake created successfully"))
+}
+
+// ListStocktakes 分页查询盘点单
+// GET /api/stocktakes?status=counting&page=1&page_size=20
+func ListStocktakes(c *gin.Context) {
+	query := db.DB.Model(&models.Stocktake{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to count stocktakes", err.Error()))
+		return
+	}
+	stocktakes := make([]models.Stocktake, 0)
+	if err := query.Order("created_at DESC, id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&stocktakes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, response.Error(http.StatusInternalServerError, "Failed to fetch stocktakes", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"stocktakes": stocktakes,
+		"total":      total,
+		"page":       page,
+		"page_size":  pageSize,
+	}, ""))
+}
+
+// GetStocktake 查询盘点单详情，包括各明细差异与汇总
+// GET /api/stocktakes/:id
+func GetStocktake(c *gin.Context) {
+	id, ok := stocktakeID(c)
+	if !ok {
+		return
+	}
+	report, err := loadStocktakeReport(db.DB, id)
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to fetch stocktake")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(report, ""))
+}
+
+// SaveStocktakeCounts 录入实盘数量，可分多次保存
+// PUT /api/stocktakes/:id/counts
+func SaveStocktakeCounts(c *gin.Context) {
+	id, ok := stocktakeID(c)
+	if !ok {
+		return
+	}
+	var req SaveStocktakeCountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.Error(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return inventory.RecordCounts(tx, id, req.Counts, currentOperatorID(c), time.Now())
+	})
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to save counts")
+		return
+	}
+
+	report, err := loadStocktakeReport(db.DB, id)
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to fetch stocktake")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(report, "Counts saved"))
+}
+
+// ApproveStocktake 审核盘点单，将所有差异过账为纠错库存日志
+// POST /api/stocktakes/:id/approve
+func ApproveStocktake(c *gin.Context) {
+	id, ok := stocktakeID(c)
+	if !ok {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.Error(http.StatusUnauthorized, "User not authenticated", nil))
+		return
+	}
+
+	var logs []models.InventoryLog
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		logs, err = inventory.ApproveStocktake(tx, id, userID.(uint), time.Now())
+		return err
+	})
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to approve stocktake")
+		return
+	}
+
+	report, err := loadStocktakeReport(db.DB, id)
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to fetch stocktake")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(gin.H{
+		"stocktake": report,
+		"logs":      logs,
+	}, "Stocktake approved"))
+}
+
+// CancelStocktake 取消盘点中的盘点单，不调整库存
+// POST /api/stocktakes/:id/cancel
+func CancelStocktake(c *gin.Context) {
+	id, ok := stocktakeID(c)
+	if !ok {
+		return
+	}
+	err := inventory.TransitionStocktake(db.DB, id, inventory.StocktakeCounting,
+		map[string]interface{}{"status": inventory.StocktakeCancelled})
+	if err != nil {
+		writeStocktakeError(c, err, "Failed to cancel stocktake")
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(nil, "Stocktake cancelled"))
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/db"
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStocktake_CountApproveAndCancel(t *testing.T) {
+	testDB := setupOrderTestDB(t)
+	originalDB := db.DB
+	db.DB = testDB
+	defer func() { db.DB = originalDB }()
+
+	operator := models.User{Username: "op-stocktake", PasswordHash: "x", Role: "manager", IsActive: true}
+	lotion := models.PhysicalProduct{Name: "Lotion", Stock: 10, RetailPrice: 60, CostPrice: 25, ReorderPoint: 2, IsActive: true}
+	towel := models.PhysicalProduct{Name: "Towel", Stock: 4, RetailPrice: 10, CostPrice: 3, ReorderPoint: 1, IsActive: true}
+	testDB.Create(&operator)
+	testDB.Create(&lotion)
+	testDB.Create(&towel)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", operator.ID) })
+	router.POST("/api/stocktakes", CreateStocktake)
+	router.GET("/api/stocktakes", ListStocktakes)
+	router.GET("/api/stocktakes/:id", GetStocktake)
+	router.PUT("/api/stocktakes/:id/counts", SaveStocktakeCounts)
+	router.POST("/api/stocktakes/:id/approve", ApproveStocktake)
+	router.POST("/api/stocktakes/:id/cancel", CancelStocktake)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type stocktakeResp struct {
+		Data struct {
+			ID          uint   `json:"id"`
+			StocktakeNo string `json:"stocktake_no"`
+			Status      string `json:"status"`
+			Lines       []struct {
+				ProductID uint `json:"product_id"`
+				Variance  *int `json:"variance"`
+			} `json:"lines"`
+			Summary struct {
+				Counted         int     `json:"counted"`
+				OverageQuantity int     `json:"overage_quantity"`
+				VarianceCost    float64 `json:"variance_cost"`
+			} `json:"summary"`
+		} `json:"data"`
+	}
+
+	w := send("POST", "/api/stocktakes", gin.H{"product_ids": []uint{lotion.ID, towel.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created stocktakeResp
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if len(created.Data.Lines) != 2 || created.Data.Status != "counting" {
+		t.Fatalf("unexpected stocktake: %+v", created.Data)
+	}
+	base := fmt.Sprintf("/api/stocktakes/%d", created.Data.ID)
+
+	if w := send("PUT", base+"/counts", gin.H{"counts": []gin.H{{"product_id": lotion.ID, "counted_quantity": 12, "reason_code": "guess"}}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown reason code, got %d", w.Code)
+	}
+	if w := send("PUT", base+"/counts", gin.H{"counts": []gin.H{{"product_id": lotion.ID}}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for missing counted quantity, got %d", w.Code)
+	}
+	w = send("PUT", base+"/counts", gin.H{"counts": []gin.H{
+		{"product_id": lotion.ID, "counted_quantity": 12, "reason_code": "miscount"},
+		{"product_id": towel.ID, "counted_quantity": 4},
+	}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("save counts: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var counted stocktakeResp
+	json.Unmarshal(w.Body.Bytes(), &counted)
+	if s := counted.Data.Summary; s.Counted != 2 || s.OverageQuantity != 2 || s.VarianceCost != 50 {
+		t.Fatalf("unexpected summary after counting: %+v", s)
+	}
+
+	if w := send("POST", base+"/approve", nil); w.Code != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var saved models.PhysicalProduct
+	testDB.First(&saved, lotion.ID)
+	if saved.Stock != 12 {
+		t.Fatalf("expected lotion stock 12 after approval, got %d", saved.Stock)
+	}
+	var logs []models.InventoryLog
+	testDB.Where("stocktake_id = ?", created.Data.ID).Find(&logs)
+	if len(logs) != 1 || logs[0].ProductID != lotion.ID || logs[0].ChangeAmount != 2 || logs[0].ReasonCode != "miscount" {
+		t.Fatalf("expected one overage log for lotion, got %+v", logs)
+	}
+	if w := send("POST", base+"/cancel", nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling an approved stocktake, got %d", w.Code)
+	}
+
+	// 取消的盘点单不调整库存
+	w = send("POST", "/api/stocktakes", gin.H{})
+	var second stocktakeResp
+	json.Unmarshal(w.Body.Bytes(), &second)
+	secondBase := fmt.Sprintf("/api/stocktakes/%d", second.Data.ID)
+	send("PUT", secondBase+"/counts", gin.H{"counts": []gin.H{{"product_id": towel.ID, "counted_quantity": 0, "reason_code": "lost"}}})
+	if w := send("POST", secondBase+"/cancel", nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel: expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send("POST", secondBase+"/approve", nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 approving a cancelled stocktake, got %d", w.Code)
+	}
+	var savedTowel models.PhysicalProduct
+	testDB.First(&savedTowel, towel.ID)
+	if savedTowel.Stock != 4 {
+		t.Fatalf("expected towel stock unchanged, got %d", savedTowel.Stock)
+	}
+	if w := send("GET", "/api/stocktakes/9999", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown stocktake, got %d", w.Code)
+	}
+}
@@ -0,0 +1,193 @@
+// Package health 管理会员健康问卷（带版本历史）与服务禁忌匹配
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"server/internal/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Condition 一种需要在服务前确认的健康状况
+type Condition struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+// Conditions 支持的健康状况代码，问卷与服务禁忌共用
+var Conditions = []Condition{
+	{Code: "pregnancy", Label: "怀孕/备孕"},
+	{Code: "injury", Label: "近期外伤/扭伤"},
+	{Code: "allergy", Label: "过敏体质"},
+	{Code: "hypertension", Label: "高血压"},
+	{Code: "heart_disease", Label: "心脏疾病"},
+	{Code: "diabetes", Label: "糖尿病"},
+	{Code: "skin_condition", Label: "皮肤破损/皮肤病"},
+	{Code: "varicose_veins", Label: "静脉曲张"},
+	{Code: "recent_surgery", Label: "近期手术"},
+}
+
+// ErrUnknownCondition 健康状况代码不在支持列表中
+var ErrUnknownCondition = errors.New("unknown health condition")
+
+// Label 返回状况代码对应的中文名称，未知代码原样返回
+func Label(code string) string {
+	for _, c := range Conditions {
+		if c.Code == code {
+			return c.Label
+		}
+	}
+	return code
+}
+
+// NormalizeConditions 校验并去重状况代码，返回可直接存储的 JSON
+func NormalizeConditions(codes []string) (datatypes.JSON, error) {
+	seen := make(map[string]bool, len(codes))
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if seen[code] {
+			continue
+		}
+		known := false
+		for _, c := range Conditions {
+			if c.Code == code {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCondition, code)
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+	raw, _ := json.Marshal(normalized)
+	return datatypes.JSON(raw), nil
+}
+
+// decodeCodes 解析存储的状况代码列表，空值或格式错误视为无
+func decodeCodes(raw datatypes.JSON) []string {
+	var codes []string
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &codes)
+	}
+	return codes
+}
+
+// LatestIntake 返回会员最新版本的健康问卷，从未填写时返回 nil
+func LatestIntake(tx *gorm.DB, memberID uint) (*models.HealthIntake, error) {
+	var intake models.HealthIntake
+	err := tx.Where("member_id = ?", memberID).Order("version DESC").First(&intake).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &intake, nil
+}
+
+// SaveIntake 以新版本保存健康问卷，历史版本保留不变
+func SaveIntake(tx *gorm.DB, intake *models.HealthIntake) error {
+	var maxVersion int
+	if err := tx.Model(&models.HealthIntake{}).Where("member_id = ?", intake.MemberID).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return err
+	}
+	intake.ID = 0
+	intake.Version = maxVersion + 1
+	return tx.Create(intake).Error
+}
+
+// Warnings 返回会员最新问卷中与服务禁忌冲突的提示，无冲突或未填写问卷时为空
+func Warnings(intake *models.HealthIntake, service *models.ServiceProduct) []string {
+	if intake == nil {
+		return nil
+	}
+	contraindicated := make(map[string]bool)
+	for _, code := range decodeCodes(service.Contraindications) {
+		contraindicated[code] = true
+	}
+	var warnings []string
+	for _, code := range decodeCodes(intake.Conditions) {
+		if contraindicated[code] {
+			warnings = append(warnings, fmt.Sprintf("会员健康问卷记录了「%s」，服务「%s」不适用", Label(code), service.Name))
+		}
+	}
+	return warnings
+}
+
+// MergeIntakes 将重复会员的问卷迁移到保留会员：两人的历史版本按填写时间重新编号；
+// 两人都填写过问卷时，再以双方最新问卷合并（状况取并集、文字说明合并）生成新的最新版本，
+// 避免任何一方记录的禁忌在合并后被遗漏
+func MergeIntakes(tx *gorm.DB, survivorID, duplicateID uint, recordedBy *uint) error {
+	survivorLatest, err := LatestIntake(tx, survivorID)
+	if err != nil {
+		return err
+	}
+	duplicateLatest, err := LatestIntake(tx, duplicateID)
+	if err != nil {
+		return err
+	}
+	if duplicateLatest == nil {
+		return nil
+	}
+
+	var intakes []models.HealthIntake
+	if err := tx.Where("member_id IN ?", []uint{survivorID, duplicateID}).
+		Order("created_at ASC, id ASC").Find(&intakes).Error; err != nil {
+		return err
+	}
+	// 先写入负数版本号再改为正式版本号，避免与 (member_id, version) 唯一索引冲突
+	for i, intake := range intakes {
+		if err := tx.Model(&models.HealthIntake{}).Where("id = ?", intake.ID).
+			Updates(map[string]interface{}{"member_id": survivorID, "version": -(i + 1)}).Error; err != nil {
+			return err
+		}
+	}
+	for i, intake := range intakes {
+		if err := tx.Model(&models.HealthIntake{}).Where("id = ?", intake.ID).
+			Update("version", i+1).Error; err != nil {
+			return err
+		}
+	}
+	if survivorLatest == nil {
+		return nil
+	}
+
+	conditions, err := NormalizeConditions(append(decodeCodes(survivorLatest.Conditions), decodeCodes(duplicateLatest.Conditions)...))
+	if err != nil {
+		return err
+	}
+	merged := models.HealthIntake{
+		MemberID:    survivorID,
+		Conditions:  conditions,
+		Allergies:   mergeText(survivorLatest.Allergies, duplicateLatest.Allergies, 255),
+		Injuries:    mergeText(survivorLatest.Injuries, duplicateLatest.Injuries, 255),
+		Medications: mergeText(survivorLatest.Medications, duplicateLatest.Medications, 255),
+		Notes:       mergeText(survivorLatest.Notes, duplicateLatest.Notes, 0),
+		RecordedBy:  recordedBy,
+	}
+	return SaveIntake(tx, &merged)
+}
+
+// mergeText 合并两份问卷的同一文字项，相同或一方为空时不重复；limit 为列的字符上限（0 表示不限）
+func mergeText(a, b string, limit int) string {
+	var merged string
+	switch {
+	case a == "" || a == b:
+		merged = b
+	case b == "":
+		merged = a
+	default:
+		merged = a + "；" + b
+	}
+	if runes := []rune(merged); limit > 0 && len(runes) > limit {
+		merged = string(runes[:limit])
+	}
+	return merged
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"errors"
+	"testing"
+
+	"server/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestNormalizeConditions(t *testing.T) {
+	raw, err := NormalizeConditions([]string{"allergy", "pregnancy", "allergy"})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if string(raw) != `["allergy","pregnancy"]` {
+		t.Fatalf("expected deduplicated codes, got %s", raw)
+	}
+	if _, err := NormalizeConditions([]string{"flu"}); !errors.Is(err, ErrUnknownCondition) {
+		t.Fatalf("expected ErrUnknownCondition, got %v", err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	service := &models.ServiceProduct{Name: "Hot stone", Contraindications: datatypes.JSON(`["pregnancy","hypertension"]`)}
+	if w := Warnings(nil, service); len(w) != 0 {
+		t.Fatalf("expected no warnings without intake, got %v", w)
+	}
+	intake := &models.HealthIntake{Conditions: datatypes.JSON(`["allergy","hypertension","pregnancy"]`)}
+	if w := Warnings(intake, service); len(w) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", w)
+	}
+	if w := Warnings(intake, &models.ServiceProduct{Name: "Foot"}); len(w) != 0 {
+		t.Fatalf("expected no warnings for service without contraindications, got %v", w)
+	}
+}
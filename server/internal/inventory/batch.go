@@ -0,0 +1,179 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrBatchInsufficient 批次剩余数量不足
+var ErrBatchInsufficient = errors.New("insufficient quantity in batch")
+
+// BatchInput 入库时登记的批次信息，均为可选
+type BatchInput struct {
+	BatchNo    string
+	ExpiryDate *time.Time
+}
+
+// ParseExpiryDate 解析 YYYY-MM-DD 格式的到期日（按服务器本地时区，与临期查询的日期边界一致），空字符串返回 nil
+func ParseExpiryDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// fefoOrder 先到期先出：有到期日的批次按到期日排序，无保质期的批次排在最后，同日按入库先后
+const fefoOrder = "expiry_date IS NULL, expiry_date ASC, received_at ASC, id ASC"
+
+// CreateBatch 为一次入库创建批次；未指定批号时按入库日期和入库日志ID生成
+func CreateBatch(tx *gorm.DB, inventoryLog *models.InventoryLog, input BatchInput, now time.Time) (*models.ProductBatch, error) {
+	batchNo := input.BatchNo
+	if batchNo == "" {
+		batchNo = fmt.Sprintf("B%s-%06d", now.Format("20060102"), inventoryLog.ID)
+	}
+	batch := models.ProductBatch{
+		ProductID:           inventoryLog.ProductID,
+		BatchNo:             batchNo,
+		Quantity:            inventoryLog.ChangeAmount,
+		Remaining:           inventoryLog.ChangeAmount,
+		ExpiryDate:          input.ExpiryDate,
+		ReceivedAt:          now,
+		UnitCost:            inventoryLog.UnitCost,
+		InventoryLogID:      &inventoryLog.ID,
+		PurchaseOrderLineID: inventoryLog.PurchaseOrderLineID,
+	}
+	if err := tx.Create(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ConsumeFEFO 按先到期先出从批次中扣减出库数量，并记录每个批次的扣减明细
+// 调用前须已通过 Adjust 扣减商品库存：Adjust 持有商品行的写锁，同一商品的批次扣减因此串行执行。
+// 批次不足部分视为从未分批的历史库存出库
+func ConsumeFEFO(tx *gorm.DB, productID uint, quantity int, inventoryLogID uint) ([]models.BatchConsumption, error) {
+	var batches []models.ProductBatch
+	if err := tx.Where("product_id = ? AND remaining > 0", productID).
+		Order(fefoOrder).Find(&batches).Error; err != nil {
+		return nil, err
+	}
+
+	consumptions := make([]models.BatchConsumption, 0)
+	for _, batch := range batches {
+		if quantity <= 0 {
+			break
+		}
+		take := batch.Remaining
+		if take > quantity {
+			take = quantity
+		}
+		if err := takeFromBatch(tx, batch.ID, take); err != nil {
+			return nil, err
+		}
+		consumption := models.BatchConsumption{InventoryLogID: inventoryLogID, BatchID: batch.ID, Quantity: take}
+		if err := tx.Create(&consumption).Error; err != nil {
+			return nil, err
+		}
+		consumptions = append(consumptions, consumption)
+		quantity -= take
+	}
+	return consumptions, nil
+}
+
+// takeFromBatch 以剩余数量充足为条件扣减批次
+func takeFromBatch(tx *gorm.DB, batchID uint, quantity int) error {
+	res := tx.Model(&models.ProductBatch{}).
+		Where("id = ? AND remaining >= ?", batchID, quantity).
+		Update("remaining", gorm.Expr("remaining - ?", quantity))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBatchInsufficient
+	}
+	return nil
+}
+
+// WriteOffBatch 报损指定批次（如临期、过期）：扣减商品库存与批次剩余，并记录一条 adjustment 库存日志
+func WriteOffBatch(tx *gorm.DB, batchID uint, quantity int, operatorID uint, remark string) (*models.InventoryLog, error) {
+	var batch models.ProductBatch
+	if err := tx.First(&batch, batchID).Error; err != nil {
+		return nil, err
+	}
+	if batch.Remaining < quantity {
+		return nil, ErrBatchInsufficient
+	}
+
+	change, err := Adjust(tx, batch.ProductID, -quantity)
+	if err != nil {
+		return nil, err
+	}
+	if err := takeFromBatch(tx, batch.ID, quantity); err != nil {
+		return nil, err
+	}
+
+	logRemark := "Write-off batch " + batch.BatchNo
+	if remark != "" {
+		logRemark += " " + remark
+	}
+	costAmount := CostOfGoods(quantity, change.UnitCost)
+	inventoryLog := models.InventoryLog{
+		ProductID:    batch.ProductID,
+		OperatorID:   operatorID,
+		ChangeAmount: -quantity,
+		ActionType:   "adjustment",
+		BeforeStock:  change.Before,
+		AfterStock:   change.After,
+		UnitCost:     &change.UnitCost,
+		CostAmount:   &costAmount,
+		Remark:       logRemark,
+	}
+	if err := tx.Create(&inventoryLog).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.BatchConsumption{InventoryLogID: inventoryLog.ID, BatchID: batch.ID, Quantity: quantity}).Error; err != nil {
+		return nil, err
+	}
+	if _, err := CheckReorderPoint(tx, batch.ProductID, change, &inventoryLog.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &inventoryLog, nil
+}
+
+// ExpiringBatch 临期批次报表中的一行
+type ExpiringBatch struct {
+	models.ProductBatch
+	DaysLeft int  `json:"days_left"` // 距到期天数，已过期为负数
+	Expired  bool `json:"expired"`
+}
+
+// ExpiringBatches 返回仍有剩余且在 within 天内到期（含已过期）的批次，按到期日升序
+func ExpiringBatches(tx *gorm.DB, within int, now time.Time) ([]ExpiringBatch, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	cutoff := today.AddDate(0, 0, within+1)
+
+	var batches []models.ProductBatch
+	if err := tx.Preload("Product").
+		Where("remaining > 0 AND expiry_date IS NOT NULL AND expiry_date < ?", cutoff).
+		Order(fefoOrder).Find(&batches).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]ExpiringBatch, 0, len(batches))
+	for _, b := range batches {
+		expiry := time.Date(b.ExpiryDate.Year(), b.ExpiryDate.Month(), b.ExpiryDate.Day(), 0, 0, 0, 0, now.Location())
+		daysLeft := int(math.Round(expiry.Sub(today).Hours() / 24))
+		result = append(result, ExpiringBatch{ProductBatch: b, DaysLeft: daysLeft, Expired: daysLeft < 0})
+	}
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestBatches_FEFOConsumptionWriteOffAndExpiry(t *testing.T) {
+	database := setupStockTestDB(t)
+	// 2 件为引入批次管理前的未分批库存
+	product := models.PhysicalProduct{Name: "Cream", Stock: 2, RetailPrice: 80, CostPrice: 30}
+	database.Create(&product)
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.Local)
+
+	receive := func(quantity int, batchNo, expiry string) models.ProductBatch {
+		t.Helper()
+		change, err := Restock(database, product.ID, quantity, nil)
+		if err != nil {
+			t.Fatalf("restock: %v", err)
+		}
+		inventoryLog := models.InventoryLog{ProductID: product.ID, OperatorID: 1, ChangeAmount: quantity, ActionType: "restock", BeforeStock: change.Before, AfterStock: change.After}
+		database.Create(&inventoryLog)
+		expiryDate, err := ParseExpiryDate(expiry)
+		if err != nil {
+			t.Fatalf("parse expiry: %v", err)
+		}
+		batch, err := CreateBatch(database, &inventoryLog, BatchInput{BatchNo: batchNo, ExpiryDate: expiryDate}, now)
+		if err != nil {
+			t.Fatalf("create batch: %v", err)
+		}
+		return *batch
+	}
+	late := receive(5, "LATE", "2027-06-30")
+	undated := receive(4, "", "")
+	soon := receive(3, "SOON", "2026-11-05")
+	expired := receive(1, "OLD", "2026-10-10")
+	if undated.BatchNo == "" {
+		t.Fatalf("expected generated batch number")
+	}
+
+	// 出库 6 件：依次取已过期 1、临期 3、远期 2，无保质期批次与未分批库存保持不动
+	if _, err := Adjust(database, product.ID, -6); err != nil {
+		t.Fatalf("adjust: %v", err)
+	}
+	consumptions, err := ConsumeFEFO(database, product.ID, 6, 99)
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	expected := []struct {
+		batchID  uint
+		quantity int
+	}{{expired.ID, 1}, {soon.ID, 3}, {late.ID, 2}}
+	if len(consumptions) != len(expected) {
+		t.Fatalf("expected %d consumptions, got %+v", len(expected), consumptions)
+	}
+	for i, e := range expected {
+		if consumptions[i].BatchID != e.batchID || consumptions[i].Quantity != e.quantity {
+			t.Fatalf("consumption %d: expected batch %d x%d, got %+v", i, e.batchID, e.quantity, consumptions[i])
+		}
+	}
+
+	// 批次用尽后剩余部分从未分批库存出库
+	if _, err := Adjust(database, product.ID, -8); err != nil {
+		t.Fatalf("adjust: %v", err)
+	}
+	consumptions, _ = ConsumeFEFO(database, product.ID, 8, 100)
+	if len(consumptions) != 2 || consumptions[0].Quantity != 3 || consumptions[1].BatchID != undated.ID || consumptions[1].Quantity != 4 {
+		t.Fatalf("expected remaining late then undated batch, got %+v", consumptions)
+	}
+
+	// 报损：超过批次剩余被拒绝
+	fresh := receive(4, "FRESH", "2026-10-25")
+	if _, err := WriteOffBatch(database, fresh.ID, 5, 1, ""); !errors.Is(err, ErrBatchInsufficient) {
+		t.Fatalf("expected insufficient batch error, got %v", err)
+	}
+
+	expiring, err := ExpiringBatches(database, 30, now)
+	if err != nil || len(expiring) != 1 || expiring[0].ID != fresh.ID || expiring[0].DaysLeft != 6 || expiring[0].Expired {
+		t.Fatalf("expected only the fresh batch expiring in 6 days, got %+v err=%v", expiring, err)
+	}
+
+	writeOff, err := WriteOffBatch(database, fresh.ID, 4, 1, "damaged")
+	if err != nil || writeOff.ChangeAmount != -4 || writeOff.ActionType != "adjustment" {
+		t.Fatalf("unexpected write-off log: %+v err=%v", writeOff, err)
+	}
+	var saved models.PhysicalProduct
+	database.First(&saved, product.ID)
+	if saved.Stock != 1 {
+		t.Fatalf("expected 1 untracked unit left, got %d", saved.Stock)
+	}
+	if expiring, _ := ExpiringBatches(database, 30, now); len(expiring) != 0 {
+		t.Fatalf("expected no expiring batches after write-off, got %+v", expiring)
+	}
+}
+
+func TestParseExpiryDate_UsesLocalMidnight(t *testing.T) {
+	expiry, err := ParseExpiryDate("2026-11-05")
+	if err != nil {
+		t.Fatalf("parse expiry: %v", err)
+	}
+	if !expiry.Equal(time.Date(2026, 11, 5, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("expected local midnight, got %v", expiry)
+	}
+	if _, err := ParseExpiryDate("2026-13-01"); err == nil {
+		t.Fatalf("expected error for invalid date")
+	}
+}
@@ -0,0 +1,102 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// ActionConsumption 服务结算时自动扣减耗材的库存日志类型
+const ActionConsumption = "consumption"
+
+// MaterialCost 按商品当前移动加权平均成本估算一次服务的耗材成本，物料清单需已预加载商品
+func MaterialCost(materials []models.ServiceMaterial) float64 {
+	var cents int64
+	for _, m := range materials {
+		cents += util.ToCents(m.Product.CostPrice) * int64(m.Quantity)
+	}
+	return util.CentsToYuan(cents)
+}
+
+// MaterialShortfall 结算时耗材账面库存不足的记录。服务已经完成，不因账面库存阻断结算：
+// 按现有库存扣减至 0，差额写入耗材日志备注并保持补货提醒未处理，留待盘点核实
+type MaterialShortfall struct {
+	ProductID      uint   `json:"product_id"`
+	ProductName    string `json:"product_name"`
+	Required       int    `json:"required"` // 物料清单用量
+	Deducted       int    `json:"deducted"` // 实际扣减的账面库存
+	InventoryLogID uint   `json:"inventory_log_id"`
+}
+
+// ConsumeServiceMaterials 按服务项目的物料清单扣减耗材库存：每种耗材生成一条关联预约的 consumption 日志，
+// 按扣减时的移动加权平均成本记录耗材成本，并按先到期先出扣减批次。
+// 返回生成的日志、库存不足的耗材与耗材总成本；服务未配置物料清单时不产生日志。
+// 耗材库存不足时不返回错误，只扣减现有库存，耗材成本仍按清单用量计入
+func ConsumeServiceMaterials(tx *gorm.DB, serviceID, appointmentID, operatorID uint, now time.Time) ([]models.InventoryLog, []MaterialShortfall, float64, error) {
+	var materials []models.ServiceMaterial
+	if err := tx.Preload("Product").Where("service_id = ?", serviceID).Order("id ASC").Find(&materials).Error; err != nil {
+		return nil, nil, 0, err
+	}
+
+	logs := make([]models.InventoryLog, 0, len(materials))
+	shortfalls := make([]MaterialShortfall, 0)
+	var totalCents int64
+	for _, m := range materials {
+		deducted := m.Quantity
+		change, err := Adjust(tx, m.ProductID, -m.Quantity)
+		if errors.Is(err, ErrInsufficientStock) {
+			// 账面库存不足：扣减现有库存，差额记为短缺
+			deducted = change.Before
+			if deducted > 0 {
+				change, err = Adjust(tx, m.ProductID, -deducted)
+			} else {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("%s: %w", m.Product.Name, err)
+		}
+		costAmount := CostOfGoods(m.Quantity, change.UnitCost)
+		apptID := appointmentID
+		remark := fmt.Sprintf("Service appointment #%d", appointmentID)
+		if deducted < m.Quantity {
+			remark += fmt.Sprintf("; stock short by %d", m.Quantity-deducted)
+		}
+		inventoryLog := models.InventoryLog{
+			ProductID:     m.ProductID,
+			OperatorID:    operatorID,
+			ChangeAmount:  -deducted,
+			ActionType:    ActionConsumption,
+			BeforeStock:   change.Before,
+			AfterStock:    change.After,
+			UnitCost:      &change.UnitCost,
+			CostAmount:    &costAmount,
+			AppointmentID: &apptID,
+			Remark:        remark,
+		}
+		if err := tx.Create(&inventoryLog).Error; err != nil {
+			return nil, nil, 0, err
+		}
+		if _, err := ConsumeFEFO(tx, m.ProductID, deducted, inventoryLog.ID); err != nil {
+			return nil, nil, 0, err
+		}
+		if deducted < m.Quantity {
+			if _, err := openAlert(tx, m.ProductID, change, &inventoryLog.ID); err != nil {
+				return nil, nil, 0, err
+			}
+			shortfalls = append(shortfalls, MaterialShortfall{
+				ProductID: m.ProductID, ProductName: m.Product.Name, Required: m.Quantity, Deducted: deducted, InventoryLogID: inventoryLog.ID,
+			})
+		} else if _, err := CheckReorderPoint(tx, m.ProductID, change, &inventoryLog.ID, now); err != nil {
+			return nil, nil, 0, err
+		}
+		totalCents += util.ToCents(costAmount)
+		logs = append(logs, inventoryLog)
+	}
+	return logs, shortfalls, util.CentsToYuan(totalCents), nil
+}
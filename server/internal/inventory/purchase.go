@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 采购单状态
+const (
+	PurchaseDraft             = "draft"
+	PurchaseOrdered           = "ordered"
+	PurchasePartiallyReceived = "partially_received"
+	PurchaseReceived          = "received"
+	PurchaseCancelled         = "cancelled"
+)
+
+var (
+	// ErrPurchaseOrderState 采购单当前状态不允许该操作
+	ErrPurchaseOrderState = errors.New("purchase order is not in a valid state for this action")
+	// ErrUnknownPurchaseLine 收货明细不属于该采购单
+	ErrUnknownPurchaseLine = errors.New("line does not belong to this purchase order")
+	// ErrOverReceipt 收货数量超过未到货数量
+	ErrOverReceipt = errors.New("received quantity exceeds outstanding quantity")
+)
+
+// Receipt 一条采购明细的本次到货数量
+type Receipt struct {
+	LineID     uint   `json:"line_id" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,min=1"`
+	BatchNo    string `json:"batch_no" binding:"max=64"`                           // 批号（可选）
+	ExpiryDate string `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"` // 到期日 YYYY-MM-DD（可选）
+}
+
+// PurchaseOrderNo 根据创建日期和ID生成采购单号，如 PO20261019-000012
+func PurchaseOrderNo(po *models.PurchaseOrder) string {
+	return fmt.Sprintf("PO%s-%06d", po.CreatedAt.Format("20060102"), po.ID)
+}
+
+// PurchaseTotal 按明细计算采购单总金额
+func PurchaseTotal(lines []models.PurchaseOrderLine) float64 {
+	var cents int64
+	for _, l := range lines {
+		cents += util.ToCents(l.UnitCost) * int64(l.Quantity)
+	}
+	return util.CentsToYuan(cents)
+}
+
+// TransitionPurchaseOrder 以当前状态为条件更新采购单状态
+func TransitionPurchaseOrder(tx *gorm.DB, id uint, from []string, updates map[string]interface{}) error {
+	res := tx.Model(&models.PurchaseOrder{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(&models.PurchaseOrder{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrPurchaseOrderState
+	}
+	return nil
+}
+
+// ReceivePurchaseOrder 登记采购单到货：每条明细原子增加库存并写入关联该明细的 restock 库存日志，
+// 同时按采购单价重新计算商品的移动加权平均成本；全部明细到齐后采购单变为 received，否则为 partially_received
+func ReceivePurchaseOrder(tx *gorm.DB, poID uint, receipts []Receipt, operatorID uint, remark string, now time.Time) ([]models.InventoryLog, error) {
+	var po models.PurchaseOrder
+	if err := tx.Preload("Lines").First(&po, poID).Error; err != nil {
+		return nil, err
+	}
+	if po.Status != PurchaseOrdered && po.Status != PurchasePartiallyReceived {
+		return nil, ErrPurchaseOrderState
+	}
+	lines := make(map[uint]models.PurchaseOrderLine, len(po.Lines))
+	for _, l := range po.Lines {
+		lines[l.ID] = l
+	}
+
+	logRemark := "Received " + po.OrderNo
+	if remark != "" {
+		logRemark += " " + remark
+	}
+	logs := make([]models.InventoryLog, 0, len(receipts))
+	for _, r := range receipts {
+		line, ok := lines[r.LineID]
+		if !ok {
+			return nil, ErrUnknownPurchaseLine
+		}
+		expiry, err := ParseExpiryDate(r.ExpiryDate)
+		if err != nil {
+			return nil, err
+		}
+		// 以未超收为条件累加到货数量，并发收货不会超过采购数量
+		res := tx.Model(&models.PurchaseOrderLine{}).
+			Where("id = ? AND received_quantity + ? <= quantity", line.ID, r.Quantity).
+			Update("received_quantity", gorm.Expr("received_quantity + ?", r.Quantity))
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil, ErrOverReceipt
+		}
+
+		unitCost := line.UnitCost
+		change, err := Restock(tx, line.ProductID, r.Quantity, &unitCost)
+		if err != nil {
+			return nil, err
+		}
+
+		lineID := line.ID
+		inventoryLog := models.InventoryLog{
+			ProductID:           line.ProductID,
+			OperatorID:          operatorID,
+			ChangeAmount:        r.Quantity,
+			ActionType:          "restock",
+			BeforeStock:         change.Before,
+			AfterStock:          change.After,
+			Remark:              logRemark,
+			PurchaseOrderLineID: &lineID,
+			UnitCost:            &unitCost,
+		}
+		if err := tx.Create(&inventoryLog).Error; err != nil {
+			return nil, err
+		}
+		if _, err := CreateBatch(tx, &inventoryLog, BatchInput{BatchNo: r.BatchNo, ExpiryDate: expiry}, now); err != nil {
+			return nil, err
+		}
+		if _, err := CheckReorderPoint(tx, line.ProductID, change, &inventoryLog.ID, now); err != nil {
+			return nil, err
+		}
+		logs = append(logs, inventoryLog)
+	}
+
+	var outstanding int64
+	if err := tx.Model(&models.PurchaseOrderLine{}).
+		Where("purchase_order_id = ? AND received_quantity < quantity", po.ID).
+		Count(&outstanding).Error; err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{"status": PurchasePartiallyReceived}
+	if outstanding == 0 {
+		updates = map[string]interface{}{"status": PurchaseReceived, "received_at": now}
+	}
+	if err := TransitionPurchaseOrder(tx, po.ID, []string{PurchaseOrdered, PurchasePartiallyReceived}, updates); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// OnOrder 返回各商品已下单但尚未到货的数量（不含草稿与已取消的采购单）
+func OnOrder(tx *gorm.DB, productIDs []uint) (map[uint]int, error) {
+	type row struct {
+		ProductID uint
+		Quantity  int
+	}
+	var rows []row
+	query := tx.Model(&models.PurchaseOrderLine{}).
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_lines.purchase_order_id AND purchase_orders.deleted_at IS NULL").
+		Where("purchase_orders.status IN ?", []string{PurchaseOrdered, PurchasePartiallyReceived}).
+		Select("purchase_order_lines.product_id, SUM(purchase_order_lines.quantity - purchase_order_lines.received_quantity) as quantity").
+		Group("purchase_order_lines.product_id")
+	if len(productIDs) > 0 {
+		query = query.Where("purchase_order_lines.product_id IN ?", productIDs)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		result[r.ProductID] = r.Quantity
+	}
+	return result, nil
+}
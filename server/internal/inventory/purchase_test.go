@@ -0,0 +1,77 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestReceivePurchaseOrder_PartialThenComplete(t *testing.T) {
+	database := setupStockTestDB(t)
+	oil := models.PhysicalProduct{Name: "Oil", Stock: 2, RetailPrice: 50, CostPrice: 15}
+	mask := models.PhysicalProduct{Name: "Mask", Stock: 0, RetailPrice: 30, CostPrice: 8}
+	supplier := models.Supplier{Name: "Acme"}
+	database.Create(&oil)
+	database.Create(&mask)
+	database.Create(&supplier)
+
+	po := models.PurchaseOrder{SupplierID: supplier.ID, Status: PurchaseDraft, Lines: []models.PurchaseOrderLine{
+		{ProductID: oil.ID, Quantity: 10, UnitCost: 18},
+		{ProductID: mask.ID, Quantity: 5, UnitCost: 9},
+	}}
+	database.Create(&po)
+	oilLine, maskLine := po.Lines[0], po.Lines[1]
+	now := time.Now()
+
+	// 草稿不可收货
+	if _, err := ReceivePurchaseOrder(database, po.ID, []Receipt{{LineID: oilLine.ID, Quantity: 1}}, 1, "", now); !errors.Is(err, ErrPurchaseOrderState) {
+		t.Fatalf("expected state error for draft, got %v", err)
+	}
+	if err := TransitionPurchaseOrder(database, po.ID, []string{PurchaseDraft}, map[string]interface{}{"status": PurchaseOrdered}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	logs, err := ReceivePurchaseOrder(database, po.ID, []Receipt{{LineID: oilLine.ID, Quantity: 6}}, 1, "first box", now)
+	if err != nil || len(logs) != 1 || logs[0].PurchaseOrderLineID == nil || *logs[0].PurchaseOrderLineID != oilLine.ID ||
+		logs[0].BeforeStock != 2 || logs[0].AfterStock != 8 || logs[0].ActionType != "restock" {
+		t.Fatalf("unexpected receipt logs: %+v err=%v", logs, err)
+	}
+	var saved models.PurchaseOrder
+	database.First(&saved, po.ID)
+	if saved.Status != PurchasePartiallyReceived {
+		t.Fatalf("expected partially received, got %s", saved.Status)
+	}
+	var savedOil models.PhysicalProduct
+	database.First(&savedOil, oil.ID)
+	// 移动加权平均：(2 × 15 + 6 × 18) / 8 = 17.25
+	if savedOil.Stock != 8 || savedOil.CostPrice != 17.25 || *logs[0].UnitCost != 18 {
+		t.Fatalf("expected oil stock 8 at average cost 17.25, got stock %d cost %v", savedOil.Stock, savedOil.CostPrice)
+	}
+
+	onOrder, err := OnOrder(database, nil)
+	if err != nil || onOrder[oil.ID] != 4 || onOrder[mask.ID] != 5 {
+		t.Fatalf("unexpected on-order quantities: %+v err=%v", onOrder, err)
+	}
+
+	// 超过未到货数量或不属于本单的明细均被拒绝
+	if _, err := ReceivePurchaseOrder(database, po.ID, []Receipt{{LineID: oilLine.ID, Quantity: 5}}, 1, "", now); !errors.Is(err, ErrOverReceipt) {
+		t.Fatalf("expected over-receipt error, got %v", err)
+	}
+	if _, err := ReceivePurchaseOrder(database, po.ID, []Receipt{{LineID: maskLine.ID + 100, Quantity: 1}}, 1, "", now); !errors.Is(err, ErrUnknownPurchaseLine) {
+		t.Fatalf("expected unknown line error, got %v", err)
+	}
+
+	if _, err := ReceivePurchaseOrder(database, po.ID, []Receipt{{LineID: oilLine.ID, Quantity: 4}, {LineID: maskLine.ID, Quantity: 5}}, 1, "", now); err != nil {
+		t.Fatalf("receive remainder: %v", err)
+	}
+	database.First(&saved, po.ID)
+	if saved.Status != PurchaseReceived || saved.ReceivedAt == nil {
+		t.Fatalf("expected fully received purchase order, got %+v", saved)
+	}
+	onOrder, _ = OnOrder(database, []uint{oil.ID})
+	if onOrder[oil.ID] != 0 {
+		t.Fatalf("expected nothing left on order, got %+v", onOrder)
+	}
+}
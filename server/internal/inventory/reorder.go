@@ -0,0 +1,158 @@
+package inventory
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+
+	"gorm.io/gorm"
+)
+
+// 补货提醒状态
+const (
+	AlertOpen     = "open"
+	AlertResolved = "resolved"
+)
+
+// demandActionTypes 计入销售速度的出库类型：商品销售与服务耗材消耗
+var demandActionTypes = []string{"sale", ActionConsumption}
+
+// CheckReorderPoint 在一次库存变更后维护补货提醒：出库使库存从补货点以上降至补货点及以下时创建提醒
+// （同一商品同时只保留一条未处理提醒）；变更后库存高于补货点时将未处理提醒标记为已补货。
+// 未创建提醒时返回 nil
+func CheckReorderPoint(tx *gorm.DB, productID uint, change StockChange, inventoryLogID *uint, now time.Time) (*models.StockAlert, error) {
+	if change.After > change.ReorderPoint {
+		// 补货点可能在提醒后被下调，因此不以变更前低于补货点为条件
+		err := tx.Model(&models.StockAlert{}).
+			Where("product_id = ? AND status = ?", productID, AlertOpen).
+			Updates(map[string]interface{}{"status": AlertResolved, "resolved_at": now}).Error
+		return nil, err
+	}
+	if change.Before <= change.ReorderPoint {
+		return nil, nil
+	}
+	return openAlert(tx, productID, change, inventoryLogID)
+}
+
+// openAlert 为商品创建一条未处理的补货提醒，已有未处理提醒时不重复创建
+func openAlert(tx *gorm.DB, productID uint, change StockChange, inventoryLogID *uint) (*models.StockAlert, error) {
+	var open int64
+	if err := tx.Model(&models.StockAlert{}).
+		Where("product_id = ? AND status = ?", productID, AlertOpen).
+		Count(&open).Error; err != nil {
+		return nil, err
+	}
+	if open > 0 {
+		return nil, nil
+	}
+	alert := models.StockAlert{
+		ProductID:      productID,
+		InventoryLogID: inventoryLogID,
+		Stock:          change.After,
+		ReorderPoint:   change.ReorderPoint,
+		Status:         AlertOpen,
+	}
+	if err := tx.Create(&alert).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// SalesVelocity 返回各商品在统计窗口内的日均销量
+func SalesVelocity(tx *gorm.DB, productIDs []uint, windowDays int, now time.Time) (map[uint]float64, error) {
+	type row struct {
+		ProductID uint
+		Sold      int
+	}
+	var rows []row
+	query := tx.Model(&models.InventoryLog{}).
+		Where("action_type IN ? AND change_amount < 0 AND created_at >= ?", demandActionTypes, now.AddDate(0, 0, -windowDays)).
+		Select("product_id, SUM(-change_amount) as sold").
+		Group("product_id")
+	if len(productIDs) > 0 {
+		query = query.Where("product_id IN ?", productIDs)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]float64, len(rows))
+	for _, r := range rows {
+		result[r.ProductID] = float64(r.Sold) / float64(windowDays)
+	}
+	return result, nil
+}
+
+// SuggestedReorderQuantity 计算建议补货量：补足到目标库存，未设置目标库存时补足到
+// 补货点 + 日均销量 × 覆盖天数；已下单未到货的数量计入在途，不重复补货
+func SuggestedReorderQuantity(product models.PhysicalProduct, velocity float64, onOrder, coverDays int) int {
+	target := product.TargetStock
+	if target <= 0 {
+		target = product.ReorderPoint + int(math.Ceil(velocity*float64(coverDays)))
+	}
+	suggested := target - product.Stock - onOrder
+	if suggested < 0 {
+		return 0
+	}
+	return suggested
+}
+
+// LowStockItem 低库存清单中的一行
+type LowStockItem struct {
+	models.PhysicalProduct
+	DailyVelocity     float64  `json:"daily_velocity"`     // 日均销量
+	DaysOfCover       *float64 `json:"days_of_cover"`      // 当前库存可售天数，近期无销售时为空
+	OnOrder           int      `json:"on_order"`           // 已下单未到货数量
+	SuggestedQuantity int      `json:"suggested_quantity"` // 建议补货量
+}
+
+// LowStock 返回库存不高于补货点的上架商品及建议补货量，按库存低于补货点的幅度降序
+func LowStock(tx *gorm.DB, now time.Time) ([]LowStockItem, error) {
+	var products []models.PhysicalProduct
+	if err := tx.Where("is_active = ? AND stock <= reorder_point", true).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	items := make([]LowStockItem, 0, len(products))
+	if len(products) == 0 {
+		return items, nil
+	}
+
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	policy := config.GlobalInventoryPolicy
+	velocity, err := SalesVelocity(tx, ids, policy.VelocityWindowDays, now)
+	if err != nil {
+		return nil, err
+	}
+	onOrder, err := OnOrder(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range products {
+		item := LowStockItem{
+			PhysicalProduct:   p,
+			DailyVelocity:     math.Round(velocity[p.ID]*100) / 100,
+			OnOrder:           onOrder[p.ID],
+			SuggestedQuantity: SuggestedReorderQuantity(p, velocity[p.ID], onOrder[p.ID], policy.CoverDays),
+		}
+		if v := velocity[p.ID]; v > 0 {
+			days := math.Round(float64(p.Stock)/v*10) / 10
+			item.DaysOfCover = &days
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		si := items[i].ReorderPoint - items[i].Stock
+		sj := items[j].ReorderPoint - items[j].Stock
+		if si != sj {
+			return si > sj
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
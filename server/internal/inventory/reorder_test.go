@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestCheckReorderPoint_RaisesOnceAndResolvesOnRestock(t *testing.T) {
+	database := setupStockTestDB(t)
+	product := models.PhysicalProduct{Name: "Toner", Stock: 12, RetailPrice: 60, ReorderPoint: 5}
+	database.Create(&product)
+	now := time.Now()
+
+	step := func(delta int) *models.StockAlert {
+		t.Helper()
+		change, err := Adjust(database, product.ID, delta)
+		if err != nil {
+			t.Fatalf("adjust %d: %v", delta, err)
+		}
+		alert, err := CheckReorderPoint(database, product.ID, change, nil, now)
+		if err != nil {
+			t.Fatalf("check reorder point: %v", err)
+		}
+		return alert
+	}
+
+	if alert := step(-6); alert != nil {
+		t.Fatalf("expected no alert above reorder point, got %+v", alert)
+	}
+	alert := step(-1) // 6 → 5，跌至补货点
+	if alert == nil || alert.Stock != 5 || alert.ReorderPoint != 5 || alert.Status != AlertOpen {
+		t.Fatalf("expected open alert at stock 5, got %+v", alert)
+	}
+	if again := step(-2); again != nil {
+		t.Fatalf("expected no new alert below reorder point, got %+v", again)
+	}
+
+	// 补货到补货点以上关闭提醒，再次跌破时重新提醒
+	step(10)
+	var resolved models.StockAlert
+	database.First(&resolved, alert.ID)
+	if resolved.Status != AlertResolved || resolved.ResolvedAt == nil {
+		t.Fatalf("expected alert resolved after restock, got %+v", resolved)
+	}
+	if again := step(-9); again == nil || again.Stock != 4 {
+		t.Fatalf("expected a new alert after dropping below again, got %+v", again)
+	}
+}
+
+func TestLowStock_SuggestsQuantityFromVelocityAndOnOrder(t *testing.T) {
+	database := setupStockTestDB(t)
+	now := time.Now()
+	fast := models.PhysicalProduct{Name: "Mask", Stock: 4, RetailPrice: 20, ReorderPoint: 10, IsActive: true}
+	targeted := models.PhysicalProduct{Name: "Oil", Stock: 8, RetailPrice: 90, ReorderPoint: 8, TargetStock: 30, IsActive: true}
+	healthy := models.PhysicalProduct{Name: "Soap", Stock: 50, RetailPrice: 15, ReorderPoint: 10, IsActive: true}
+	database.Create(&fast)
+	database.Create(&targeted)
+	database.Create(&healthy)
+
+	// 近 30 天卖出 60 件 → 日均 2 件；窗口外的销售不计入
+	database.Create(&models.InventoryLog{ProductID: fast.ID, OperatorID: 1, ChangeAmount: -60, ActionType: "sale"})
+	old := models.InventoryLog{ProductID: fast.ID, OperatorID: 1, ChangeAmount: -100, ActionType: "sale"}
+	database.Create(&old)
+	database.Model(&old).Update("created_at", now.AddDate(0, 0, -45))
+	database.Create(&models.InventoryLog{ProductID: fast.ID, OperatorID: 1, ChangeAmount: -5, ActionType: "adjustment"})
+
+	supplier := models.Supplier{Name: "Acme", IsActive: true}
+	database.Create(&supplier)
+	po := models.PurchaseOrder{OrderNo: "PO1", SupplierID: supplier.ID, Status: PurchaseOrdered,
+		Lines: []models.PurchaseOrderLine{{ProductID: targeted.ID, Quantity: 10, ReceivedQuantity: 2, UnitCost: 40}}}
+	database.Create(&po)
+
+	items, err := LowStock(database, now)
+	if err != nil {
+		t.Fatalf("low stock: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != fast.ID || items[1].ID != targeted.ID {
+		t.Fatalf("expected mask then oil, got %+v", items)
+	}
+	// 补货点 10 + 2 件/天 × 14 天 - 库存 4 = 34
+	if m := items[0]; m.DailyVelocity != 2 || m.DaysOfCover == nil || *m.DaysOfCover != 2 || m.SuggestedQuantity != 34 {
+		t.Fatalf("unexpected mask suggestion: %+v", m)
+	}
+	// 目标库存 30 - 库存 8 - 在途 8 = 14
+	if o := items[1]; o.OnOrder != 8 || o.DaysOfCover != nil || o.SuggestedQuantity != 14 {
+		t.Fatalf("unexpected oil suggestion: %+v", o)
+	}
+}
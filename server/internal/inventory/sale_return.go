@@ -0,0 +1,66 @@
+package inventory
+
+import (
+	"time"
+
+	"server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ActionReturn 订单退款时商品退回库存的日志类型
+const ActionReturn = "return"
+
+// ReturnSale 将一次销售出库退回库存：按出库时的单位成本入库（移动加权平均成本随之更新），
+// 退回数量优先放回出库时扣减的批次（保留原到期日），出库时来自未分批库存的部分登记为新批次。
+// 返回退货入库日志
+func ReturnSale(tx *gorm.DB, saleLog *models.InventoryLog, operatorID uint, remark string, now time.Time) (*models.InventoryLog, error) {
+	quantity := -saleLog.ChangeAmount
+	if quantity <= 0 {
+		return nil, nil
+	}
+	change, err := Restock(tx, saleLog.ProductID, quantity, saleLog.UnitCost)
+	if err != nil {
+		return nil, err
+	}
+
+	returnLog := models.InventoryLog{
+		ProductID:    saleLog.ProductID,
+		OperatorID:   operatorID,
+		MemberID:     saleLog.MemberID,
+		ChangeAmount: quantity,
+		ActionType:   ActionReturn,
+		BeforeStock:  change.Before,
+		AfterStock:   change.After,
+		UnitCost:     saleLog.UnitCost,
+		Remark:       remark,
+	}
+	if err := tx.Create(&returnLog).Error; err != nil {
+		return nil, err
+	}
+
+	var consumptions []models.BatchConsumption
+	if err := tx.Where("inventory_log_id = ?", saleLog.ID).Order("id ASC").Find(&consumptions).Error; err != nil {
+		return nil, err
+	}
+	left := quantity
+	for _, bc := range consumptions {
+		if err := tx.Model(&models.ProductBatch{}).Where("id = ?", bc.BatchID).
+			Update("remaining", gorm.Expr("remaining + ?", bc.Quantity)).Error; err != nil {
+			return nil, err
+		}
+		left -= bc.Quantity
+	}
+	if left > 0 {
+		unbatched := returnLog
+		unbatched.ChangeAmount = left
+		if _, err := CreateBatch(tx, &unbatched, BatchInput{}, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := CheckReorderPoint(tx, saleLog.ProductID, change, &returnLog.ID, now); err != nil {
+		return nil, err
+	}
+	return &returnLog, nil
+}
@@ -0,0 +1,87 @@
+// Package inventory 封装商品库存变更：库存增减一律通过原子条件更新完成，避免并发销售超卖或覆盖彼此的更新
+package inventory
+
+import (
+	"errors"
+
+	"server/internal/models"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// ErrInsufficientStock 库存不足以完成本次扣减
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// StockChange 一次库存变更前后的数量，以及变更后的单位成本（移动加权平均）和商品补货点
+type StockChange struct {
+	Before       int     `json:"before_stock"`
+	After        int     `json:"after_stock"`
+	UnitCost     float64 `json:"unit_cost"`
+	ReorderPoint int     `json:"reorder_point"`
+}
+
+// Adjust 原子地将商品库存增减 delta：以 stock + delta >= 0 为条件在数据库内完成加减，
+// 不依赖事务前读取的库存值，因此并发扣减不会使库存为负，也不会丢失其他事务的更新。
+// 库存不足时返回 ErrInsufficientStock（Before/After 为当前库存），商品不存在时返回 gorm.ErrRecordNotFound
+func Adjust(tx *gorm.DB, productID uint, delta int) (StockChange, error) {
+	res := tx.Model(&models.PhysicalProduct{}).
+		Where("id = ? AND stock + ? >= 0", productID, delta).
+		Update("stock", gorm.Expr("stock + ?", delta))
+	if res.Error != nil {
+		return StockChange{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		var product models.PhysicalProduct
+		if err := tx.Select("id", "stock", "cost_price", "reorder_point").First(&product, productID).Error; err != nil {
+			return StockChange{}, err
+		}
+		return StockChange{Before: product.Stock, After: product.Stock, UnitCost: product.CostPrice, ReorderPoint: product.ReorderPoint}, ErrInsufficientStock
+	}
+
+	// 本事务已持有该行的写锁，读回的库存即为本次更新后的值
+	var product models.PhysicalProduct
+	if err := tx.Select("id", "stock", "cost_price", "reorder_point").First(&product, productID).Error; err != nil {
+		return StockChange{}, err
+	}
+	return StockChange{Before: product.Stock - delta, After: product.Stock, UnitCost: product.CostPrice, ReorderPoint: product.ReorderPoint}, nil
+}
+
+// Restock 入库并按入库单价更新移动加权平均成本；unitCost 为空时（如盘盈、纠错）成本不变
+func Restock(tx *gorm.DB, productID uint, quantity int, unitCost *float64) (StockChange, error) {
+	change, err := Adjust(tx, productID, quantity)
+	if err != nil || unitCost == nil {
+		return change, err
+	}
+	change.UnitCost = MovingAverageCost(change.Before, change.UnitCost, quantity, *unitCost)
+	if err := tx.Model(&models.PhysicalProduct{}).Where("id = ?", productID).
+		Update("cost_price", change.UnitCost).Error; err != nil {
+		return StockChange{}, err
+	}
+	return change, nil
+}
+
+// MovingAverageCost 计算入库后的移动加权平均成本：
+// (原库存 × 原成本 + 入库数量 × 入库单价) / (原库存 + 入库数量)，原库存为 0 时直接取入库单价
+func MovingAverageCost(onHand int, currentCost float64, quantity int, unitCost float64) float64 {
+	if onHand <= 0 {
+		return util.RoundMoney(unitCost)
+	}
+	total := int64(onHand)*util.ToCents(currentCost) + int64(quantity)*util.ToCents(unitCost)
+	units := int64(onHand + quantity)
+	return util.CentsToYuan((total + units/2) / units)
+}
+
+// CostOfGoods 返回出库数量按单位成本计算的销售成本
+func CostOfGoods(quantity int, unitCost float64) float64 {
+	return util.CentsToYuan(util.ToCents(unitCost) * int64(quantity))
+}
+
+// GrossMargin 根据销售收入和销售成本计算毛利及毛利率（%）
+func GrossMargin(revenue, cost float64) (profit, marginRate float64) {
+	profit = util.RoundMoney(revenue - cost)
+	if revenue > 0 {
+		marginRate = util.RoundMoney(profit / revenue * 100)
+	}
+	return profit, marginRate
+}
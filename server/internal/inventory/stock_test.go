@@ -0,0 +1,126 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"server/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupStockTestDB 使用临时文件数据库，使多个连接可以真正并发写入同一张表
+func setupStockTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=10000&_journal_mode=WAL", filepath.Join(t.TempDir(), "stock.db"))
+	database, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := database.AutoMigrate(&models.PhysicalProduct{}, &models.InventoryLog{}, &models.Supplier{}, &models.PurchaseOrder{}, &models.PurchaseOrderLine{}, &models.ProductBatch{}, &models.BatchConsumption{}, &models.StockAlert{}, &models.Stocktake{}, &models.StocktakeLine{}, &models.ServiceMaterial{}, &models.ProductCategory{}, &models.ParentProduct{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+func TestAdjust_ConcurrentSalesNeverOversell(t *testing.T) {
+	database := setupStockTestDB(t)
+	product := models.PhysicalProduct{Name: "Oil", Stock: 5, RetailPrice: 20}
+	database.Create(&product)
+
+	const buyers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold, rejected := 0, 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := database.Transaction(func(tx *gorm.DB) error {
+				change, err := Adjust(tx, product.ID, -1)
+				if err != nil {
+					return err
+				}
+				return tx.Create(&models.InventoryLog{
+					ProductID: product.ID, ChangeAmount: -1, ActionType: "sale",
+					BeforeStock: change.Before, AfterStock: change.After,
+				}).Error
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				sold++
+			case errors.Is(err, ErrInsufficientStock):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var saved models.PhysicalProduct
+	database.First(&saved, product.ID)
+	if sold != 5 || rejected != buyers-5 || saved.Stock != 0 {
+		t.Fatalf("expected 5 sales and stock 0, got sold=%d rejected=%d stock=%d", sold, rejected, saved.Stock)
+	}
+
+	// 每条日志的前后库存连续且互不重复，说明没有丢失更新
+	var logs []models.InventoryLog
+	database.Order("before_stock DESC").Find(&logs)
+	for i, l := range logs {
+		if l.BeforeStock != 5-i || l.AfterStock != 4-i {
+			t.Fatalf("unexpected log %d: before=%d after=%d", i, l.BeforeStock, l.AfterStock)
+		}
+	}
+}
+
+func TestAdjust_MissingProductAndRestock(t *testing.T) {
+	database := setupStockTestDB(t)
+	product := models.PhysicalProduct{Name: "Mask", Stock: 2, RetailPrice: 10}
+	database.Create(&product)
+
+	if _, err := Adjust(database, product.ID+100, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected record not found, got %v", err)
+	}
+	change, err := Adjust(database, product.ID, -3)
+	if !errors.Is(err, ErrInsufficientStock) || change.Before != 2 {
+		t.Fatalf("expected insufficient stock with current stock 2, got %+v err=%v", change, err)
+	}
+	change, err = Adjust(database, product.ID, 8)
+	if err != nil || change.Before != 2 || change.After != 10 {
+		t.Fatalf("expected restock 2 -> 10, got %+v err=%v", change, err)
+	}
+}
+
+func TestMovingAverageCostAndMargin(t *testing.T) {
+	cases := []struct {
+		onHand   int
+		current  float64
+		quantity int
+		unitCost float64
+		expected float64
+	}{
+		{0, 12, 10, 20, 20},      // 无库存时直接取入库单价
+		{10, 20, 10, 30, 25},     // 等量加权
+		{3, 10, 1, 11, 10.25},    // (30 + 11) / 4
+		{3, 10.01, 3, 10, 10.01}, // 四舍五入到分：(30.03 + 30) / 6 = 10.005
+	}
+	for _, tc := range cases {
+		if got := MovingAverageCost(tc.onHand, tc.current, tc.quantity, tc.unitCost); got != tc.expected {
+			t.Fatalf("MovingAverageCost(%d, %v, %d, %v) = %v, want %v", tc.onHand, tc.current, tc.quantity, tc.unitCost, got, tc.expected)
+		}
+	}
+
+	if cost := CostOfGoods(3, 17.25); cost != 51.75 {
+		t.Fatalf("expected cost of goods 51.75, got %v", cost)
+	}
+	if profit, margin := GrossMargin(200, 150); profit != 50 || margin != 25 {
+		t.Fatalf("expected profit 50 and margin 25%%, got %v and %v", profit, margin)
+	}
+}
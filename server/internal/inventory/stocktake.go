@@ -0,0 +1,289 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 盘点单状态
+const (
+	StocktakeCounting  = "counting"
+	StocktakeApproved  = "approved"
+	StocktakeCancelled = "cancelled"
+)
+
+var (
+	// ErrStocktakeState 盘点单当前状态不允许该操作
+	ErrStocktakeState = errors.New("stocktake is not in a valid state for this action")
+	// ErrNoStocktakeProducts 没有可盘点的商品
+	ErrNoStocktakeProducts = errors.New("no products to count")
+	// ErrUnknownStocktakeProduct 录入的商品不在该盘点单中
+	ErrUnknownStocktakeProduct = errors.New("product is not part of this stocktake")
+	// ErrMissingReasonCode 存在差异的明细未填写原因代码
+	ErrMissingReasonCode = errors.New("reason code is required for every line with a variance")
+)
+
+// Count 一个商品的实盘数量；同一商品可多次保存，以最后一次为准
+type Count struct {
+	ProductID       uint   `json:"product_id" binding:"required"`
+	CountedQuantity *int   `json:"counted_quantity" binding:"required,min=0"`
+	ReasonCode      string `json:"reason_code" binding:"omitempty,oneof=damaged expired lost theft miscount sample other"` // 差异原因：损坏、过期、丢失、失窃、记账错误、试用装、其他
+	Remark          string `json:"remark" binding:"max=255"`
+}
+
+// StocktakeNo 根据创建日期和ID生成盘点单号，如 ST20261019-000003
+func StocktakeNo(st *models.Stocktake) string {
+	return fmt.Sprintf("ST%s-%06d", st.CreatedAt.Format("20060102"), st.ID)
+}
+
+// OpenStocktake 创建盘点单并快照各商品当前账面库存；未指定商品时盘点全部上架商品。
+// 指定的商品不存在时返回 gorm.ErrRecordNotFound
+func OpenStocktake(tx *gorm.DB, productIDs []uint, remark string, createdBy *uint) (*models.Stocktake, error) {
+	var products []models.PhysicalProduct
+	query := tx.Order("id ASC")
+	if len(productIDs) > 0 {
+		query = query.Where("id IN ?", productIDs)
+	} else {
+		query = query.Where("is_active = ?", true)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	if len(productIDs) > 0 && len(products) != len(uniqueIDs(productIDs)) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if len(products) == 0 {
+		return nil, ErrNoStocktakeProducts
+	}
+
+	st := models.Stocktake{Status: StocktakeCounting, Remark: remark, CreatedBy: createdBy}
+	for _, p := range products {
+		st.Lines = append(st.Lines, models.StocktakeLine{ProductID: p.ID, ExpectedStock: p.Stock})
+	}
+	if err := tx.Create(&st).Error; err != nil {
+		return nil, err
+	}
+	st.StocktakeNo = StocktakeNo(&st)
+	if err := tx.Model(&st).Update("stocktake_no", st.StocktakeNo).Error; err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// uniqueIDs 去除重复的ID
+func uniqueIDs(ids []uint) map[uint]struct{} {
+	set := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// RecordCounts 保存实盘数量，仅盘点中的盘点单可录入。
+// 录入（或改动）实盘数量时将账面库存重新快照为此刻的库存，使差异只反映实物与账面的出入，
+// 开始盘点后到录入前发生的销售、到货不会被计为差异；仅修改原因代码或备注时保留原快照
+func RecordCounts(tx *gorm.DB, stocktakeID uint, counts []Count, countedBy *uint, now time.Time) error {
+	var st models.Stocktake
+	if err := tx.First(&st, stocktakeID).Error; err != nil {
+		return err
+	}
+	if st.Status != StocktakeCounting {
+		return ErrStocktakeState
+	}
+	for _, cnt := range counts {
+		var line models.StocktakeLine
+		if err := tx.Where("stocktake_id = ? AND product_id = ?", stocktakeID, cnt.ProductID).First(&line).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownStocktakeProduct
+			}
+			return err
+		}
+		updates := map[string]interface{}{
+			"reason_code": cnt.ReasonCode,
+			"remark":      cnt.Remark,
+		}
+		if line.CountedQuantity == nil || *line.CountedQuantity != *cnt.CountedQuantity {
+			var product models.PhysicalProduct
+			if err := tx.Select("id", "stock").First(&product, cnt.ProductID).Error; err != nil {
+				return err
+			}
+			updates["counted_quantity"] = *cnt.CountedQuantity
+			updates["expected_stock"] = product.Stock
+			updates["counted_by"] = countedBy
+			updates["counted_at"] = now
+		}
+		if err := tx.Model(&models.StocktakeLine{}).Where("id = ?", line.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Variance 返回明细的盘点差异（实盘 - 录入时的账面快照），尚未盘点时为 0
+func Variance(line models.StocktakeLine) int {
+	if line.CountedQuantity == nil {
+		return 0
+	}
+	return *line.CountedQuantity - line.ExpectedStock
+}
+
+// StocktakeLineVariance 带差异数量与差异金额的盘点明细
+type StocktakeLineVariance struct {
+	models.StocktakeLine
+	Variance     *int     `json:"variance"`      // 差异数量，尚未盘点时为空
+	VarianceCost *float64 `json:"variance_cost"` // 差异金额（按商品当前移动加权平均成本），盘亏为负
+}
+
+// StocktakeSummary 盘点单汇总
+type StocktakeSummary struct {
+	Lines            int     `json:"lines"`
+	Counted          int     `json:"counted"`
+	Uncounted        int     `json:"uncounted"`
+	VarianceLines    int     `json:"variance_lines"`    // 有差异的明细数
+	ShortageQuantity int     `json:"shortage_quantity"` // 盘亏数量
+	OverageQuantity  int     `json:"overage_quantity"`  // 盘盈数量
+	VarianceCost     float64 `json:"variance_cost"`     // 净差异金额，盘亏为负
+}
+
+// StocktakeReport 盘点单详情：明细附带差异，并给出汇总
+type StocktakeReport struct {
+	*models.Stocktake
+	Lines   []StocktakeLineVariance `json:"lines"`
+	Summary StocktakeSummary        `json:"summary"`
+}
+
+// BuildStocktakeReport 计算盘点单各明细的差异与汇总，明细需已预加载商品
+func BuildStocktakeReport(st *models.Stocktake) StocktakeReport {
+	report := StocktakeReport{Stocktake: st, Lines: make([]StocktakeLineVariance, 0, len(st.Lines))}
+	var costCents int64
+	for _, line := range st.Lines {
+		row := StocktakeLineVariance{StocktakeLine: line}
+		report.Summary.Lines++
+		if line.CountedQuantity == nil {
+			report.Summary.Uncounted++
+			report.Lines = append(report.Lines, row)
+			continue
+		}
+		report.Summary.Counted++
+		variance := Variance(line)
+		cents := util.ToCents(line.Product.CostPrice) * int64(variance)
+		cost := util.CentsToYuan(cents)
+		row.Variance = &variance
+		row.VarianceCost = &cost
+		costCents += cents
+		switch {
+		case variance < 0:
+			report.Summary.VarianceLines++
+			report.Summary.ShortageQuantity += -variance
+		case variance > 0:
+			report.Summary.VarianceLines++
+			report.Summary.OverageQuantity += variance
+		}
+		report.Lines = append(report.Lines, row)
+	}
+	report.Summary.VarianceCost = util.CentsToYuan(costCents)
+	return report
+}
+
+// TransitionStocktake 以当前状态为条件更新盘点单状态
+func TransitionStocktake(tx *gorm.DB, id uint, from string, updates map[string]interface{}) error {
+	res := tx.Model(&models.Stocktake{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(&models.Stocktake{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrStocktakeState
+	}
+	return nil
+}
+
+// ApproveStocktake 审核盘点单：每条有差异的已盘明细按差异数量（实盘 - 录入时的快照）原子调整当前库存，
+// 录入实盘后、审核前发生的销售、到货因此不会被覆盖；调整日志关联盘点单并记录原因代码，盘亏按先到期先出扣减批次。
+// 未盘点的明细不做调整
+func ApproveStocktake(tx *gorm.DB, stocktakeID uint, operatorID uint, now time.Time) ([]models.InventoryLog, error) {
+	var st models.Stocktake
+	if err := tx.Preload("Lines", func(q *gorm.DB) *gorm.DB {
+		return q.Order("id ASC")
+	}).First(&st, stocktakeID).Error; err != nil {
+		return nil, err
+	}
+	if st.Status != StocktakeCounting {
+		return nil, ErrStocktakeState
+	}
+	for _, line := range st.Lines {
+		if Variance(line) != 0 && line.ReasonCode == "" {
+			return nil, ErrMissingReasonCode
+		}
+	}
+	if err := TransitionStocktake(tx, st.ID, StocktakeCounting, map[string]interface{}{
+		"status":      StocktakeApproved,
+		"approved_by": operatorID,
+		"approved_at": now,
+	}); err != nil {
+		return nil, err
+	}
+
+	logs := make([]models.InventoryLog, 0)
+	for _, line := range st.Lines {
+		variance := Variance(line)
+		if variance == 0 {
+			continue
+		}
+		change, err := Adjust(tx, line.ProductID, variance)
+		if err != nil {
+			return nil, err
+		}
+
+		remark := "Stocktake " + st.StocktakeNo
+		if line.Remark != "" {
+			remark += " " + line.Remark
+		}
+		stocktakeID := st.ID
+		inventoryLog := models.InventoryLog{
+			ProductID:    line.ProductID,
+			OperatorID:   operatorID,
+			ChangeAmount: variance,
+			ActionType:   "adjustment",
+			BeforeStock:  change.Before,
+			AfterStock:   change.After,
+			UnitCost:     &change.UnitCost,
+			Remark:       remark,
+			StocktakeID:  &stocktakeID,
+			ReasonCode:   line.ReasonCode,
+		}
+		if variance < 0 {
+			costAmount := CostOfGoods(-variance, change.UnitCost)
+			inventoryLog.CostAmount = &costAmount
+		}
+		if err := tx.Create(&inventoryLog).Error; err != nil {
+			return nil, err
+		}
+		if variance < 0 {
+			if _, err := ConsumeFEFO(tx, line.ProductID, -variance, inventoryLog.ID); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := CheckReorderPoint(tx, line.ProductID, change, &inventoryLog.ID, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.StocktakeLine{}).Where("id = ?", line.ID).
+			Update("inventory_log_id", inventoryLog.ID).Error; err != nil {
+			return nil, err
+		}
+		logs = append(logs, inventoryLog)
+	}
+	return logs, nil
+}
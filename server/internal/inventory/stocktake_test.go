@@ -0,0 +1,137 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestStocktake_PostsVarianceAgainstCurrentStock(t *testing.T) {
+	database := setupStockTestDB(t)
+	now := time.Now()
+	shampoo := models.PhysicalProduct{Name: "Shampoo", Stock: 20, RetailPrice: 40, CostPrice: 12, ReorderPoint: 5, IsActive: true}
+	comb := models.PhysicalProduct{Name: "Comb", Stock: 3, RetailPrice: 5, CostPrice: 1, ReorderPoint: 1, IsActive: true}
+	brush := models.PhysicalProduct{Name: "Brush", Stock: 7, RetailPrice: 15, CostPrice: 4, ReorderPoint: 1, IsActive: true}
+	retired := models.PhysicalProduct{Name: "Retired", Stock: 9, RetailPrice: 1, IsActive: true}
+	database.Create(&shampoo)
+	database.Create(&comb)
+	database.Create(&brush)
+	database.Create(&retired)
+	database.Model(&retired).Update("is_active", false)
+
+	// 洗发水有 6 件在批次中，其余为未分批库存
+	restockLog := models.InventoryLog{ProductID: shampoo.ID, OperatorID: 1, ChangeAmount: 6, ActionType: "restock"}
+	database.Create(&restockLog)
+	expiry := now.AddDate(0, 2, 0)
+	batch, _ := CreateBatch(database, &restockLog, BatchInput{BatchNo: "SH1", ExpiryDate: &expiry}, now)
+
+	st, err := OpenStocktake(database, nil, "month end", nil)
+	if err != nil {
+		t.Fatalf("open stocktake: %v", err)
+	}
+	if len(st.Lines) != 3 || st.StocktakeNo == "" {
+		t.Fatalf("expected 3 active products snapshotted, got %+v", st)
+	}
+	if _, err := OpenStocktake(database, []uint{shampoo.ID, 9999}, "", nil); err == nil {
+		t.Fatalf("expected error for unknown product")
+	}
+
+	qty := func(n int) *int { return &n }
+	// 分两次录入：洗发水先录 15 后改为 16，梳子一致，刷子未盘
+	if err := RecordCounts(database, st.ID, []Count{{ProductID: shampoo.ID, CountedQuantity: qty(15)}}, nil, now); err != nil {
+		t.Fatalf("record counts: %v", err)
+	}
+	if err := RecordCounts(database, st.ID, []Count{
+		{ProductID: shampoo.ID, CountedQuantity: qty(16)},
+		{ProductID: comb.ID, CountedQuantity: qty(3)},
+	}, nil, now); err != nil {
+		t.Fatalf("record counts: %v", err)
+	}
+	if err := RecordCounts(database, st.ID, []Count{{ProductID: retired.ID, CountedQuantity: qty(1)}}, nil, now); !errors.Is(err, ErrUnknownStocktakeProduct) {
+		t.Fatalf("expected unknown product error, got %v", err)
+	}
+
+	var loaded models.Stocktake
+	database.Preload("Lines.Product").First(&loaded, st.ID)
+	report := BuildStocktakeReport(&loaded)
+	if s := report.Summary; s.Counted != 2 || s.Uncounted != 1 || s.VarianceLines != 1 || s.ShortageQuantity != 4 || s.VarianceCost != -48 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+
+	if _, err := ApproveStocktake(database, st.ID, 1, now); !errors.Is(err, ErrMissingReasonCode) {
+		t.Fatalf("expected missing reason code error, got %v", err)
+	}
+	RecordCounts(database, st.ID, []Count{{ProductID: shampoo.ID, CountedQuantity: qty(16), ReasonCode: "damaged", Remark: "leaking"}}, nil, now)
+
+	// 盘点期间又卖出 2 件：审核按差异 -4 调整当前库存 18 → 14
+	Adjust(database, shampoo.ID, -2)
+	logs, err := ApproveStocktake(database, st.ID, 1, now)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expected one adjustment log, got %+v", logs)
+	}
+	l := logs[0]
+	if l.ChangeAmount != -4 || l.BeforeStock != 18 || l.AfterStock != 14 || l.ActionType != "adjustment" ||
+		l.ReasonCode != "damaged" || l.StocktakeID == nil || *l.StocktakeID != st.ID || l.CostAmount == nil || *l.CostAmount != 48 {
+		t.Fatalf("unexpected adjustment log: %+v", l)
+	}
+	var consumed models.ProductBatch
+	database.First(&consumed, batch.ID)
+	if consumed.Remaining != 2 {
+		t.Fatalf("expected shortage consumed from batch first, remaining 2, got %d", consumed.Remaining)
+	}
+	var line models.StocktakeLine
+	database.Where("stocktake_id = ? AND product_id = ?", st.ID, shampoo.ID).First(&line)
+	if line.InventoryLogID == nil || *line.InventoryLogID != l.ID {
+		t.Fatalf("expected line linked to adjustment log, got %+v", line)
+	}
+
+	if _, err := ApproveStocktake(database, st.ID, 1, now); !errors.Is(err, ErrStocktakeState) {
+		t.Fatalf("expected state error on second approval, got %v", err)
+	}
+	if err := RecordCounts(database, st.ID, []Count{{ProductID: comb.ID, CountedQuantity: qty(2)}}, nil, now); !errors.Is(err, ErrStocktakeState) {
+		t.Fatalf("expected state error when counting an approved stocktake, got %v", err)
+	}
+}
+
+func TestStocktake_SalesBeforeCountAreNotVariance(t *testing.T) {
+	database := setupStockTestDB(t)
+	now := time.Now()
+	cream := models.PhysicalProduct{Name: "Cream", Stock: 10, RetailPrice: 30, CostPrice: 10, IsActive: true}
+	database.Create(&cream)
+
+	st, err := OpenStocktake(database, []uint{cream.ID}, "", nil)
+	if err != nil {
+		t.Fatalf("open stocktake: %v", err)
+	}
+	// 开始盘点后、录入实盘前卖出 3 件，实盘 7 件与账面一致
+	Adjust(database, cream.ID, -3)
+	qty := 7
+	if err := RecordCounts(database, st.ID, []Count{{ProductID: cream.ID, CountedQuantity: &qty}}, nil, now); err != nil {
+		t.Fatalf("record counts: %v", err)
+	}
+	var line models.StocktakeLine
+	database.Where("stocktake_id = ?", st.ID).First(&line)
+	if line.ExpectedStock != 7 || Variance(line) != 0 {
+		t.Fatalf("expected snapshot 7 with no variance, got expected=%d variance=%d", line.ExpectedStock, Variance(line))
+	}
+
+	// 仅补充备注不改变快照：录入后再卖出 1 件，审核时差异仍为 0
+	Adjust(database, cream.ID, -1)
+	if err := RecordCounts(database, st.ID, []Count{{ProductID: cream.ID, CountedQuantity: &qty, Remark: "shelf A"}}, nil, now); err != nil {
+		t.Fatalf("record counts: %v", err)
+	}
+	logs, err := ApproveStocktake(database, st.ID, 1, now)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	var saved models.PhysicalProduct
+	database.First(&saved, cream.ID)
+	if len(logs) != 0 || saved.Stock != 6 {
+		t.Fatalf("expected no adjustment and stock 6, got logs=%+v stock=%d", logs, saved.Stock)
+	}
+}
@@ -0,0 +1,74 @@
+// Package jobs 负责启动后台定时任务
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"server/internal/membership"
+	"server/internal/referral"
+	"server/pkg/config"
+
+	"gorm.io/gorm"
+)
+
+// every 以固定间隔在后台循环执行任务，启动时先执行一次
+func every(name string, interval time.Duration, fn func() error) {
+	go func() {
+		for {
+			start := time.Now()
+			if err := fn(); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			} else {
+				log.Printf("job %s finished in %s", name, time.Since(start))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Start 启动所有后台定时任务
+func Start(database *gorm.DB) {
+	every("member-level-recalculate", config.GlobalMemberLevelPolicy.RecalculateInterval, func() error {
+		summary, err := membership.RecalculateAll(database, time.Now())
+		if err == nil {
+			log.Printf("member levels: evaluated=%d upgraded=%d downgraded=%d grace_started=%d",
+				summary.Evaluated, summary.Upgraded, summary.Downgraded, summary.GraceStarted)
+		}
+		return err
+	})
+
+	every("points-expire", config.GlobalPointsPolicy.ExpireInterval, func() error {
+		expired, err := membership.ExpirePoints(database, time.Now())
+		if err == nil && expired > 0 {
+			log.Printf("points expired: %d", expired)
+		}
+		return err
+	})
+
+	every("member-campaigns", config.GlobalCampaignPolicy.RunInterval, func() error {
+		summary, err := membership.RunCampaigns(database, time.Now())
+		if err == nil {
+			log.Printf("member campaigns: birthday=%d anniversary=%d skipped=%d",
+				summary.Birthday, summary.Anniversary, summary.Skipped)
+		}
+		return err
+	})
+
+	every("commission-release", config.GlobalCommissionPolicy.ReleaseInterval, func() error {
+		summary, err := referral.Release(database, time.Now())
+		if err == nil && (summary.Paid > 0 || summary.Reversed > 0) {
+			log.Printf("commission release: confirmed=%d paid=%d reversed=%d",
+				summary.Confirmed, summary.Paid, summary.Reversed)
+		}
+		return err
+	})
+
+	every("member-rfm", config.GlobalRFMPolicy.RecalculateInterval, func() error {
+		summary, err := membership.RecalculateRFM(database, time.Now())
+		if err == nil {
+			log.Printf("member rfm: evaluated=%d churn_risk=%d", summary.Evaluated, summary.ChurnRisk)
+		}
+		return err
+	})
+}
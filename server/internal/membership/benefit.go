@@ -0,0 +1,88 @@
+package membership
+
+import (
+	"errors"
+	"time"
+
+	"server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ServiceDiscountRate 返回会员等级对应的服务折扣率
+func ServiceDiscountRate(tx *gorm.DB, level string) (float64, error) {
+	tier, err := FindTier(tx, level)
+	if err != nil {
+		return 0, err
+	}
+	return tier.ServiceDiscount, nil
+}
+
+// ProductDiscountRate 返回会员等级对应的商品折扣率
+func ProductDiscountRate(tx *gorm.DB, level string) (float64, error) {
+	tier, err := FindTier(tx, level)
+	if err != nil {
+		return 0, err
+	}
+	return tier.ProductDiscount, nil
+}
+
+// CommissionMultiplier 返回会员作为推荐人时的佣金倍数（取其等级配置）
+// 会员记录不存在时按最低等级计算
+func CommissionMultiplier(tx *gorm.DB, memberID uint) (float64, error) {
+	var member models.Member
+	if err := tx.Select("id", "level").First(&member, memberID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	tier, err := FindTier(tx, member.Level)
+	if err != nil {
+		return 0, err
+	}
+	return tier.CommissionMultiplier, nil
+}
+
+// ApplySettledOrder 订单结算后的会员权益统一入口
+// 无论服务订单还是商品订单，都通过这里更新会员的窗口消费额与等级，并按实付金额发放积分
+func ApplySettledOrder(tx *gorm.DB, order *models.Order, now time.Time) (*models.MemberLevelChange, error) {
+	var member models.Member
+	if err := tx.First(&member, order.MemberID).Error; err != nil {
+		return nil, err
+	}
+	change, err := Evaluate(tx, &member, now, SourceSettlement, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := EarnForOrder(tx, &member, order, now); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// RefundSummary 订单退款撤销的会员权益
+type RefundSummary struct {
+	PointsRefunded int                       `json:"points_refunded"` // 退回的抵扣积分
+	PointsRevoked  int                       `json:"points_revoked"`  // 收回的消费积分
+	LevelChange    *models.MemberLevelChange `json:"level_change,omitempty"`
+}
+
+// ApplyRefundedOrder 订单退款后的会员权益统一入口，与 ApplySettledOrder 相对：
+// 退回抵扣的积分与优惠券、收回消费获得的积分，并按退款后的消费额重新评估等级。
+// 调用前订单须已标记退款，使其不再计入消费额
+func ApplyRefundedOrder(tx *gorm.DB, order *models.Order, now time.Time) (RefundSummary, error) {
+	var summary RefundSummary
+	var member models.Member
+	if err := tx.First(&member, order.MemberID).Error; err != nil {
+		return summary, err
+	}
+	var err error
+	if summary.PointsRefunded, summary.PointsRevoked, err = ReverseOrderPoints(tx, &member, order, now); err != nil {
+		return summary, err
+	}
+	if err := ReleaseCoupons(tx, order.ID); err != nil {
+		return summary, err
+	}
+	if summary.LevelChange, err = EvaluateRefund(tx, &member, order, now); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,198 @@
+package membership
+
+import (
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 会员关怀活动类型
+const (
+	CampaignBirthday    = "birthday"
+	CampaignAnniversary = "anniversary"
+)
+
+// CampaignSummary 汇总一次活动任务的发放结果
+type CampaignSummary struct {
+	Birthday    int `json:"birthday"`
+	Anniversary int `json:"anniversary"`
+	Skipped     int `json:"skipped"` // 当年已发放过而跳过的数量
+}
+
+// occurrence 返回某月某日在指定年份的日期，2月29日在平年按2月28日处理
+func occurrence(anchor time.Time, year int, loc *time.Location) time.Time {
+	month, day := anchor.Month(), anchor.Day()
+	if month == time.February && day == 29 && !isLeapYear(year) {
+		day = 28
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// nextOccurrence 返回从 today（含）起最近一次的纪念日
+func nextOccurrence(anchor, today time.Time) time.Time {
+	occ := occurrence(anchor, today.Year(), today.Location())
+	if occ.Before(today) {
+		occ = occurrence(anchor, today.Year()+1, today.Location())
+	}
+	return occ
+}
+
+// dueWithin 判断纪念日是否落在 [today, today+leadDays] 内
+func dueWithin(occ, today time.Time, leadDays int) bool {
+	return !occ.After(today.AddDate(0, 0, leadDays))
+}
+
+// generateCouponCode 生成未被使用的优惠券码
+func generateCouponCode(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		suffix, err := util.GenerateInvitationCode(8)
+		if err != nil {
+			return "", err
+		}
+		code := "CP" + suffix
+		var count int64
+		if err := tx.Unscoped().Model(&models.Coupon{}).Where("code = ?", code).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a unique coupon code")
+}
+
+// IssueCoupon 为会员发放一张优惠券
+func IssueCoupon(tx *gorm.DB, memberID uint, title string, amount, minSpend float64, expiresAt time.Time) (*models.Coupon, error) {
+	code, err := generateCouponCode(tx)
+	if err != nil {
+		return nil, err
+	}
+	coupon := &models.Coupon{
+		MemberID:  memberID,
+		Code:      code,
+		Title:     title,
+		Amount:    amount,
+		MinSpend:  minSpend,
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Create(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// issueReward 为会员发放一次活动奖励并记录；当年已发放过时返回 false
+func issueReward(tx *gorm.DB, member *models.Member, campaign string, occ time.Time, rule config.CampaignReward) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.CampaignIssue{}).
+		Where("member_id = ? AND campaign = ? AND year = ?", member.ID, campaign, occ.Year()).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	title := "生日礼遇"
+	if campaign == CampaignAnniversary {
+		title = fmt.Sprintf("入会 %d 周年礼遇", occ.Year()-member.CreatedAt.Year())
+	}
+
+	issue := models.CampaignIssue{
+		MemberID:   member.ID,
+		Campaign:   campaign,
+		Year:       occ.Year(),
+		RewardType: rule.RewardType,
+		Amount:     rule.Amount,
+		Remark:     fmt.Sprintf("%s（%s）", title, occ.Format("2006-01-02")),
+	}
+	if member.MarketingOptIn {
+		issue.NotifyChannel = member.PreferredContact
+	}
+
+	switch rule.RewardType {
+	case "coupon":
+		coupon, err := IssueCoupon(tx, member.ID, title, rule.Amount, rule.MinSpend, occ.AddDate(0, 0, rule.CouponValidDays))
+		if err != nil {
+			return false, err
+		}
+		issue.CouponID = &coupon.ID
+	case "balance":
+		if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).
+			Update("balance", gorm.Expr("balance + ?", rule.Amount)).Error; err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("unknown campaign reward type %q", rule.RewardType)
+	}
+
+	if err := tx.Create(&issue).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RunCampaigns 查找生日或入会周年即将到来的有效会员并发放配置的奖励
+// 同一会员同一活动每年只发放一次，任务可重复执行
+func RunCampaigns(database *gorm.DB, now time.Time) (CampaignSummary, error) {
+	var summary CampaignSummary
+	policy := config.GlobalCampaignPolicy
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var members []models.Member
+	if err := database.Where("is_active = ?", true).Find(&members).Error; err != nil {
+		return summary, err
+	}
+
+	for i := range members {
+		member := &members[i]
+		type due struct {
+			campaign string
+			occ      time.Time
+			rule     config.CampaignReward
+		}
+		var dues []due
+		if policy.Birthday.Enabled && member.Birthday != nil {
+			occ := nextOccurrence(*member.Birthday, today)
+			if dueWithin(occ, today, policy.Birthday.LeadDays) {
+				dues = append(dues, due{CampaignBirthday, occ, policy.Birthday})
+			}
+		}
+		if policy.Anniversary.Enabled {
+			occ := nextOccurrence(member.CreatedAt, today)
+			if occ.Year() > member.CreatedAt.Year() && dueWithin(occ, today, policy.Anniversary.LeadDays) {
+				dues = append(dues, due{CampaignAnniversary, occ, policy.Anniversary})
+			}
+		}
+
+		for _, d := range dues {
+			var issued bool
+			err := database.Transaction(func(tx *gorm.DB) error {
+				var err error
+				issued, err = issueReward(tx, member, d.campaign, d.occ, d.rule)
+				return err
+			})
+			if err != nil {
+				return summary, fmt.Errorf("issue %s reward to member %d: %w", d.campaign, member.ID, err)
+			}
+			switch {
+			case !issued:
+				summary.Skipped++
+			case d.campaign == CampaignBirthday:
+				summary.Birthday++
+			default:
+				summary.Anniversary++
+			}
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,71 @@
+package membership
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+)
+
+func TestRunCampaigns_IssuesOncePerYear(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	birthday := time.Date(1992, 3, 13, 0, 0, 0, 0, time.UTC)
+	celebrant := models.Member{Name: "B", Phone: "21", InvitationCode: "B21", Birthday: &birthday, PreferredContact: "sms", MarketingOptIn: true}
+	database.Create(&celebrant)
+
+	veteran := models.Member{Name: "V", Phone: "22", InvitationCode: "V22", Balance: 10}
+	veteran.CreatedAt = time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	database.Create(&veteran)
+	database.Model(&veteran).Update("marketing_opt_in", false)
+
+	farBirthday := time.Date(1990, 8, 1, 0, 0, 0, 0, time.UTC)
+	database.Create(&models.Member{Name: "F", Phone: "23", InvitationCode: "F23", Birthday: &farBirthday})
+
+	summary, err := RunCampaigns(database, now)
+	if err != nil {
+		t.Fatalf("run campaigns: %v", err)
+	}
+	if summary.Birthday != 1 || summary.Anniversary != 1 {
+		t.Fatalf("expected one birthday and one anniversary reward, got %+v", summary)
+	}
+
+	var coupon models.Coupon
+	if err := database.Where("member_id = ?", celebrant.ID).First(&coupon).Error; err != nil {
+		t.Fatalf("expected birthday coupon: %v", err)
+	}
+	if coupon.Amount != config.GlobalCampaignPolicy.Birthday.Amount {
+		t.Fatalf("expected coupon amount %.2f, got %.2f", config.GlobalCampaignPolicy.Birthday.Amount, coupon.Amount)
+	}
+
+	var reloaded models.Member
+	database.First(&reloaded, veteran.ID)
+	if reloaded.Balance != 10+config.GlobalCampaignPolicy.Anniversary.Amount {
+		t.Fatalf("expected anniversary gift balance, got %.2f", reloaded.Balance)
+	}
+
+	var issue models.CampaignIssue
+	database.Where("member_id = ? AND campaign = ?", veteran.ID, CampaignAnniversary).First(&issue)
+	if issue.NotifyChannel != "" || issue.Year != 2026 {
+		t.Fatalf("expected silent 2026 anniversary issue, got %+v", issue)
+	}
+
+	// 再次执行不会重复发放
+	summary, err = RunCampaigns(database, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("rerun campaigns: %v", err)
+	}
+	if summary.Birthday != 0 || summary.Anniversary != 0 || summary.Skipped != 2 {
+		t.Fatalf("expected rerun to skip both rewards, got %+v", summary)
+	}
+}
+
+func TestNextOccurrence_LeapDayBirthday(t *testing.T) {
+	leapDay := time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2027, 2, 20, 0, 0, 0, 0, time.UTC)
+	if occ := nextOccurrence(leapDay, today); !occ.Equal(time.Date(2027, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected Feb 28 in a common year, got %s", occ)
+	}
+}
@@ -0,0 +1,68 @@
+package membership
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound = errors.New("coupon not found")
+	ErrCouponUsed     = errors.New("coupon has already been used")
+	ErrCouponExpired  = errors.New("coupon has expired")
+	ErrCouponMinSpend = errors.New("order amount does not reach the coupon minimum spend")
+)
+
+// CouponDiscount 校验优惠券属于该会员、未使用、未过期且订单金额达到使用门槛，
+// 返回优惠券及可抵扣金额（不超过订单金额）
+func CouponDiscount(tx *gorm.DB, memberID, couponID uint, amount float64, now time.Time) (*models.Coupon, float64, error) {
+	var coupon models.Coupon
+	if err := tx.Where("id = ? AND member_id = ?", couponID, memberID).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrCouponNotFound
+		}
+		return nil, 0, err
+	}
+	if coupon.UsedAt != nil {
+		return nil, 0, ErrCouponUsed
+	}
+	if !now.Before(coupon.ExpiresAt) {
+		return nil, 0, ErrCouponExpired
+	}
+	if amount < coupon.MinSpend {
+		return nil, 0, ErrCouponMinSpend
+	}
+	return &coupon, math.Min(coupon.Amount, amount), nil
+}
+
+// UseCoupon 核销优惠券并关联订单；以未使用为条件原子更新，防止同一张券并发重复使用
+func UseCoupon(tx *gorm.DB, coupon *models.Coupon, order *models.Order, now time.Time) error {
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND used_at IS NULL", coupon.ID).
+		Updates(map[string]interface{}{"used_at": now, "order_id": order.ID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCouponUsed
+	}
+	coupon.UsedAt = &now
+	coupon.OrderID = &order.ID
+	return nil
+}
+
+// ReleaseCoupons 订单退款时退回该订单核销的优惠券，未过期的券可再次使用
+func ReleaseCoupons(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&models.Coupon{}).Where("order_id = ?", orderID).
+		Updates(map[string]interface{}{"used_at": nil, "order_id": nil}).Error
+}
+
+// IsCouponError 判断是否为优惠券校验失败（应返回 400）
+func IsCouponError(err error) bool {
+	return errors.Is(err, ErrCouponNotFound) || errors.Is(err, ErrCouponUsed) ||
+		errors.Is(err, ErrCouponExpired) || errors.Is(err, ErrCouponMinSpend)
+}
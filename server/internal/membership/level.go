@@ -0,0 +1,215 @@
+// Package membership 提供会员等级评估等会员权益相关的业务逻辑
+package membership
+
+import (
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 等级变动来源
+const (
+	SourceSettlement = "settlement"
+	SourceJob        = "job"
+	SourceMerge      = "merge"
+	SourceRefund     = "refund"
+)
+
+// WindowStart 返回消费统计窗口的起点
+// rolling: 近12个月滚动窗口；calendar: 当前自然年
+func WindowStart(now time.Time) time.Time {
+	if config.GlobalMemberLevelPolicy.ConsumptionWindow == "calendar" {
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	}
+	return now.AddDate(-1, 0, 0)
+}
+
+// WindowConsumption 汇总会员在统计窗口内已结算且未退款订单的实付金额（仅统计配置中计入消费的订单类型）
+func WindowConsumption(tx *gorm.DB, memberID uint, now time.Time) (float64, error) {
+	var total float64
+	if err := tx.Model(&models.Order{}).
+		Where("member_id = ? AND created_at >= ? AND created_at <= ?", memberID, WindowStart(now), now).
+		Where("refunded_at IS NULL").
+		Where("order_type IN ?", config.GlobalMemberLevelPolicy.ConsumptionOrderTypes).
+		Select("COALESCE(SUM(paid_amount), 0)").
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return util.RoundMoney(total), nil
+}
+
+// Evaluate 重新计算会员的窗口消费额与等级，并在等级变化时写入变动记录
+// allowDowngrade 为 false 时只升级（结算场景），为 true 时按保级策略处理降级（定时任务场景）
+// 返回值: 本次产生的等级变动记录（无变动时为 nil）
+func Evaluate(tx *gorm.DB, member *models.Member, now time.Time, source string, allowDowngrade bool) (*models.MemberLevelChange, error) {
+	consumption, err := WindowConsumption(tx, member.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers, err := Tiers(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := config.GlobalMemberLevelPolicy
+	currentRank := tierRank(tiers, member.Level)
+	targetRank := tierForConsumption(tiers, consumption)
+	target := tiers[targetRank].Name
+
+	updates := map[string]interface{}{"yearly_total_consumption": consumption}
+	var change *models.MemberLevelChange
+
+	switch {
+	case targetRank > currentRank:
+		change = &models.MemberLevelChange{
+			MemberID: member.ID, FromLevel: member.Level, ToLevel: target, Consumption: consumption, Source: source,
+			Reason: fmt.Sprintf("统计期内消费 %.2f 元，达到 %s 等级门槛", consumption, target),
+		}
+		updates["level"] = target
+		updates["level_grace_until"] = nil
+	case targetRank == currentRank:
+		if member.LevelGraceUntil != nil {
+			updates["level_grace_until"] = nil
+		}
+	case allowDowngrade:
+		if member.LevelGraceUntil == nil {
+			graceUntil := now.AddDate(0, 0, policy.GraceDays)
+			updates["level_grace_until"] = graceUntil
+			member.LevelGraceUntil = &graceUntil
+			break
+		}
+		if now.Before(*member.LevelGraceUntil) {
+			break
+		}
+		newRank := targetRank
+		if policy.MaxDowngradeSteps > 0 && currentRank-newRank > policy.MaxDowngradeSteps {
+			newRank = currentRank - policy.MaxDowngradeSteps
+		}
+		newLevel := tiers[newRank].Name
+		change = &models.MemberLevelChange{
+			MemberID: member.ID, FromLevel: member.Level, ToLevel: newLevel, Consumption: consumption, Source: source,
+			Reason: fmt.Sprintf("统计期内消费 %.2f 元，未达到 %s 等级门槛，保级期已于 %s 结束", consumption, member.Level, member.LevelGraceUntil.Format("2006-01-02")),
+		}
+		updates["level"] = newLevel
+		updates["level_grace_until"] = nil
+	}
+
+	if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	member.YearlyTotalConsumption = consumption
+	if lvl, ok := updates["level"].(string); ok {
+		member.Level = lvl
+	}
+	if v, ok := updates["level_grace_until"]; ok && v == nil {
+		member.LevelGraceUntil = nil
+	}
+
+	if change != nil {
+		if err := tx.Create(change).Error; err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+// EvaluateRefund 订单退款后重新计算会员的窗口消费额；若会员当前等级正是依靠该订单达到的，
+// 立即降至退款后消费额对应的等级（不进入保级期），其余降级仍由定时任务按保级策略处理
+func EvaluateRefund(tx *gorm.DB, member *models.Member, order *models.Order, now time.Time) (*models.MemberLevelChange, error) {
+	if _, err := Evaluate(tx, member, now, SourceRefund, false); err != nil {
+		return nil, err
+	}
+	counted := !order.CreatedAt.Before(WindowStart(now))
+	if counted {
+		counted = false
+		for _, t := range config.GlobalMemberLevelPolicy.ConsumptionOrderTypes {
+			if t == order.OrderType {
+				counted = true
+				break
+			}
+		}
+	}
+	if !counted {
+		return nil, nil
+	}
+
+	tiers, err := Tiers(tx)
+	if err != nil {
+		return nil, err
+	}
+	currentRank := tierRank(tiers, member.Level)
+	targetRank := tierForConsumption(tiers, member.YearlyTotalConsumption)
+	if targetRank >= currentRank || tierForConsumption(tiers, member.YearlyTotalConsumption+order.PaidAmount) < currentRank {
+		return nil, nil
+	}
+
+	target := tiers[targetRank].Name
+	change := &models.MemberLevelChange{
+		MemberID: member.ID, FromLevel: member.Level, ToLevel: target, Consumption: member.YearlyTotalConsumption, Source: SourceRefund,
+		Reason: fmt.Sprintf("订单 #%d 退款后统计期内消费 %.2f 元，未达到 %s 等级门槛", order.ID, member.YearlyTotalConsumption, member.Level),
+	}
+	if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).
+		Updates(map[string]interface{}{"level": target, "level_grace_until": nil}).Error; err != nil {
+		return nil, err
+	}
+	member.Level = target
+	member.LevelGraceUntil = nil
+	if err := tx.Create(change).Error; err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// RecalculateSummary 汇总一次批量重算的结果
+type RecalculateSummary struct {
+	Evaluated    int `json:"evaluated"`
+	Upgraded     int `json:"upgraded"`
+	Downgraded   int `json:"downgraded"`
+	GraceStarted int `json:"grace_started"`
+}
+
+// RecalculateAll 对所有有效会员按当前窗口重新评估等级，可降级
+func RecalculateAll(database *gorm.DB, now time.Time) (RecalculateSummary, error) {
+	var summary RecalculateSummary
+
+	var members []models.Member
+	if err := database.Where("is_active = ?", true).Find(&members).Error; err != nil {
+		return summary, err
+	}
+
+	tiers, err := Tiers(database)
+	if err != nil {
+		return summary, err
+	}
+
+	for i := range members {
+		member := &members[i]
+		hadGrace := member.LevelGraceUntil != nil
+		err := database.Transaction(func(tx *gorm.DB) error {
+			change, err := Evaluate(tx, member, now, SourceJob, true)
+			if err != nil {
+				return err
+			}
+			summary.Evaluated++
+			switch {
+			case change != nil && tierRank(tiers, change.ToLevel) > tierRank(tiers, change.FromLevel):
+				summary.Upgraded++
+			case change != nil:
+				summary.Downgraded++
+			case !hadGrace && member.LevelGraceUntil != nil:
+				summary.GraceStarted++
+			}
+			return nil
+		})
+		if err != nil {
+			return summary, fmt.Errorf("evaluate member %d: %w", member.ID, err)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,132 @@
+package membership
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLevelTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := database.AutoMigrate(&models.Member{}, &models.Order{}, &models.MemberLevelChange{}, &models.MemberTier{}, &models.PointsTransaction{}, &models.Coupon{}, &models.CampaignIssue{}, &models.MemberRFM{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+func createOrder(t *testing.T, database *gorm.DB, memberID uint, amount float64, at time.Time, seq uint) {
+	t.Helper()
+	order := models.Order{MemberID: memberID, PaidAmount: amount, OrderType: "service", AppointmentID: &seq}
+	order.CreatedAt = at
+	order.UpdatedAt = at
+	if err := database.Create(&order).Error; err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+}
+
+func TestEvaluate_UpgradeOnSettlementIgnoresOldOrders(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Now()
+
+	member := models.Member{Name: "A", Phone: "1", InvitationCode: "A1", Level: "basic"}
+	database.Create(&member)
+	createOrder(t, database, member.ID, 20000, now.AddDate(-2, 0, 0), 1)
+	createOrder(t, database, member.ID, 1500, now.AddDate(0, -1, 0), 2)
+
+	change, err := Evaluate(database, &member, now, SourceSettlement, false)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if change == nil || change.ToLevel != "silver" {
+		t.Fatalf("expected upgrade to silver from in-window consumption, got %+v", change)
+	}
+	if member.YearlyTotalConsumption != 1500 {
+		t.Fatalf("expected window consumption 1500, got %.2f", member.YearlyTotalConsumption)
+	}
+}
+
+func TestEvaluate_DowngradeAfterGracePeriod(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Now()
+
+	member := models.Member{Name: "B", Phone: "2", InvitationCode: "B2", Level: "platinum"}
+	database.Create(&member)
+	createOrder(t, database, member.ID, 100, now.AddDate(0, -2, 0), 1)
+
+	// 结算场景不降级
+	if change, err := Evaluate(database, &member, now, SourceSettlement, false); err != nil || change != nil {
+		t.Fatalf("expected no change on settlement, got %+v, err=%v", change, err)
+	}
+
+	// 首次评估进入保级期
+	if change, err := Evaluate(database, &member, now, SourceJob, true); err != nil || change != nil {
+		t.Fatalf("expected grace period instead of downgrade, got %+v, err=%v", change, err)
+	}
+	if member.LevelGraceUntil == nil || member.Level != "platinum" {
+		t.Fatalf("expected grace period to start, got %+v", member)
+	}
+
+	// 保级期结束后每次最多下降一级
+	later := now.AddDate(0, 0, config.GlobalMemberLevelPolicy.GraceDays+1)
+	change, err := Evaluate(database, &member, later, SourceJob, true)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if change == nil || change.FromLevel != "platinum" || change.ToLevel != "gold" {
+		t.Fatalf("expected one-step downgrade to gold, got %+v", change)
+	}
+
+	var reloaded models.Member
+	database.First(&reloaded, member.ID)
+	if reloaded.Level != "gold" || reloaded.LevelGraceUntil != nil {
+		t.Fatalf("expected stored level gold without grace, got %+v", reloaded)
+	}
+
+	var history int64
+	database.Model(&models.MemberLevelChange{}).Where("member_id = ?", member.ID).Count(&history)
+	if history != 1 {
+		t.Fatalf("expected 1 level change record, got %d", history)
+	}
+}
+
+func TestEvaluate_UsesConfiguredTiers(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Now()
+
+	tiers := []models.MemberTier{
+		{Name: "bronze", Threshold: 0, ServiceDiscount: 1, ProductDiscount: 1, CommissionMultiplier: 1},
+		{Name: "diamond", Threshold: 300, ServiceDiscount: 0.7, ProductDiscount: 0.85, CommissionMultiplier: 2},
+	}
+	database.Create(&tiers)
+
+	member := models.Member{Name: "C", Phone: "3", InvitationCode: "C3", Level: "bronze"}
+	database.Create(&member)
+	createOrder(t, database, member.ID, 500, now.AddDate(0, -1, 0), 1)
+
+	change, err := Evaluate(database, &member, now, SourceSettlement, false)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if change == nil || change.ToLevel != "diamond" {
+		t.Fatalf("expected upgrade to configured tier diamond, got %+v", change)
+	}
+
+	if rate, _ := ServiceDiscountRate(database, member.Level); rate != 0.7 {
+		t.Fatalf("expected service discount 0.7, got %v", rate)
+	}
+	if rate, _ := ProductDiscountRate(database, member.Level); rate != 0.85 {
+		t.Fatalf("expected product discount 0.85, got %v", rate)
+	}
+	if multiplier, _ := CommissionMultiplier(database, member.ID); multiplier != 2 {
+		t.Fatalf("expected doubled commission multiplier, got %v", multiplier)
+	}
+}
@@ -0,0 +1,294 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 积分流水类型
+const (
+	PointsEarn   = "earn"
+	PointsGrant  = "grant" // 活动赠送
+	PointsRedeem = "redeem"
+	PointsExpire = "expire"
+	PointsRefund = "refund" // 订单退款退回抵扣的积分
+	PointsRevoke = "revoke" // 订单退款收回消费获得的积分
+)
+
+// lotTypes 会增加积分、需按批次跟踪剩余与过期的流水类型
+var lotTypes = []string{PointsEarn, PointsGrant, PointsRefund}
+
+// 积分抵扣校验错误
+var (
+	ErrInsufficientPoints = errors.New("insufficient points")
+	ErrRedeemLimit        = errors.New("points deduction exceeds the allowed ratio")
+)
+
+// PointsValue 返回积分可抵扣的金额（单位：元）
+func PointsValue(points int) float64 {
+	return util.CalculateRate(float64(points), config.GlobalPointsPolicy.YuanPerPoint)
+}
+
+// CheckRedeemable 校验订单金额下可使用的积分数量
+// amount: 订单应付金额（单位：元）
+func CheckRedeemable(member *models.Member, points int, amount float64) error {
+	if points <= 0 {
+		return nil
+	}
+	if member.Points < points {
+		return ErrInsufficientPoints
+	}
+	if PointsValue(points) > util.CalculateRate(amount, config.GlobalPointsPolicy.MaxRedeemRatio)+0.001 {
+		return ErrRedeemLimit
+	}
+	return nil
+}
+
+// isBirthday 判断当天是否为会员生日（只比较月日）
+func isBirthday(member *models.Member, now time.Time) bool {
+	if member.Birthday == nil {
+		return false
+	}
+	return member.Birthday.Month() == now.Month() && member.Birthday.Day() == now.Day()
+}
+
+// EarnForOrder 按实付金额为订单发放积分
+// 积分 = 实付金额 × 每元积分 × 等级积分倍数（生日当天再乘生日倍数），向下取整
+func EarnForOrder(tx *gorm.DB, member *models.Member, order *models.Order, now time.Time) (*models.PointsTransaction, error) {
+	tier, err := FindTier(tx, member.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := config.GlobalPointsPolicy
+	multiplier := tier.PointsMultiplier
+	remark := fmt.Sprintf("订单 #%d 消费 %.2f 元", order.ID, order.PaidAmount)
+	if isBirthday(member, now) {
+		multiplier *= policy.BirthdayMultiplier
+		remark += "（生日多倍积分）"
+	}
+	points := int(math.Floor(order.PaidAmount*policy.PointsPerYuan*multiplier + 1e-9))
+	if points <= 0 {
+		return nil, nil
+	}
+	return addPoints(tx, member, points, PointsEarn, &order.ID, remark, now)
+}
+
+// GrantPoints 直接赠送积分（如营销活动），与消费积分一样按有效期过期
+func GrantPoints(tx *gorm.DB, member *models.Member, points int, remark string, now time.Time) (*models.PointsTransaction, error) {
+	if points <= 0 {
+		return nil, nil
+	}
+	return addPoints(tx, member, points, PointsGrant, nil, remark, now)
+}
+
+// addPoints 增加会员积分并写入一条可跟踪剩余与过期的流水
+func addPoints(tx *gorm.DB, member *models.Member, points int, txType string, orderID *uint, remark string, now time.Time) (*models.PointsTransaction, error) {
+	if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).
+		Update("points", gorm.Expr("points + ?", points)).Error; err != nil {
+		return nil, err
+	}
+	member.Points += points
+
+	expiresAt := now.AddDate(0, 0, config.GlobalPointsPolicy.ValidityDays)
+	entry := &models.PointsTransaction{
+		MemberID:     member.ID,
+		Type:         txType,
+		Points:       points,
+		Remaining:    points,
+		BalanceAfter: member.Points,
+		ExpiresAt:    &expiresAt,
+		OrderID:      orderID,
+		Remark:       remark,
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// consumeEarned 按到期时间从早到晚扣减获得记录中的剩余积分
+func consumeEarned(tx *gorm.DB, memberID uint, points int) error {
+	used, err := consumeUpTo(tx, memberID, points)
+	if err != nil {
+		return err
+	}
+	if used < points {
+		return ErrInsufficientPoints
+	}
+	return nil
+}
+
+// consumeUpTo 按到期时间从早到晚扣减获得记录中的剩余积分，剩余不足时扣完为止，返回实际扣减的积分
+func consumeUpTo(tx *gorm.DB, memberID uint, points int) (int, error) {
+	var lots []models.PointsTransaction
+	if err := tx.Where("member_id = ? AND type IN ? AND remaining > 0", memberID, lotTypes).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+	left := points
+	for _, lot := range lots {
+		if left == 0 {
+			break
+		}
+		used := lot.Remaining
+		if used > left {
+			used = left
+		}
+		if err := tx.Model(&models.PointsTransaction{}).Where("id = ?", lot.ID).
+			Update("remaining", lot.Remaining-used).Error; err != nil {
+			return 0, err
+		}
+		left -= used
+	}
+	return points - left, nil
+}
+
+// Redeem 使用积分抵扣订单金额
+// 返回值: 抵扣流水与抵扣金额（单位：元）
+func Redeem(tx *gorm.DB, member *models.Member, points int, order *models.Order) (*models.PointsTransaction, float64, error) {
+	if points <= 0 {
+		return nil, 0, nil
+	}
+	// 以数据库中的最新余额为准，防止并发重复抵扣
+	result := tx.Model(&models.Member{}).
+		Where("id = ? AND points >= ?", member.ID, points).
+		Update("points", gorm.Expr("points - ?", points))
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, 0, ErrInsufficientPoints
+	}
+	if err := consumeEarned(tx, member.ID, points); err != nil {
+		return nil, 0, err
+	}
+	member.Points -= points
+
+	value := PointsValue(points)
+	entry := &models.PointsTransaction{
+		MemberID:     member.ID,
+		Type:         PointsRedeem,
+		Points:       -points,
+		BalanceAfter: member.Points,
+		OrderID:      &order.ID,
+		Remark:       fmt.Sprintf("订单 #%d 抵扣 %.2f 元", order.ID, value),
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, 0, err
+	}
+	return entry, value, nil
+}
+
+// ReverseOrderPoints 订单退款时撤销积分变动：退回该订单抵扣的积分（作为新的积分批次），
+// 再收回该订单获得的积分——获得批次中未使用的部分直接作废，已被使用的部分从其他批次中扣回，
+// 积分不足时扣完为止。返回退回与实际收回的积分数
+func ReverseOrderPoints(tx *gorm.DB, member *models.Member, order *models.Order, now time.Time) (refunded, revoked int, err error) {
+	var entries []models.PointsTransaction
+	if err := tx.Where("order_id = ? AND member_id = ? AND type IN ?", order.ID, member.ID, []string{PointsRedeem, PointsEarn}).
+		Order("id ASC").Find(&entries).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for _, e := range entries {
+		if e.Type == PointsRedeem {
+			refunded += -e.Points
+		}
+	}
+	if refunded > 0 {
+		if _, err := addPoints(tx, member, refunded, PointsRefund, &order.ID, fmt.Sprintf("订单 #%d 退款，退回抵扣积分", order.ID), now); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for _, lot := range entries {
+		if lot.Type != PointsEarn {
+			continue
+		}
+		if err := tx.Model(&models.PointsTransaction{}).Where("id = ?", lot.ID).Update("remaining", 0).Error; err != nil {
+			return 0, 0, err
+		}
+		taken, err := consumeUpTo(tx, member.ID, lot.Points-lot.Remaining)
+		if err != nil {
+			return 0, 0, err
+		}
+		revoked += lot.Remaining + taken
+	}
+	if revoked > 0 {
+		var current models.Member
+		if err := tx.Select("id", "points").First(&current, member.ID).Error; err != nil {
+			return 0, 0, err
+		}
+		if revoked > current.Points {
+			revoked = current.Points
+		}
+		balance := current.Points - revoked
+		if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).Update("points", balance).Error; err != nil {
+			return 0, 0, err
+		}
+		member.Points = balance
+		if err := tx.Create(&models.PointsTransaction{
+			MemberID:     member.ID,
+			Type:         PointsRevoke,
+			Points:       -revoked,
+			BalanceAfter: balance,
+			OrderID:      &order.ID,
+			Remark:       fmt.Sprintf("订单 #%d 退款，收回消费积分", order.ID),
+		}).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+	return refunded, revoked, nil
+}
+
+// ExpirePoints 清理已到期的积分，返回本次过期的积分总数
+func ExpirePoints(database *gorm.DB, now time.Time) (int, error) {
+	var lots []models.PointsTransaction
+	if err := database.Where("type IN ? AND remaining > 0 AND expires_at <= ?", lotTypes, now).
+		Order("member_id ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, lot := range lots {
+		err := database.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.PointsTransaction{}).Where("id = ?", lot.ID).Update("remaining", 0).Error; err != nil {
+				return err
+			}
+			var member models.Member
+			if err := tx.Select("id", "points").First(&member, lot.MemberID).Error; err != nil {
+				return err
+			}
+			deduct := lot.Remaining
+			if deduct > member.Points {
+				deduct = member.Points
+			}
+			balance := member.Points - deduct
+			if err := tx.Model(&models.Member{}).Where("id = ?", member.ID).Update("points", balance).Error; err != nil {
+				return err
+			}
+			expired += deduct
+			return tx.Create(&models.PointsTransaction{
+				MemberID:     member.ID,
+				Type:         PointsExpire,
+				Points:       -deduct,
+				BalanceAfter: balance,
+				Remark:       fmt.Sprintf("%s 获得的积分已过期", lot.CreatedAt.Format("2006-01-02")),
+			}).Error
+		})
+		if err != nil {
+			return expired, fmt.Errorf("expire points entry %d: %w", lot.ID, err)
+		}
+	}
+	return expired, nil
+}
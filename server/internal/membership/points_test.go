@@ -0,0 +1,83 @@
+package membership
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+)
+
+func TestEarnForOrder_TierAndBirthdayMultipliers(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	birthday := time.Date(1990, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	// 默认等级中 silver 积分倍数为 1.2
+	member := models.Member{Name: "P", Phone: "11", InvitationCode: "P11", Level: "silver", Birthday: &birthday}
+	database.Create(&member)
+	order := models.Order{MemberID: member.ID, PaidAmount: 100, OrderType: "service"}
+	database.Create(&order)
+
+	entry, err := EarnForOrder(database, &member, &order, now)
+	if err != nil {
+		t.Fatalf("earn: %v", err)
+	}
+	want := int(100 * config.GlobalPointsPolicy.PointsPerYuan * 1.2 * config.GlobalPointsPolicy.BirthdayMultiplier)
+	if entry == nil || entry.Points != want || entry.Remaining != want {
+		t.Fatalf("expected %d points, got %+v", want, entry)
+	}
+
+	var reloaded models.Member
+	database.First(&reloaded, member.ID)
+	if reloaded.Points != want {
+		t.Fatalf("expected member points %d, got %d", want, reloaded.Points)
+	}
+}
+
+func TestRedeemAndExpire_ConsumeOldestPointsFirst(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Now()
+
+	member := models.Member{Name: "Q", Phone: "12", InvitationCode: "Q12", Level: "basic"}
+	database.Create(&member)
+	first := models.Order{MemberID: member.ID, PaidAmount: 300, OrderType: "service"}
+	second := models.Order{MemberID: member.ID, PaidAmount: 200, OrderType: "service"}
+	database.Create(&first)
+	database.Create(&second)
+
+	if _, err := EarnForOrder(database, &member, &first, now.AddDate(-1, 0, -1)); err != nil {
+		t.Fatalf("earn first: %v", err)
+	}
+	if _, err := EarnForOrder(database, &member, &second, now); err != nil {
+		t.Fatalf("earn second: %v", err)
+	}
+
+	// 抵扣 100 积分应从最早到期的一批中扣减
+	if _, value, err := Redeem(database, &member, 100, &second); err != nil || value != PointsValue(100) {
+		t.Fatalf("redeem: value=%v err=%v", value, err)
+	}
+	if _, _, err := Redeem(database, &member, 10000, &second); err != ErrInsufficientPoints {
+		t.Fatalf("expected insufficient points, got %v", err)
+	}
+
+	expired, err := ExpirePoints(database, now)
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if expired != 200 {
+		t.Fatalf("expected 200 remaining points of the first batch to expire, got %d", expired)
+	}
+
+	var reloaded models.Member
+	database.First(&reloaded, member.ID)
+	if reloaded.Points != 200 {
+		t.Fatalf("expected 200 points left, got %d", reloaded.Points)
+	}
+
+	var entries int64
+	database.Model(&models.PointsTransaction{}).Where("member_id = ?", member.ID).Count(&entries)
+	if entries != 4 {
+		t.Fatalf("expected earn/earn/redeem/expire entries, got %d", entries)
+	}
+}
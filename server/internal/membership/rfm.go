@@ -0,0 +1,182 @@
+package membership
+
+import (
+	"errors"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// RFM 分群
+const (
+	SegmentChampion    = "champion"    // 高价值：近期、高频、高消费
+	SegmentLoyal       = "loyal"       // 忠诚：消费频次高
+	SegmentNew         = "new"         // 新客：近期首次消费
+	SegmentPotential   = "potential"   // 潜力：近期有消费但频次/金额一般
+	SegmentAtRisk      = "at_risk"     // 流失预警：曾经高频但近期未到店
+	SegmentHibernating = "hibernating" // 沉睡：长期未到店且频次低
+	SegmentLost        = "lost"        // 流失：最长时间未到店
+	SegmentNoOrders    = "no_orders"   // 无消费记录
+)
+
+// Segments 所有分群，按价值从高到低排列
+var Segments = []string{
+	SegmentChampion, SegmentLoyal, SegmentNew, SegmentPotential,
+	SegmentAtRisk, SegmentHibernating, SegmentLost, SegmentNoOrders,
+}
+
+// RFMSummary 汇总一次 RFM 计算的结果
+type RFMSummary struct {
+	Evaluated int            `json:"evaluated"`
+	ChurnRisk int            `json:"churn_risk"`
+	Segments  map[string]int `json:"segments"`
+}
+
+// recencyScore 距上次消费越近得分越高（5 分最高）
+func recencyScore(days int, bands []int) int {
+	for i, limit := range bands {
+		if days <= limit {
+			return 5 - i
+		}
+	}
+	return 1
+}
+
+// ascendingScore 数值越大得分越高：未达到第一档得 1 分，达到第 i 档得 i+2 分
+func ascendingScore(value float64, bands []float64) int {
+	score := 1
+	for i, limit := range bands {
+		if value >= limit {
+			score = i + 2
+		}
+	}
+	return score
+}
+
+// segmentFor 根据 RFM 得分划分会员分群
+func segmentFor(r, f, m int) string {
+	switch {
+	case r >= 4 && f >= 4 && m >= 4:
+		return SegmentChampion
+	case r >= 3 && f >= 4:
+		return SegmentLoyal
+	case r >= 4 && f == 1:
+		return SegmentNew
+	case r >= 3:
+		return SegmentPotential
+	case f >= 3:
+		return SegmentAtRisk
+	case r == 2:
+		return SegmentHibernating
+	default:
+		return SegmentLost
+	}
+}
+
+type orderPoint struct {
+	MemberID   uint
+	CreatedAt  time.Time
+	PaidAmount float64
+}
+
+// scoreMember 根据会员的全部订单（按时间升序）计算 RFM 记录
+func scoreMember(memberID uint, orders []orderPoint, now time.Time) models.MemberRFM {
+	policy := config.GlobalRFMPolicy
+	record := models.MemberRFM{MemberID: memberID, DaysSinceLast: -1, Segment: SegmentNoOrders, CalculatedAt: now}
+	if len(orders) == 0 {
+		return record
+	}
+
+	last := orders[len(orders)-1].CreatedAt
+	record.LastOrderAt = &last
+	record.DaysSinceLast = int(now.Sub(last).Hours() / 24)
+
+	windowStart := now.AddDate(0, 0, -policy.WindowDays)
+	for _, o := range orders {
+		if !o.CreatedAt.Before(windowStart) {
+			record.OrderCount++
+			record.Monetary += o.PaidAmount
+		}
+	}
+	record.Monetary = util.RoundMoney(record.Monetary)
+
+	if len(orders) >= 2 {
+		span := last.Sub(orders[0].CreatedAt).Hours() / 24
+		record.AvgIntervalDays = util.RoundMoney(span / float64(len(orders)-1))
+		record.ChurnRisk = record.DaysSinceLast >= policy.ChurnMinDays &&
+			float64(record.DaysSinceLast) > policy.ChurnIntervalFactor*record.AvgIntervalDays
+	}
+
+	frequencyBands := make([]float64, len(policy.FrequencyCounts))
+	for i, v := range policy.FrequencyCounts {
+		frequencyBands[i] = float64(v)
+	}
+	record.RScore = recencyScore(record.DaysSinceLast, policy.RecencyDays)
+	record.FScore = ascendingScore(float64(record.OrderCount), frequencyBands)
+	record.MScore = ascendingScore(record.Monetary, policy.MonetaryAmounts)
+	record.Segment = segmentFor(record.RScore, record.FScore, record.MScore)
+	return record
+}
+
+// RecalculateRFM 基于订单数据为所有有效会员重新计算 RFM 得分、分群与流失风险
+func RecalculateRFM(database *gorm.DB, now time.Time) (RFMSummary, error) {
+	summary := RFMSummary{Segments: make(map[string]int, len(Segments))}
+	for _, s := range Segments {
+		summary.Segments[s] = 0
+	}
+
+	var members []models.Member
+	if err := database.Select("id").Where("is_active = ?", true).Find(&members).Error; err != nil {
+		return summary, err
+	}
+
+	var points []orderPoint
+	if err := database.Model(&models.Order{}).
+		Select("member_id, created_at, paid_amount").
+		Where("refunded_at IS NULL").
+		Order("member_id ASC, created_at ASC").
+		Scan(&points).Error; err != nil {
+		return summary, err
+	}
+	byMember := make(map[uint][]orderPoint)
+	for _, p := range points {
+		byMember[p.MemberID] = append(byMember[p.MemberID], p)
+	}
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		for _, m := range members {
+			record := scoreMember(m.ID, byMember[m.ID], now)
+
+			var existing models.MemberRFM
+			err := tx.Where("member_id = ?", m.ID).First(&existing).Error
+			switch {
+			case err == nil:
+				record.ID = existing.ID
+				record.CreatedAt = existing.CreatedAt
+				if err := tx.Save(&record).Error; err != nil {
+					return err
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err := tx.Create(&record).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+
+			summary.Evaluated++
+			summary.Segments[record.Segment]++
+			if record.ChurnRisk {
+				summary.ChurnRisk++
+			}
+		}
+		// 已停用会员不再参与分群（物理删除，重新启用后可再次写入）
+		return tx.Unscoped().Where("member_id NOT IN (?)", tx.Model(&models.Member{}).Select("id").Where("is_active = ?", true)).
+			Delete(&models.MemberRFM{}).Error
+	})
+	return summary, err
+}
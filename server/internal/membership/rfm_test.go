@@ -0,0 +1,67 @@
+package membership
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestRecalculateRFM_SegmentsAndChurnRisk(t *testing.T) {
+	database := setupLevelTestDB(t)
+	now := time.Now()
+
+	regular := models.Member{Name: "R", Phone: "31", InvitationCode: "R31"}
+	drifting := models.Member{Name: "D", Phone: "32", InvitationCode: "D32"}
+	idle := models.Member{Name: "I", Phone: "33", InvitationCode: "I33"}
+	database.Create(&regular)
+	database.Create(&drifting)
+	database.Create(&idle)
+
+	seq := uint(1)
+	// 常客：每 10 天到店一次，最近一次 5 天前
+	for i := 0; i < 12; i++ {
+		createOrder(t, database, regular.ID, 400, now.AddDate(0, 0, -5-10*i), seq)
+		seq++
+	}
+	// 原本每 7 天到店一次，已 70 天未到店
+	for i := 0; i < 5; i++ {
+		createOrder(t, database, drifting.ID, 200, now.AddDate(0, 0, -70-7*i), seq)
+		seq++
+	}
+
+	summary, err := RecalculateRFM(database, now)
+	if err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if summary.Evaluated != 3 || summary.ChurnRisk != 1 {
+		t.Fatalf("expected 3 evaluated and 1 churn risk, got %+v", summary)
+	}
+
+	var records []models.MemberRFM
+	database.Find(&records)
+	byMember := map[uint]models.MemberRFM{}
+	for _, r := range records {
+		byMember[r.MemberID] = r
+	}
+
+	if r := byMember[regular.ID]; r.Segment != SegmentChampion || r.ChurnRisk {
+		t.Fatalf("expected regular to be a champion without churn risk, got %+v", r)
+	}
+	if r := byMember[drifting.ID]; !r.ChurnRisk || r.AvgIntervalDays != 7 || r.RScore != 3 {
+		t.Fatalf("expected drifting member flagged with 7-day norm, got %+v", r)
+	}
+	if r := byMember[idle.ID]; r.Segment != SegmentNoOrders || r.DaysSinceLast != -1 {
+		t.Fatalf("expected idle member without orders, got %+v", r)
+	}
+
+	// 重算会更新已有记录而不是重复插入
+	if _, err := RecalculateRFM(database, now); err != nil {
+		t.Fatalf("recalculate again: %v", err)
+	}
+	var count int64
+	database.Model(&models.MemberRFM{}).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected 3 rfm records after rerun, got %d", count)
+	}
+}
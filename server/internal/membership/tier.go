@@ -0,0 +1,99 @@
+package membership
+
+import (
+	"encoding/json"
+	"sort"
+
+	"server/internal/models"
+	"server/pkg/config"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// DefaultTiers 将出厂默认配置转换为等级记录（按阈值从低到高）
+func DefaultTiers() []models.MemberTier {
+	tiers := make([]models.MemberTier, 0, len(config.DefaultMemberTiers))
+	for _, d := range config.DefaultMemberTiers {
+		perks, _ := json.Marshal(d.Perks)
+		if d.Perks == nil {
+			perks = []byte("[]")
+		}
+		tiers = append(tiers, models.MemberTier{
+			Name:                 d.Name,
+			DisplayName:          d.DisplayName,
+			Threshold:            d.Threshold,
+			ServiceDiscount:      d.ServiceDiscount,
+			ProductDiscount:      d.ProductDiscount,
+			CommissionMultiplier: d.CommissionMultiplier,
+			PointsMultiplier:     d.PointsMultiplier,
+			Perks:                datatypes.JSON(perks),
+		})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Threshold < tiers[j].Threshold })
+	return tiers
+}
+
+// SeedDefaultTiers 等级表为空时写入默认等级
+func SeedDefaultTiers(database *gorm.DB) error {
+	var count int64
+	if err := database.Model(&models.MemberTier{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	tiers := DefaultTiers()
+	return database.Create(&tiers).Error
+}
+
+// Tiers 返回按升级阈值从低到高排序的全部等级；等级表为空时退回默认配置
+func Tiers(tx *gorm.DB) ([]models.MemberTier, error) {
+	var tiers []models.MemberTier
+	if err := tx.Order("threshold ASC").Find(&tiers).Error; err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return DefaultTiers(), nil
+	}
+	return tiers, nil
+}
+
+// tierRank 返回等级在列表中的序号，未知等级视为最低等级
+func tierRank(tiers []models.MemberTier, name string) int {
+	for i, t := range tiers {
+		if t.Name == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// tierForConsumption 返回消费额可达到的最高等级序号（消费额需超过阈值）
+func tierForConsumption(tiers []models.MemberTier, consumption float64) int {
+	rank := 0
+	for i, t := range tiers {
+		if consumption > t.Threshold {
+			rank = i
+		}
+	}
+	return rank
+}
+
+// FindTier 查询会员当前等级的配置，未知等级按最低等级处理
+func FindTier(tx *gorm.DB, level string) (models.MemberTier, error) {
+	tiers, err := Tiers(tx)
+	if err != nil {
+		return models.MemberTier{}, err
+	}
+	return tiers[tierRank(tiers, level)], nil
+}
+
+// LevelFor 根据统计期内消费额计算应达到的等级
+func LevelFor(tx *gorm.DB, consumption float64) (string, error) {
+	tiers, err := Tiers(tx)
+	if err != nil {
+		return "", err
+	}
+	return tiers[tierForConsumption(tiers, consumption)].Name, nil
+}
@@ -27,13 +27,137 @@ type BaseModel struct {
 // Member represents a customer profile with referral metadata.
 type Member struct {
 	BaseModel
-	Name                   string  `gorm:"size:64;not null" json:"name"`
-	Phone                  string  `gorm:"size:32;uniqueIndex;not null" json:"phone"`
-	Level                  string  `gorm:"size:32;default:basic" json:"level"`
-	YearlyTotalConsumption float64 `gorm:"type:decimal(12,2);default:0" json:"yearly_total_consumption"`
-	Balance                float64 `gorm:"type:decimal(12,2);default:0" json:"balance"`
-	InvitationCode         string  `gorm:"size:32;uniqueIndex" json:"invitation_code"`
-	ReferrerID             *uint   `json:"referrer_id"`
+	Name                   string     `gorm:"size:64;not null" json:"name"`
+	Phone                  string     `gorm:"size:32;uniqueIndex;not null" json:"phone"`
+	Level                  string     `gorm:"size:32;default:basic" json:"level"`
+	YearlyTotalConsumption float64    `gorm:"type:decimal(12,2);default:0" json:"yearly_total_consumption"`
+	Balance                float64    `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	CommissionBalance      float64    `gorm:"type:decimal(12,2);default:0" json:"commission_balance"` // 佣金钱包余额：可申请提现，不可用于消费
+	InvitationCode         string     `gorm:"size:32;uniqueIndex" json:"invitation_code"`
+	ReferrerID             *uint      `json:"referrer_id"`
+	ReferralBoundAt        *time.Time `json:"referral_bound_at,omitempty"`           // 推荐关系绑定时间
+	ReferralBoundBy        *uint      `json:"referral_bound_by,omitempty"`           // 绑定推荐关系的操作员ID
+	IsActive               bool       `gorm:"default:true" json:"is_active"`         // 是否有效（停用后保留历史数据）
+	MergedIntoID           *uint      `gorm:"index" json:"merged_into_id,omitempty"` // 被合并时指向保留的会员
+	LevelGraceUntil        *time.Time `json:"level_grace_until,omitempty"`           // 保级期截止时间，到期仍未达标则降级
+	Points                 int        `gorm:"default:0" json:"points"`               // 当前可用积分
+	// 会员档案：生日、性别与联系偏好，供生日/入会周年营销活动及生日积分倍数使用
+	Birthday         *time.Time `gorm:"type:date" json:"birthday,omitempty"`  // 生日（仅月日参与生日权益判断）
+	Gender           string     `gorm:"size:8" json:"gender"`                 // "male", "female" 或空（未填写）
+	PreferredContact string     `gorm:"size:16" json:"preferred_contact"`     // 偏好联系方式："sms", "wechat", "phone"
+	MarketingOptIn   bool       `gorm:"default:true" json:"marketing_opt_in"` // 是否愿意接收营销通知
+	Tags             []Tag      `gorm:"many2many:member_tags" json:"tags,omitempty"`
+}
+
+// MemberLevelChange records every level change together with the reason behind it.
+type MemberLevelChange struct {
+	BaseModel
+	MemberID    uint    `gorm:"index;not null" json:"member_id"`
+	FromLevel   string  `gorm:"size:32;not null" json:"from_level"`
+	ToLevel     string  `gorm:"size:32;not null" json:"to_level"`
+	Consumption float64 `gorm:"type:decimal(12,2);not null;default:0" json:"consumption"` // 评估时窗口内的消费额
+	Source      string  `gorm:"size:32;not null" json:"source"`                           // "settlement"(结算), "job"(定时任务), "merge"(合并)
+	Reason      string  `gorm:"size:255" json:"reason"`
+}
+
+// MemberTier configures a member level: its upgrade threshold and the benefits attached to it.
+// Tiers are ranked by threshold; Member.Level stores the tier name.
+type MemberTier struct {
+	BaseModel
+	Name                 string         `gorm:"size:32;uniqueIndex;not null" json:"name"`
+	DisplayName          string         `gorm:"size:64" json:"display_name"`
+	Threshold            float64        `gorm:"type:decimal(12,2);uniqueIndex;not null;default:0" json:"threshold"` // 统计期内消费额超过该值即可升级
+	ServiceDiscount      float64        `gorm:"type:decimal(5,4);not null;default:1" json:"service_discount"`
+	ProductDiscount      float64        `gorm:"type:decimal(5,4);not null;default:1" json:"product_discount"`
+	CommissionMultiplier float64        `gorm:"type:decimal(5,2);not null;default:1" json:"commission_multiplier"` // 作为推荐人时佣金比例的倍数
+	PointsMultiplier     float64        `gorm:"type:decimal(5,2);not null;default:1" json:"points_multiplier"`     // 消费获得积分的倍数
+	Perks                datatypes.JSON `gorm:"type:json" json:"perks"`
+}
+
+// PointsTransaction is one entry in a member's points ledger.
+// Earn entries track how many of their points are still unused so redemption and expiry consume them oldest first.
+type PointsTransaction struct {
+	BaseModel
+	MemberID     uint       `gorm:"index;not null" json:"member_id"`
+	Type         string     `gorm:"size:16;not null;index" json:"type"`  // "earn"(消费获得), "grant"(赠送), "redeem"(抵扣), "expire"(过期), "refund"(退款退回), "revoke"(退款收回)
+	Points       int        `gorm:"not null" json:"points"`              // 正数为增加，负数为扣减
+	Remaining    int        `gorm:"not null;default:0" json:"remaining"` // 获得记录中尚未使用或过期的积分
+	BalanceAfter int        `gorm:"not null" json:"balance_after"`
+	ExpiresAt    *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	OrderID      *uint      `gorm:"index" json:"order_id,omitempty"`
+	Remark       string     `gorm:"size:255" json:"remark"`
+}
+
+// Coupon is a member voucher issued by a campaign.
+type Coupon struct {
+	BaseModel
+	MemberID  uint       `gorm:"index;not null" json:"member_id"`
+	Code      string     `gorm:"size:32;uniqueIndex;not null" json:"code"`
+	Title     string     `gorm:"size:64;not null" json:"title"`
+	Amount    float64    `gorm:"type:decimal(10,2);not null" json:"amount"`              // 面额（单位：元）
+	MinSpend  float64    `gorm:"type:decimal(10,2);not null;default:0" json:"min_spend"` // 使用门槛
+	ExpiresAt time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	OrderID   *uint      `gorm:"index" json:"order_id,omitempty"` // 核销的订单
+}
+
+// CampaignIssue logs one reward issued by a member campaign; the unique index keeps
+// the daily job from issuing the same campaign twice in one year.
+type CampaignIssue struct {
+	BaseModel
+	MemberID      uint    `gorm:"uniqueIndex:idx_campaign_member_year;not null" json:"member_id"`
+	Member        Member  `gorm:"foreignKey:MemberID" json:"member"`
+	Campaign      string  `gorm:"size:32;uniqueIndex:idx_campaign_member_year;not null" json:"campaign"` // "birthday"(生日), "anniversary"(入会周年)
+	Year          int     `gorm:"uniqueIndex:idx_campaign_member_year;not null" json:"year"`             // 活动所属年份
+	RewardType    string  `gorm:"size:16;not null" json:"reward_type"`                                   // "coupon"(优惠券), "balance"(赠送余额)
+	Amount        float64 `gorm:"type:decimal(10,2);not null" json:"amount"`
+	CouponID      *uint   `json:"coupon_id,omitempty"`
+	NotifyChannel string  `gorm:"size:16" json:"notify_channel"` // 需通知的渠道，会员拒收营销通知时为空
+	Remark        string  `gorm:"size:255" json:"remark"`
+}
+
+// MemberRFM stores the latest recency/frequency/monetary scores and segment of a member.
+type MemberRFM struct {
+	BaseModel
+	MemberID        uint       `gorm:"uniqueIndex;not null" json:"member_id"`
+	Member          Member     `gorm:"foreignKey:MemberID" json:"member"`
+	LastOrderAt     *time.Time `json:"last_order_at,omitempty"`
+	DaysSinceLast   int        `json:"days_since_last"`                                      // 距上次消费天数（无消费时为 -1）
+	OrderCount      int        `json:"order_count"`                                          // 统计窗口内消费次数
+	Monetary        float64    `gorm:"type:decimal(12,2);default:0" json:"monetary"`         // 统计窗口内实付金额
+	AvgIntervalDays float64    `gorm:"type:decimal(8,2);default:0" json:"avg_interval_days"` // 个人平均消费间隔（少于两次消费时为 0）
+	RScore          int        `json:"r_score"`
+	FScore          int        `json:"f_score"`
+	MScore          int        `json:"m_score"`
+	Segment         string     `gorm:"size:32;index" json:"segment"` // champion/loyal/new/potential/at_risk/hibernating/lost/no_orders
+	ChurnRisk       bool       `gorm:"index" json:"churn_risk"`      // 未到店时长明显超过个人消费节奏
+	CalculatedAt    time.Time  `json:"calculated_at"`
+}
+
+// Tag is a free-form staff label attached to members (e.g. "VIP", "精油过敏").
+type Tag struct {
+	BaseModel
+	Name  string `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	Color string `gorm:"size:16" json:"color"`
+}
+
+// SavedSegment is a named member list defined by a filter over member fields, order history and tags.
+type SavedSegment struct {
+	BaseModel
+	Name        string         `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	Description string         `gorm:"size:255" json:"description"`
+	Filter      datatypes.JSON `gorm:"type:json;not null" json:"filter"` // 筛选条件树，见 internal/segment
+	CreatedBy   *uint          `json:"created_by,omitempty"`
+}
+
+// SegmentActionLog records a bulk action applied to the members of a saved segment.
+type SegmentActionLog struct {
+	BaseModel
+	SegmentID   uint           `gorm:"index;not null" json:"segment_id"`
+	Action      string         `gorm:"size:32;not null" json:"action"` // add_tag/remove_tag/gift_balance/grant_points/issue_coupon
+	Params      datatypes.JSON `gorm:"type:json" json:"params"`
+	MemberCount int            `gorm:"not null" json:"member_count"`
+	OperatorID  *uint          `json:"operator_id,omitempty"`
 }
 
 // Technician holds skill tags and availability state.
@@ -55,6 +179,17 @@ type ServiceProduct struct {
 	Price    float64 `gorm:"type:decimal(10,2);not null" json:"price"`
 	IsActive bool    `gorm:"default:true" json:"is_active"`
 	ImageURL string  `gorm:"size:255" json:"image_url"` // 服务图片
+	// Contraindications 不适用该服务的健康状况代码列表（见 health 包），如 ["pregnancy","hypertension"]
+	Contraindications datatypes.JSON `gorm:"type:json" json:"contraindications"`
+}
+
+// ServiceMaterial is one line of a service's bill of materials: a product consumed every time the service is performed.
+type ServiceMaterial struct {
+	BaseModel
+	ServiceID uint            `gorm:"uniqueIndex:idx_service_material;not null" json:"service_id"`
+	ProductID uint            `gorm:"uniqueIndex:idx_service_material;not null" json:"product_id"`
+	Product   PhysicalProduct `gorm:"foreignKey:ProductID" json:"product"`
+	Quantity  int             `gorm:"not null" json:"quantity"` // 每次服务消耗数量
 }
 
 // Appointment captures booking details and pricing.
@@ -74,6 +209,32 @@ type Appointment struct {
 	PaymentMethod  string         `gorm:"size:32" json:"payment_method"`                    // balance/cash/mixed
 	PaidBalance    float64        `gorm:"type:decimal(10,2);default:0" json:"paid_balance"` // 余额支付金额
 	PaidCash       float64        `gorm:"type:decimal(10,2);default:0" json:"paid_cash"`    // 现金支付金额
+	PaidPoints     int            `gorm:"default:0" json:"paid_points"`                     // 抵扣使用的积分
+	HealthWarnings []string       `gorm:"-" json:"health_warnings,omitempty"`               // 创建预约时的健康禁忌提醒，不落库
+}
+
+// HealthIntake is one version of a member's health questionnaire; every save adds a new version.
+type HealthIntake struct {
+	BaseModel
+	MemberID    uint           `gorm:"not null;uniqueIndex:idx_member_intake_version" json:"member_id"`
+	Version     int            `gorm:"not null;uniqueIndex:idx_member_intake_version" json:"version"`
+	Conditions  datatypes.JSON `gorm:"type:json" json:"conditions"` // 健康状况代码列表，如 ["pregnancy","allergy"]
+	Allergies   string         `gorm:"size:255" json:"allergies"`   // 过敏原描述
+	Injuries    string         `gorm:"size:255" json:"injuries"`    // 伤病部位与情况
+	Medications string         `gorm:"size:255" json:"medications"` // 正在服用的药物
+	Notes       string         `gorm:"type:text" json:"notes"`
+	RecordedBy  *uint          `json:"recorded_by,omitempty"` // 录入的操作员ID
+}
+
+// TreatmentNote is a technician's note for a single appointment.
+type TreatmentNote struct {
+	BaseModel
+	AppointmentID uint       `gorm:"index;not null" json:"appointment_id"`
+	MemberID      uint       `gorm:"index;not null" json:"member_id"`
+	TechID        uint       `gorm:"index;not null" json:"tech_id"`
+	Technician    Technician `gorm:"foreignKey:TechID" json:"technician"`
+	Content       string     `gorm:"type:text;not null" json:"content"`
+	AuthorID      *uint      `json:"author_id,omitempty"` // 提交记录的登录用户ID
 }
 
 type Order struct {
@@ -84,11 +245,17 @@ type Order struct {
 	Inviter          *Member       `gorm:"foreignKey:InviterID" json:"inviter,omitempty"`
 	PaidAmount       float64       `gorm:"type:decimal(12,2);not null" json:"paid_amount"`
 	CommissionAmount float64       `gorm:"type:decimal(12,2);not null;default:0" json:"commission_amount"`
+	PointsDeduction  float64       `gorm:"type:decimal(12,2);not null;default:0" json:"points_deduction"` // 积分抵扣金额（不计入实付）
+	CouponID         *uint         `gorm:"index" json:"coupon_id,omitempty"`                              // 使用的优惠券
+	CouponDeduction  float64       `gorm:"type:decimal(12,2);not null;default:0" json:"coupon_deduction"` // 优惠券抵扣金额（不计入实付）
 	OrderType        string        `gorm:"size:16;not null;index;check:chk_orders_valid,((order_type IN ('service','physical')) AND (paid_amount >= 0) AND (commission_amount >= 0) AND (commission_amount <= paid_amount) AND ((order_type='service' AND appointment_id IS NOT NULL AND inventory_log_id IS NULL) OR (order_type='physical' AND inventory_log_id IS NOT NULL AND appointment_id IS NULL)))" json:"order_type"`
 	AppointmentID    *uint         `gorm:"uniqueIndex;index" json:"appointment_id,omitempty"`
 	Appointment      *Appointment  `gorm:"foreignKey:AppointmentID" json:"appointment,omitempty"`
 	InventoryLogID   *uint         `gorm:"uniqueIndex;index" json:"inventory_log_id,omitempty"`
 	InventoryLog     *InventoryLog `gorm:"foreignKey:InventoryLogID" json:"inventory_log,omitempty"`
+	RefundedAt       *time.Time    `gorm:"index" json:"refunded_at,omitempty"` // 退款时间，为空表示未退款
+	RefundReason     string        `gorm:"size:255" json:"refund_reason,omitempty"`
+	CostAmount       *float64      `gorm:"type:decimal(12,2)" json:"cost_amount,omitempty"` // 商品订单为销售成本，服务订单为消耗的耗材成本；为空表示未核算成本
 }
 
 // Schedule represents a technician's daily availability
@@ -103,21 +270,99 @@ type Schedule struct {
 // FissionLog stores commission payouts for referral fission events.
 type FissionLog struct {
 	BaseModel
-	InviterID        uint    `gorm:"index;not null" json:"inviter_id"`
-	InviteeID        uint    `gorm:"index;not null" json:"invitee_id"`
+	InviterID        uint    `gorm:"index;not null" json:"inviter_id"` // 佣金受益人
+	InviteeID        uint    `gorm:"index;not null" json:"invitee_id"` // 下单会员
 	CommissionAmount float64 `gorm:"type:decimal(10,2);not null" json:"commission_amount"`
+	OrderID          *uint   `gorm:"index" json:"order_id,omitempty"`         // 产生佣金的订单
+	Level            int     `gorm:"not null;default:1" json:"level"`         // 推荐层级：1 为直接推荐人
+	Rate             float64 `gorm:"type:decimal(6,4);default:0" json:"rate"` // 实际适用的佣金比例（含等级倍数）
+	// Status 佣金状态：pending(持有期内) -> confirmed(持有期满) -> paid(已计入推荐人佣金钱包)，或 reversed(订单退款撤销)
+	// 数据库默认值为 paid，使引入持有期前已直接入账的历史记录保持已发放状态
+	Status        string     `gorm:"size:16;index;default:'paid'" json:"status"`
+	AvailableAt   *time.Time `gorm:"index" json:"available_at,omitempty"` // 持有期截止时间
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	ReversedAt    *time.Time `json:"reversed_at,omitempty"`
+	ReverseReason string     `gorm:"size:255" json:"reverse_reason,omitempty"`
+}
+
+// ReferralProgram is the configured multi-level referral commission scheme (single row).
+type ReferralProgram struct {
+	BaseModel
+	ServiceRates datatypes.JSON `gorm:"type:json" json:"service_rates"`                     // 服务订单各级佣金比例，下标 0 为直接推荐人
+	ProductRates datatypes.JSON `gorm:"type:json" json:"product_rates"`                     // 商品订单各级佣金比例
+	MaxPerOrder  float64        `gorm:"type:decimal(12,2);default:0" json:"max_per_order"`  // 单笔订单佣金合计上限，0 不限
+	MaxPerPeriod float64        `gorm:"type:decimal(12,2);default:0" json:"max_per_period"` // 每位受益人周期内佣金上限，0 不限
+	PeriodDays   int            `gorm:"default:30" json:"period_days"`                      // 周期上限的统计天数
+}
+
+// CommissionWithdrawal is a referrer's request to cash out the commission wallet.
+// 申请时即从佣金钱包扣除（冻结）金额，驳回时退回钱包
+type CommissionWithdrawal struct {
+	BaseModel
+	MemberID uint    `gorm:"index;not null" json:"member_id"`
+	Member   Member  `gorm:"foreignKey:MemberID" json:"member"`
+	Amount   float64 `gorm:"type:decimal(12,2);not null" json:"amount"`
+	// Status 提现状态：pending(待审核) -> approved(已审核待打款) -> paid(已打款)，或 rejected(已驳回)
+	Status       string     `gorm:"size:16;index;not null;default:'pending'" json:"status"`
+	Method       string     `gorm:"size:16;not null" json:"method"` // 打款方式："bank", "wechat", "alipay", "cash"
+	Account      string     `gorm:"size:128" json:"account"`        // 收款账号（现金打款可为空）
+	Note         string     `gorm:"size:255" json:"note"`
+	RequestedBy  *uint      `json:"requested_by,omitempty"` // 代为提交申请的操作员ID
+	ReviewedBy   *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	RejectReason string     `gorm:"size:255" json:"reject_reason,omitempty"`
+	PaidBy       *uint      `json:"paid_by,omitempty"`
+	PaidAt       *time.Time `json:"paid_at,omitempty"`
+	PayoutRef    string     `gorm:"size:128" json:"payout_ref,omitempty"` // 打款流水号/凭证号
+}
+
+// ProductCategory groups physical products in the catalog.
+type ProductCategory struct {
+	BaseModel
+	Name        string `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	Description string `gorm:"size:255" json:"description"`
+	SortOrder   int    `gorm:"not null;default:0" json:"sort_order"` // 排序，数值小的在前
+}
+
+// ParentProduct holds the catalog information shared by the variants of a product sold in several sizes or scents.
+// Stock, prices and SKU live on the variants, which are PhysicalProduct rows pointing at the parent.
+type ParentProduct struct {
+	BaseModel
+	Name        string            `gorm:"size:128;not null" json:"name"`
+	Description string            `gorm:"size:500" json:"description"`
+	ImageURL    string            `gorm:"size:255" json:"image_url"`
+	CategoryID  *uint             `gorm:"index" json:"category_id"`
+	Category    *ProductCategory  `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Variants    []PhysicalProduct `gorm:"foreignKey:ParentID" json:"variants,omitempty"`
 }
 
 // PhysicalProduct represents physical products for sale in the store.
+// A product with a parent is a variant: its name, category, description and image follow the parent.
 type PhysicalProduct struct {
 	BaseModel
 	Name        string  `gorm:"size:128;not null" json:"name"`
 	Stock       int     `gorm:"not null;default:0" json:"stock"`                         // 库存数量
 	RetailPrice float64 `gorm:"type:decimal(10,2);not null" json:"retail_price"`         // 零售价
-	CostPrice   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"cost_price"` // 进货价
+	CostPrice   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"cost_price"` // 移动加权平均成本，每次带单价入库时重新加权
 	Description string  `gorm:"size:500" json:"description"`                             // 商品描述
 	IsActive    bool    `gorm:"default:true" json:"is_active"`                           // 是否上架
 	ImageURL    string  `gorm:"size:255" json:"image_url"`                               // 商品图片
+	// ReorderPoint 补货点：库存降至该值及以下即视为低库存并触发补货提醒
+	ReorderPoint int `gorm:"not null;default:10" json:"reorder_point"`
+	// TargetStock 补货目标库存，建议补货量补足到该值；为 0 时按近期销售速度估算
+	TargetStock int `gorm:"not null;default:0" json:"target_stock"`
+	// CategoryID 商品分类，为空表示未分类
+	CategoryID *uint            `gorm:"index" json:"category_id"`
+	Category   *ProductCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	// SKU/Barcode 为空时存 NULL，唯一索引只约束已填写的编码
+	SKU     *string `gorm:"column:sku;size:64;uniqueIndex" json:"sku"`
+	Barcode *string `gorm:"size:64;uniqueIndex" json:"barcode"` // 商品条码，前台扫码查询
+	// ParentID 所属父商品，为空表示独立商品
+	ParentID    *uint          `gorm:"index" json:"parent_id"`
+	Parent      *ParentProduct `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
+	VariantName string         `gorm:"size:64" json:"variant_name"` // 规格名称，如 "10ml 薰衣草"
+	Options     datatypes.JSON `gorm:"type:json" json:"options"`    // 规格属性，如 {"size":"10ml","scent":"lavender"}
 }
 
 // InventoryLog records all inventory changes for physical products.
@@ -130,9 +375,127 @@ type InventoryLog struct {
 	MemberID     *uint           `gorm:"index" json:"member_id"` // 购买者ID（销售时可选）
 	Member       *Member         `gorm:"foreignKey:MemberID" json:"member,omitempty"`
 	ChangeAmount int             `gorm:"not null" json:"change_amount"`                   // 变动数量（正数为入库，负数为出库）
-	ActionType   string          `gorm:"size:32;not null" json:"action_type"`             // "restock"(到货), "sale"(销售), "adjustment"(纠错)
+	ActionType   string          `gorm:"size:32;not null" json:"action_type"`             // "restock"(到货), "sale"(销售), "adjustment"(纠错), "consumption"(服务耗材), "return"(退款退货)
 	BeforeStock  int             `gorm:"not null" json:"before_stock"`                    // 变动前库存
 	AfterStock   int             `gorm:"not null" json:"after_stock"`                     // 变动后库存
 	SaleAmount   *float64        `gorm:"type:decimal(10,2)" json:"sale_amount,omitempty"` // 销售金额（销售时可选）
 	Remark       string          `gorm:"size:255" json:"remark"`                          // 备注
+	// PurchaseOrderLineID 采购收货产生的入库记录关联的采购单明细
+	PurchaseOrderLineID *uint `gorm:"index" json:"purchase_order_line_id,omitempty"`
+	// UnitCost 入库时为入库单价，出库时为变动时的移动加权平均成本；为空表示未记录
+	UnitCost *float64 `gorm:"type:decimal(10,2)" json:"unit_cost,omitempty"`
+	// CostAmount 销售出库的销售成本（出库数量 × 单位成本）
+	CostAmount *float64 `gorm:"type:decimal(12,2)" json:"cost_amount,omitempty"`
+	// AppointmentID 服务结算自动扣减耗材时关联的预约
+	AppointmentID *uint `gorm:"index" json:"appointment_id,omitempty"`
+	// StocktakeID 盘点审核产生的差异调整关联的盘点单
+	StocktakeID *uint `gorm:"index" json:"stocktake_id,omitempty"`
+	// ReasonCode 调整原因代码，盘点差异调整时必填
+	ReasonCode string `gorm:"size:32" json:"reason_code,omitempty"`
+}
+
+// ProductBatch is a received lot of a physical product with its own expiry date.
+// 批次剩余数量之和不超过商品库存；差额为引入批次管理前入库、未分批的库存
+type ProductBatch struct {
+	BaseModel
+	ProductID           uint            `gorm:"index;not null" json:"product_id"`
+	Product             PhysicalProduct `gorm:"foreignKey:ProductID" json:"product"`
+	BatchNo             string          `gorm:"size:64;index;not null" json:"batch_no"`       // 批号
+	Quantity            int             `gorm:"not null" json:"quantity"`                     // 入库数量
+	Remaining           int             `gorm:"not null" json:"remaining"`                    // 剩余数量
+	ExpiryDate          *time.Time      `gorm:"type:date;index" json:"expiry_date,omitempty"` // 到期日，为空表示无保质期
+	ReceivedAt          time.Time       `gorm:"not null" json:"received_at"`
+	UnitCost            *float64        `gorm:"type:decimal(10,2)" json:"unit_cost,omitempty"` // 入库单价
+	InventoryLogID      *uint           `gorm:"index" json:"inventory_log_id,omitempty"`       // 入库日志
+	PurchaseOrderLineID *uint           `gorm:"index" json:"purchase_order_line_id,omitempty"`
+}
+
+// StockAlert is raised when an outbound change takes a product's stock from above its reorder point
+// to at or below it; it is resolved once stock is replenished above the reorder point again.
+type StockAlert struct {
+	BaseModel
+	ProductID      uint            `gorm:"index;not null" json:"product_id"`
+	Product        PhysicalProduct `gorm:"foreignKey:ProductID" json:"product"`
+	InventoryLogID *uint           `gorm:"index" json:"inventory_log_id,omitempty"` // 触发提醒的出库日志
+	Stock          int             `gorm:"not null" json:"stock"`                   // 触发时的库存
+	ReorderPoint   int             `gorm:"not null" json:"reorder_point"`           // 触发时的补货点
+	Status         string          `gorm:"size:16;not null;index" json:"status"`    // "open"(待补货), "resolved"(已补货)
+	ResolvedAt     *time.Time      `json:"resolved_at,omitempty"`
+}
+
+// BatchConsumption records how many units an outbound inventory change took from each batch.
+type BatchConsumption struct {
+	BaseModel
+	InventoryLogID uint `gorm:"index;not null" json:"inventory_log_id"`
+	BatchID        uint `gorm:"index;not null" json:"batch_id"`
+	Quantity       int  `gorm:"not null" json:"quantity"`
+}
+
+// Supplier is a vendor that physical products are purchased from.
+type Supplier struct {
+	BaseModel
+	Name        string `gorm:"size:128;uniqueIndex;not null" json:"name"`
+	ContactName string `gorm:"size:64" json:"contact_name"` // 联系人
+	Phone       string `gorm:"size:32" json:"phone"`
+	Email       string `gorm:"size:128" json:"email"`
+	Address     string `gorm:"size:255" json:"address"`
+	Note        string `gorm:"size:255" json:"note"`
+	IsActive    bool   `gorm:"default:true" json:"is_active"` // 停用后不可再下新采购单
+}
+
+// PurchaseOrder is an order of physical products placed with a supplier.
+type PurchaseOrder struct {
+	BaseModel
+	OrderNo    string   `gorm:"size:32;uniqueIndex" json:"order_no"` // 采购单号，创建后生成
+	SupplierID uint     `gorm:"index;not null" json:"supplier_id"`
+	Supplier   Supplier `gorm:"foreignKey:SupplierID" json:"supplier"`
+	// Status 采购单状态：draft(草稿) -> ordered(已下单) -> partially_received(部分到货) -> received(全部到货)，未到货前可 cancelled(取消)
+	Status      string              `gorm:"size:24;index;not null;default:'draft'" json:"status"`
+	ExpectedAt  *time.Time          `json:"expected_at,omitempty"` // 预计到货时间
+	OrderedAt   *time.Time          `json:"ordered_at,omitempty"`
+	ReceivedAt  *time.Time          `json:"received_at,omitempty"` // 全部到货时间
+	TotalAmount float64             `gorm:"type:decimal(12,2);not null;default:0" json:"total_amount"`
+	Remark      string              `gorm:"size:255" json:"remark"`
+	CreatedBy   *uint               `json:"created_by,omitempty"`
+	Lines       []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+}
+
+// PurchaseOrderLine is one product line of a purchase order.
+type PurchaseOrderLine struct {
+	BaseModel
+	PurchaseOrderID  uint            `gorm:"index;not null" json:"purchase_order_id"`
+	ProductID        uint            `gorm:"index;not null" json:"product_id"`
+	Product          PhysicalProduct `gorm:"foreignKey:ProductID" json:"product"`
+	Quantity         int             `gorm:"not null" json:"quantity"`                     // 采购数量
+	ReceivedQuantity int             `gorm:"not null;default:0" json:"received_quantity"`  // 已到货数量
+	UnitCost         float64         `gorm:"type:decimal(10,2);not null" json:"unit_cost"` // 采购单价
+}
+
+// Stocktake is an inventory count session. Expected stock is snapshotted when the session is opened;
+// on approval each counted variance is posted as an adjustment log.
+type Stocktake struct {
+	BaseModel
+	StocktakeNo string `gorm:"size:32;uniqueIndex" json:"stocktake_no"` // 盘点单号，创建后生成
+	// Status 盘点状态：counting(盘点中) -> approved(已审核过账)，盘点中可 cancelled(取消)
+	Status     string          `gorm:"size:16;index;not null;default:'counting'" json:"status"`
+	Remark     string          `gorm:"size:255" json:"remark"`
+	CreatedBy  *uint           `json:"created_by,omitempty"`
+	ApprovedBy *uint           `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time      `json:"approved_at,omitempty"`
+	Lines      []StocktakeLine `gorm:"foreignKey:StocktakeID" json:"lines"`
+}
+
+// StocktakeLine is one product of a stocktake with its snapshotted and counted quantities.
+type StocktakeLine struct {
+	BaseModel
+	StocktakeID     uint            `gorm:"uniqueIndex:idx_stocktake_product;not null" json:"stocktake_id"`
+	ProductID       uint            `gorm:"uniqueIndex:idx_stocktake_product;not null" json:"product_id"`
+	Product         PhysicalProduct `gorm:"foreignKey:ProductID" json:"product"`
+	ExpectedStock   int             `gorm:"not null" json:"expected_stock"` // 账面库存：开始盘点时快照，录入实盘时按当时库存重新快照
+	CountedQuantity *int            `json:"counted_quantity"`               // 实盘数量，为空表示尚未盘点
+	ReasonCode      string          `gorm:"size:32" json:"reason_code"`     // 差异原因代码
+	Remark          string          `gorm:"size:255" json:"remark"`
+	CountedBy       *uint           `json:"counted_by,omitempty"`
+	CountedAt       *time.Time      `json:"counted_at,omitempty"`
+	InventoryLogID  *uint           `json:"inventory_log_id,omitempty"` // 审核时生成的差异调整日志
 }
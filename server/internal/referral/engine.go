@@ -0,0 +1,194 @@
+// Package referral 实现多级推荐分佣：按推荐方案沿推荐链逐级计算佣金，并为每位受益人记录分销日志
+package referral
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"server/internal/membership"
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Share 一位受益人在一笔订单中应得的佣金
+type Share struct {
+	BeneficiaryID uint    `json:"beneficiary_id"`
+	Level         int     `json:"level"`
+	Rate          float64 `json:"rate"`
+	Amount        float64 `json:"amount"`
+}
+
+// DefaultProgram 将出厂默认配置转换为推荐方案记录
+func DefaultProgram() models.ReferralProgram {
+	d := config.DefaultReferralProgram
+	serviceRates, _ := json.Marshal(d.ServiceRates)
+	productRates, _ := json.Marshal(d.ProductRates)
+	return models.ReferralProgram{
+		ServiceRates: datatypes.JSON(serviceRates),
+		ProductRates: datatypes.JSON(productRates),
+		MaxPerOrder:  d.MaxPerOrder,
+		MaxPerPeriod: d.MaxPerPeriod,
+		PeriodDays:   d.PeriodDays,
+	}
+}
+
+// Program 返回当前推荐方案；未配置时退回默认配置
+func Program(tx *gorm.DB) (*models.ReferralProgram, error) {
+	var program models.ReferralProgram
+	err := tx.Order("id ASC").First(&program).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		program = DefaultProgram()
+		return &program, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &program, nil
+}
+
+// Rates 解析各级佣金比例，格式错误时视为不分佣
+func Rates(raw datatypes.JSON) []float64 {
+	var rates []float64
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &rates)
+	}
+	return rates
+}
+
+// ratesFor 返回订单类型对应的各级佣金比例
+func ratesFor(program *models.ReferralProgram, orderType string) []float64 {
+	if orderType == "physical" {
+		return Rates(program.ProductRates)
+	}
+	return Rates(program.ServiceRates)
+}
+
+// Compute 计算订单沿推荐链各级受益人的佣金（只读，不写库）
+// 规则：
+//   - 第 N 级受益人比例 = 方案第 N 级比例 × 受益人等级的佣金倍数
+//   - 停用的受益人不分佣，但不影响更上级
+//   - 受益人周期内累计佣金不超过 MaxPerPeriod；订单佣金合计不超过 MaxPerOrder 与实付金额
+func Compute(tx *gorm.DB, order *models.Order, now time.Time) ([]Share, error) {
+	program, err := Program(tx)
+	if err != nil {
+		return nil, err
+	}
+	rates := ratesFor(program, order.OrderType)
+
+	var buyer models.Member
+	if err := tx.Select("id", "referrer_id").First(&buyer, order.MemberID).Error; err != nil {
+		return nil, err
+	}
+
+	paidCents := util.ToCents(order.PaidAmount)
+	orderRemaining := paidCents
+	if program.MaxPerOrder > 0 && util.ToCents(program.MaxPerOrder) < orderRemaining {
+		orderRemaining = util.ToCents(program.MaxPerOrder)
+	}
+
+	shares := make([]Share, 0, len(rates))
+	visited := map[uint]bool{buyer.ID: true}
+	current := buyer.ReferrerID
+	for level := 1; level <= len(rates) && current != nil && orderRemaining > 0; level++ {
+		// 推荐链出现环时停止
+		if visited[*current] {
+			break
+		}
+		visited[*current] = true
+
+		var beneficiary models.Member
+		if err := tx.Select("id", "level", "referrer_id", "is_active").First(&beneficiary, *current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		current = beneficiary.ReferrerID
+
+		if !beneficiary.IsActive || rates[level-1] <= 0 {
+			continue
+		}
+		multiplier, err := membership.CommissionMultiplier(tx, beneficiary.ID)
+		if err != nil {
+			return nil, err
+		}
+		rate := rates[level-1] * multiplier
+		cents := util.ToCents(util.CalculateRate(order.PaidAmount, rate))
+
+		if program.MaxPerPeriod > 0 {
+			since := now.AddDate(0, 0, -program.PeriodDays)
+			var earned float64
+			if err := tx.Model(&models.FissionLog{}).
+				Where("inviter_id = ? AND created_at >= ? AND status <> ?", beneficiary.ID, since, CommissionReversed).
+				Select("COALESCE(SUM(commission_amount), 0)").Scan(&earned).Error; err != nil {
+				return nil, err
+			}
+			if remaining := util.ToCents(program.MaxPerPeriod) - util.ToCents(earned); cents > remaining {
+				cents = remaining
+			}
+		}
+		if cents > orderRemaining {
+			cents = orderRemaining
+		}
+		if cents <= 0 {
+			continue
+		}
+		orderRemaining -= cents
+		shares = append(shares, Share{
+			BeneficiaryID: beneficiary.ID,
+			Level:         level,
+			Rate:          rate,
+			Amount:        util.CentsToYuan(cents),
+		})
+	}
+	return shares, nil
+}
+
+// Total 返回各级佣金合计
+func Total(shares []Share) float64 {
+	var cents int64
+	for _, s := range shares {
+		cents += util.ToCents(s.Amount)
+	}
+	return util.CentsToYuan(cents)
+}
+
+// Settle 结算订单佣金：为每位受益人写入待确认（pending）的分销日志，同时回写订单佣金合计
+// 佣金在持有期满后由 Release 计入推荐人佣金钱包；订单需已创建（需要订单ID）
+func Settle(tx *gorm.DB, order *models.Order, now time.Time) ([]models.FissionLog, error) {
+	shares, err := Compute(tx, order, now)
+	if err != nil {
+		return nil, err
+	}
+
+	availableAt := now.AddDate(0, 0, config.GlobalCommissionPolicy.HoldingDays)
+	logs := make([]models.FissionLog, 0, len(shares))
+	for _, s := range shares {
+		fissionLog := models.FissionLog{
+			InviterID:        s.BeneficiaryID,
+			InviteeID:        order.MemberID,
+			CommissionAmount: s.Amount,
+			OrderID:          &order.ID,
+			Level:            s.Level,
+			Rate:             s.Rate,
+			Status:           CommissionPending,
+			AvailableAt:      &availableAt,
+		}
+		if err := tx.Create(&fissionLog).Error; err != nil {
+			return nil, err
+		}
+		logs = append(logs, fissionLog)
+	}
+
+	order.CommissionAmount = Total(shares)
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Update("commission_amount", order.CommissionAmount).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
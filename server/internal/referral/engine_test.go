@@ -0,0 +1,121 @@
+package referral
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupReferralTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := database.AutoMigrate(&models.Member{}, &models.Order{}, &models.MemberTier{}, &models.FissionLog{}, &models.ReferralProgram{}, &models.CommissionWithdrawal{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+// createChain 创建推荐链 members[0] <- members[1] <- ... ，members[i] 的推荐人为 members[i-1]
+func createChain(t *testing.T, database *gorm.DB, n int) []models.Member {
+	t.Helper()
+	members := make([]models.Member, n)
+	for i := range members {
+		members[i] = models.Member{Name: string(rune('A' + i)), Phone: string(rune('A'+i)) + "-phone", InvitationCode: string(rune('A' + i))}
+		if i > 0 {
+			members[i].ReferrerID = &members[i-1].ID
+		}
+		if err := database.Create(&members[i]).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+	return members
+}
+
+func TestSettle_MultiLevelRatesByOrderType(t *testing.T) {
+	database := setupReferralTestDB(t)
+	database.Create(&models.ReferralProgram{
+		ServiceRates: datatypes.JSON(`[0.1, 0.05, 0.02]`),
+		ProductRates: datatypes.JSON(`[0.05]`),
+	})
+	// A <- B <- C <- D <- E：E 下单，D/C/B 分别为 1/2/3 级，A 超出层级
+	members := createChain(t, database, 5)
+	buyer := members[4]
+
+	seq := uint(1)
+	order := models.Order{MemberID: buyer.ID, PaidAmount: 200, OrderType: "service", AppointmentID: &seq}
+	database.Create(&order)
+
+	logs, err := Settle(database, &order, time.Now())
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	expected := map[uint]float64{members[3].ID: 20, members[2].ID: 10, members[1].ID: 4}
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 fission logs, got %d", len(logs))
+	}
+	for _, l := range logs {
+		if l.CommissionAmount != expected[l.InviterID] || l.InviteeID != buyer.ID || l.OrderID == nil || *l.OrderID != order.ID {
+			t.Fatalf("unexpected fission log: %+v", l)
+		}
+	}
+	var saved models.Order
+	database.First(&saved, order.ID)
+	if saved.CommissionAmount != 34 {
+		t.Fatalf("expected order commission 34, got %v", saved.CommissionAmount)
+	}
+	var level2 models.Member
+	database.First(&level2, members[2].ID)
+	if level2.Balance != 0 || logs[0].Status != CommissionPending {
+		t.Fatalf("expected commission held as pending, balance=%v status=%s", level2.Balance, logs[0].Status)
+	}
+
+	productOrder := models.Order{MemberID: buyer.ID, PaidAmount: 200, OrderType: "physical", InventoryLogID: &seq}
+	shares, err := Compute(database, &productOrder, time.Now())
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if len(shares) != 1 || shares[0].BeneficiaryID != members[3].ID || shares[0].Amount != 10 {
+		t.Fatalf("expected only direct referrer at product rate, got %+v", shares)
+	}
+}
+
+func TestCompute_CapsAndInactiveReferrers(t *testing.T) {
+	database := setupReferralTestDB(t)
+	database.Create(&models.ReferralProgram{
+		ServiceRates: datatypes.JSON(`[0.1, 0.1]`),
+		ProductRates: datatypes.JSON(`[0.1]`),
+		MaxPerOrder:  15,
+		MaxPerPeriod: 25,
+		PeriodDays:   30,
+	})
+	members := createChain(t, database, 3)
+	now := time.Now()
+
+	// 直接推荐人本周期已获得 20 元，仅剩 5 元额度
+	database.Create(&models.FissionLog{InviterID: members[1].ID, InviteeID: members[2].ID, CommissionAmount: 20})
+	seq := uint(1)
+	order := models.Order{MemberID: members[2].ID, PaidAmount: 200, OrderType: "service", AppointmentID: &seq}
+	shares, err := Compute(database, &order, now)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	// 1 级被周期上限截为 5，2 级 20 被单笔上限截为 10
+	if len(shares) != 2 || shares[0].Amount != 5 || shares[1].Amount != 10 || Total(shares) != 15 {
+		t.Fatalf("unexpected capped shares: %+v", shares)
+	}
+
+	// 停用的直接推荐人不分佣，上级仍按 2 级比例分佣
+	database.Model(&models.Member{}).Where("id = ?", members[1].ID).Update("is_active", false)
+	shares, _ = Compute(database, &order, now)
+	if len(shares) != 1 || shares[0].BeneficiaryID != members[0].ID || shares[0].Level != 2 {
+		t.Fatalf("expected only level-2 share after deactivation, got %+v", shares)
+	}
+}
@@ -0,0 +1,120 @@
+package referral
+
+import (
+	"errors"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 佣金状态
+const (
+	CommissionPending   = "pending"   // 持有期内，不可使用
+	CommissionConfirmed = "confirmed" // 持有期满且订单未退款
+	CommissionPaid      = "paid"      // 已计入推荐人佣金钱包
+	CommissionReversed  = "reversed"  // 订单在持有期内退款，佣金撤销
+)
+
+// ErrOrderRefunded 订单已退款
+var ErrOrderRefunded = errors.New("order already refunded")
+
+// ReleaseSummary 一次佣金发放任务的统计结果
+type ReleaseSummary struct {
+	Confirmed int `json:"confirmed"`
+	Paid      int `json:"paid"`
+	Reversed  int `json:"reversed"`
+}
+
+// Release 确认持有期已满的佣金并计入推荐人佣金钱包（与预存余额分开，可申请提现）
+// 每条佣金独立事务处理，并以状态作为条件更新，重复执行或并发执行不会重复入账
+func Release(database *gorm.DB, now time.Time) (ReleaseSummary, error) {
+	var summary ReleaseSummary
+
+	var due []models.FissionLog
+	if err := database.Where("status IN ? AND (available_at IS NULL OR available_at <= ?)",
+		[]string{CommissionPending, CommissionConfirmed}, now).
+		Order("id ASC").Find(&due).Error; err != nil {
+		return summary, err
+	}
+
+	for i := range due {
+		fissionLog := &due[i]
+		err := database.Transaction(func(tx *gorm.DB) error {
+			// 兜底：订单已退款但佣金仍为待确认时直接撤销
+			if fissionLog.OrderID != nil {
+				var order models.Order
+				if err := tx.Select("id", "refunded_at").First(&order, *fissionLog.OrderID).Error; err == nil && order.RefundedAt != nil {
+					n, err := reverseLogs(tx, tx.Where("id = ?", fissionLog.ID), "order refunded", now)
+					summary.Reversed += n
+					return err
+				}
+			}
+
+			if fissionLog.Status == CommissionPending {
+				res := tx.Model(&models.FissionLog{}).
+					Where("id = ? AND status = ?", fissionLog.ID, CommissionPending).
+					Updates(map[string]interface{}{"status": CommissionConfirmed, "confirmed_at": now})
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					return nil
+				}
+				summary.Confirmed++
+			}
+
+			res := tx.Model(&models.FissionLog{}).
+				Where("id = ? AND status = ?", fissionLog.ID, CommissionConfirmed).
+				Updates(map[string]interface{}{"status": CommissionPaid, "paid_at": now})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+			if err := tx.Model(&models.Member{}).Where("id = ?", fissionLog.InviterID).
+				Update("commission_balance", gorm.Expr("commission_balance + ?", fissionLog.CommissionAmount)).Error; err != nil {
+				return err
+			}
+			summary.Paid++
+			return nil
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// reverseLogs 将查询范围内尚未入账的佣金标记为撤销，返回撤销条数
+func reverseLogs(tx *gorm.DB, scope *gorm.DB, reason string, now time.Time) (int, error) {
+	res := scope.Model(&models.FissionLog{}).
+		Where("status IN ?", []string{CommissionPending, CommissionConfirmed}).
+		Updates(map[string]interface{}{"status": CommissionReversed, "reversed_at": now, "reverse_reason": reason})
+	return int(res.RowsAffected), res.Error
+}
+
+// ReverseForOrder 撤销订单尚未入账的佣金，并将订单佣金合计更新为仍有效的部分
+// 已过持有期并入账（paid）的佣金不再追回
+func ReverseForOrder(tx *gorm.DB, order *models.Order, reason string, now time.Time) (int, error) {
+	reversed, err := reverseLogs(tx, tx.Where("order_id = ?", order.ID), reason, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var remaining float64
+	if err := tx.Model(&models.FissionLog{}).
+		Where("order_id = ? AND status <> ?", order.ID, CommissionReversed).
+		Select("COALESCE(SUM(commission_amount), 0)").Scan(&remaining).Error; err != nil {
+		return 0, err
+	}
+	order.CommissionAmount = util.RoundMoney(remaining)
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Update("commission_amount", order.CommissionAmount).Error; err != nil {
+		return 0, err
+	}
+	return reversed, nil
+}
@@ -0,0 +1,60 @@
+package referral
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+)
+
+func TestRelease_PaysAfterHoldingPeriodAndSkipsReversed(t *testing.T) {
+	database := setupReferralTestDB(t)
+	members := createChain(t, database, 3)
+	now := time.Now()
+
+	seq1, seq2 := uint(1), uint(2)
+	kept := models.Order{MemberID: members[1].ID, PaidAmount: 100, OrderType: "service", AppointmentID: &seq1}
+	refunded := models.Order{MemberID: members[2].ID, PaidAmount: 300, OrderType: "service", AppointmentID: &seq2}
+	database.Create(&kept)
+	database.Create(&refunded)
+	if _, err := Settle(database, &kept, now); err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if _, err := Settle(database, &refunded, now); err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+
+	// 持有期内不发放
+	summary, err := Release(database, now.Add(time.Hour))
+	if err != nil || summary.Paid != 0 {
+		t.Fatalf("expected nothing released within holding period, summary=%+v err=%v", summary, err)
+	}
+
+	// 持有期内退款：佣金撤销，订单佣金清零
+	reversed, err := ReverseForOrder(database, &refunded, "order refunded", now.Add(2*time.Hour))
+	if err != nil || reversed != 1 || refunded.CommissionAmount != 0 {
+		t.Fatalf("expected 1 reversed commission, got %d (commission %.2f, err %v)", reversed, refunded.CommissionAmount, err)
+	}
+
+	later := now.AddDate(0, 0, config.GlobalCommissionPolicy.HoldingDays+1)
+	summary, err = Release(database, later)
+	if err != nil || summary.Confirmed != 1 || summary.Paid != 1 {
+		t.Fatalf("expected one commission paid, summary=%+v err=%v", summary, err)
+	}
+	// 重复执行不会重复入账
+	if summary, _ := Release(database, later); summary.Paid != 0 {
+		t.Fatalf("expected idempotent release, got %+v", summary)
+	}
+
+	var referrer models.Member
+	database.First(&referrer, members[0].ID)
+	if referrer.CommissionBalance != 10 || referrer.Balance != 0 {
+		t.Fatalf("expected commission wallet 10 and untouched balance, got wallet %v balance %v", referrer.CommissionBalance, referrer.Balance)
+	}
+	var direct models.Member
+	database.First(&direct, members[1].ID)
+	if direct.CommissionBalance != 0 {
+		t.Fatalf("expected reversed commission never credited, got wallet %v", direct.CommissionBalance)
+	}
+}
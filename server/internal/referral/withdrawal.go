@@ -0,0 +1,180 @@
+package referral
+
+import (
+	"errors"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/config"
+	"server/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+// 提现状态
+const (
+	WithdrawalPending  = "pending"  // 待审核，金额已从佣金钱包冻结
+	WithdrawalApproved = "approved" // 已审核，待线下打款
+	WithdrawalPaid     = "paid"     // 已打款
+	WithdrawalRejected = "rejected" // 已驳回，金额退回佣金钱包
+)
+
+var (
+	// ErrInsufficientCommission 佣金钱包余额不足
+	ErrInsufficientCommission = errors.New("insufficient commission balance")
+	// ErrWithdrawalBelowMinimum 提现金额低于最低限额
+	ErrWithdrawalBelowMinimum = errors.New("withdrawal amount below minimum")
+	// ErrWithdrawalState 提现申请当前状态不允许该操作
+	ErrWithdrawalState = errors.New("withdrawal is not in a valid state for this action")
+)
+
+// WithdrawalRequest 提现申请参数
+type WithdrawalRequest struct {
+	MemberID    uint
+	Amount      float64
+	Method      string
+	Account     string
+	Note        string
+	RequestedBy *uint
+}
+
+// RequestWithdrawal 从佣金钱包冻结提现金额并创建待审核的提现申请
+// 以余额充足作为条件扣减，并发申请不会透支钱包
+func RequestWithdrawal(tx *gorm.DB, req WithdrawalRequest) (*models.CommissionWithdrawal, error) {
+	amount := util.RoundMoney(req.Amount)
+	if amount <= 0 || amount < config.GlobalCommissionPolicy.MinWithdrawal {
+		return nil, ErrWithdrawalBelowMinimum
+	}
+
+	var member models.Member
+	if err := tx.Select("id").First(&member, req.MemberID).Error; err != nil {
+		return nil, err
+	}
+	res := tx.Model(&models.Member{}).
+		Where("id = ? AND commission_balance >= ?", req.MemberID, amount).
+		Update("commission_balance", gorm.Expr("commission_balance - ?", amount))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInsufficientCommission
+	}
+
+	withdrawal := models.CommissionWithdrawal{
+		MemberID:    req.MemberID,
+		Amount:      amount,
+		Status:      WithdrawalPending,
+		Method:      req.Method,
+		Account:     req.Account,
+		Note:        req.Note,
+		RequestedBy: req.RequestedBy,
+	}
+	if err := tx.Create(&withdrawal).Error; err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// transitionWithdrawal 以当前状态为条件更新提现申请，返回更新后的记录
+func transitionWithdrawal(tx *gorm.DB, id uint, from string, updates map[string]interface{}) (*models.CommissionWithdrawal, error) {
+	var withdrawal models.CommissionWithdrawal
+	if err := tx.First(&withdrawal, id).Error; err != nil {
+		return nil, err
+	}
+	res := tx.Model(&models.CommissionWithdrawal{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrWithdrawalState
+	}
+	if err := tx.First(&withdrawal, id).Error; err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// ApproveWithdrawal 审核通过待审核的提现申请
+func ApproveWithdrawal(tx *gorm.DB, id uint, reviewer *uint, now time.Time) (*models.CommissionWithdrawal, error) {
+	return transitionWithdrawal(tx, id, WithdrawalPending, map[string]interface{}{
+		"status":      WithdrawalApproved,
+		"reviewed_by": reviewer,
+		"reviewed_at": now,
+	})
+}
+
+// RejectWithdrawal 驳回待审核或待打款的提现申请，冻结金额退回佣金钱包
+func RejectWithdrawal(tx *gorm.DB, id uint, reason string, reviewer *uint, now time.Time) (*models.CommissionWithdrawal, error) {
+	updates := map[string]interface{}{
+		"status":        WithdrawalRejected,
+		"reject_reason": reason,
+		"reviewed_by":   reviewer,
+		"reviewed_at":   now,
+	}
+	withdrawal, err := transitionWithdrawal(tx, id, WithdrawalPending, updates)
+	if errors.Is(err, ErrWithdrawalState) {
+		withdrawal, err = transitionWithdrawal(tx, id, WithdrawalApproved, updates)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.Member{}).Where("id = ?", withdrawal.MemberID).
+		Update("commission_balance", gorm.Expr("commission_balance + ?", withdrawal.Amount)).Error; err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// MarkWithdrawalPaid 记录已审核提现的线下打款
+func MarkWithdrawalPaid(tx *gorm.DB, id uint, payoutRef string, paidBy *uint, now time.Time) (*models.CommissionWithdrawal, error) {
+	return transitionWithdrawal(tx, id, WithdrawalApproved, map[string]interface{}{
+		"status":     WithdrawalPaid,
+		"payout_ref": payoutRef,
+		"paid_by":    paidBy,
+		"paid_at":    now,
+	})
+}
+
+// Liability 门店对推荐人的佣金负债
+type Liability struct {
+	Pending       float64 `json:"pending"`        // 持有期内或已确认未入账的佣金
+	Wallet        float64 `json:"wallet"`         // 佣金钱包中可提现的余额
+	InWithdrawal  float64 `json:"in_withdrawal"`  // 已申请提现、尚未打款的金额
+	Outstanding   float64 `json:"outstanding"`    // 未结清负债合计 = Pending + Wallet + InWithdrawal
+	PaidOut       float64 `json:"paid_out"`       // 历史已打款合计
+	WalletHolders int64   `json:"wallet_holders"` // 佣金钱包有余额的会员数
+}
+
+// OutstandingLiability 汇总尚未支付给推荐人的佣金
+func OutstandingLiability(tx *gorm.DB) (Liability, error) {
+	var l Liability
+	if err := tx.Model(&models.FissionLog{}).
+		Where("status IN ?", []string{CommissionPending, CommissionConfirmed}).
+		Select("COALESCE(SUM(commission_amount), 0)").Scan(&l.Pending).Error; err != nil {
+		return l, err
+	}
+	if err := tx.Model(&models.Member{}).Where("commission_balance > 0").
+		Select("COALESCE(SUM(commission_balance), 0)").Scan(&l.Wallet).Error; err != nil {
+		return l, err
+	}
+	if err := tx.Model(&models.Member{}).Where("commission_balance > 0").Count(&l.WalletHolders).Error; err != nil {
+		return l, err
+	}
+	if err := tx.Model(&models.CommissionWithdrawal{}).
+		Where("status IN ?", []string{WithdrawalPending, WithdrawalApproved}).
+		Select("COALESCE(SUM(amount), 0)").Scan(&l.InWithdrawal).Error; err != nil {
+		return l, err
+	}
+	if err := tx.Model(&models.CommissionWithdrawal{}).Where("status = ?", WithdrawalPaid).
+		Select("COALESCE(SUM(amount), 0)").Scan(&l.PaidOut).Error; err != nil {
+		return l, err
+	}
+	l.Pending = util.RoundMoney(l.Pending)
+	l.Wallet = util.RoundMoney(l.Wallet)
+	l.InWithdrawal = util.RoundMoney(l.InWithdrawal)
+	l.PaidOut = util.RoundMoney(l.PaidOut)
+	l.Outstanding = util.RoundMoney(l.Pending + l.Wallet + l.InWithdrawal)
+	return l, nil
+}
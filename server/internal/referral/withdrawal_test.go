@@ -0,0 +1,70 @@
+package referral
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/models"
+)
+
+func TestWithdrawal_FreezeRejectAndPayout(t *testing.T) {
+	database := setupReferralTestDB(t)
+	members := createChain(t, database, 2)
+	referrer := members[0]
+	database.Model(&models.Member{}).Where("id = ?", referrer.ID).Update("commission_balance", 100)
+	database.Create(&models.FissionLog{InviterID: referrer.ID, InviteeID: members[1].ID, CommissionAmount: 30, Status: CommissionPending})
+	now := time.Now()
+
+	if _, err := RequestWithdrawal(database, WithdrawalRequest{MemberID: referrer.ID, Amount: 5, Method: "cash"}); !errors.Is(err, ErrWithdrawalBelowMinimum) {
+		t.Fatalf("expected minimum amount error, got %v", err)
+	}
+	if _, err := RequestWithdrawal(database, WithdrawalRequest{MemberID: referrer.ID, Amount: 120, Method: "cash"}); !errors.Is(err, ErrInsufficientCommission) {
+		t.Fatalf("expected insufficient balance error, got %v", err)
+	}
+
+	first, err := RequestWithdrawal(database, WithdrawalRequest{MemberID: referrer.ID, Amount: 60, Method: "bank", Account: "6222"})
+	if err != nil {
+		t.Fatalf("request withdrawal: %v", err)
+	}
+	second, err := RequestWithdrawal(database, WithdrawalRequest{MemberID: referrer.ID, Amount: 40, Method: "cash"})
+	if err != nil {
+		t.Fatalf("request withdrawal: %v", err)
+	}
+	var wallet models.Member
+	database.First(&wallet, referrer.ID)
+	if wallet.CommissionBalance != 0 {
+		t.Fatalf("expected wallet fully frozen, got %v", wallet.CommissionBalance)
+	}
+
+	// 未审核不可打款
+	if _, err := MarkWithdrawalPaid(database, first.ID, "TX1", nil, now); !errors.Is(err, ErrWithdrawalState) {
+		t.Fatalf("expected state error paying unapproved withdrawal, got %v", err)
+	}
+	if _, err := ApproveWithdrawal(database, first.ID, nil, now); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	paid, err := MarkWithdrawalPaid(database, first.ID, "TX1", nil, now)
+	if err != nil || paid.Status != WithdrawalPaid || paid.PayoutRef != "TX1" {
+		t.Fatalf("expected paid withdrawal, got %+v err=%v", paid, err)
+	}
+	if _, err := RejectWithdrawal(database, first.ID, "too late", nil, now); !errors.Is(err, ErrWithdrawalState) {
+		t.Fatalf("expected paid withdrawal to be final, got %v", err)
+	}
+
+	if _, err := RejectWithdrawal(database, second.ID, "account mismatch", nil, now); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	database.First(&wallet, referrer.ID)
+	if wallet.CommissionBalance != 40 {
+		t.Fatalf("expected rejected amount back in wallet, got %v", wallet.CommissionBalance)
+	}
+
+	liability, err := OutstandingLiability(database)
+	if err != nil {
+		t.Fatalf("liability: %v", err)
+	}
+	if liability.Pending != 30 || liability.Wallet != 40 || liability.InWithdrawal != 0 || liability.Outstanding != 70 || liability.PaidOut != 60 || liability.WalletHolders != 1 {
+		t.Fatalf("unexpected liability: %+v", liability)
+	}
+}
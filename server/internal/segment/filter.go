@@ -0,0 +1,240 @@
+// Package segment 实现会员分群的筛选 DSL，将 JSON 条件树编译为针对 members 表的 SQL 条件
+//
+// 条件树示例（金卡会员且近 90 天购买过 12 号商品）：
+//
+//	{"all": [
+//	  {"field": "level", "op": "eq", "value": "gold"},
+//	  {"field": "purchased_product", "op": "eq", "value": 12, "within_days": 90}
+//	]}
+//
+// 组合节点: all（且）、any（或）、not（非）；叶子节点由 field/op/value 组成，
+// 订单类字段可用 within_days 限定统计最近 N 天，已退款的订单不计入（与 RFM 一致）。
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter 筛选条件不合法
+var ErrInvalidFilter = errors.New("invalid segment filter")
+
+// Filter 筛选条件树的一个节点
+type Filter struct {
+	All        []Filter    `json:"all,omitempty"`
+	Any        []Filter    `json:"any,omitempty"`
+	Not        *Filter     `json:"not,omitempty"`
+	Field      string      `json:"field,omitempty"`
+	Op         string      `json:"op,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	WithinDays int         `json:"within_days,omitempty"`
+}
+
+// 会员表上可直接比较的字段
+var memberColumns = map[string]string{
+	"level":                    "members.level",
+	"balance":                  "members.balance",
+	"points":                   "members.points",
+	"yearly_total_consumption": "members.yearly_total_consumption",
+	"gender":                   "members.gender",
+	"is_active":                "members.is_active",
+	"preferred_contact":        "members.preferred_contact",
+	"marketing_opt_in":         "members.marketing_opt_in",
+}
+
+var comparisonOps = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// Parse 解析并校验 JSON 格式的筛选条件
+func Parse(raw []byte) (*Filter, error) {
+	var f Filter
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	if _, _, err := Compile(&f, time.Now()); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Compile 将条件树编译为 SQL 条件与参数，可直接用于 members 表的 Where
+func Compile(f *Filter, now time.Time) (string, []interface{}, error) {
+	switch {
+	case len(f.All) > 0:
+		return compileGroup(f.All, " AND ", now)
+	case len(f.Any) > 0:
+		return compileGroup(f.Any, " OR ", now)
+	case f.Not != nil:
+		sql, args, err := Compile(f.Not, now)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + sql + ")", args, nil
+	case f.Field != "":
+		return compileLeaf(f, now)
+	default:
+		return "", nil, fmt.Errorf("%w: empty condition", ErrInvalidFilter)
+	}
+}
+
+func compileGroup(children []Filter, sep string, now time.Time) (string, []interface{}, error) {
+	parts := make([]string, 0, len(children))
+	var args []interface{}
+	for i := range children {
+		sql, childArgs, err := Compile(&children[i], now)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "("+sql+")")
+		args = append(args, childArgs...)
+	}
+	return strings.Join(parts, sep), args, nil
+}
+
+// windowClause 返回订单子查询的时间窗口条件
+func windowClause(f *Filter, now time.Time, column string) (string, []interface{}) {
+	if f.WithinDays <= 0 {
+		return "", nil
+	}
+	return " AND " + column + " >= ?", []interface{}{now.AddDate(0, 0, -f.WithinDays)}
+}
+
+// compare 生成 "expr op ?" 形式的比较条件，支持 in
+func compare(expr string, f *Filter) (string, []interface{}, error) {
+	if f.Op == "in" {
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", nil, fmt.Errorf("%w: %s in requires a non-empty array", ErrInvalidFilter, f.Field)
+		}
+		return expr + " IN ?", []interface{}{values}, nil
+	}
+	op, ok := comparisonOps[f.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unsupported op %q for %s", ErrInvalidFilter, f.Op, f.Field)
+	}
+	if f.Value == nil {
+		return "", nil, fmt.Errorf("%w: %s requires a value", ErrInvalidFilter, f.Field)
+	}
+	return expr + " " + op + " ?", []interface{}{f.Value}, nil
+}
+
+// existence 生成存在/不存在子查询：eq 表示存在，ne 表示不存在
+func existence(subquery string, args []interface{}, f *Filter) (string, []interface{}, error) {
+	if f.Value == nil {
+		return "", nil, fmt.Errorf("%w: %s requires a value", ErrInvalidFilter, f.Field)
+	}
+	switch f.Op {
+	case "eq", "":
+		return "EXISTS (" + subquery + ")", args, nil
+	case "ne":
+		return "NOT EXISTS (" + subquery + ")", args, nil
+	default:
+		return "", nil, fmt.Errorf("%w: %s only supports eq/ne", ErrInvalidFilter, f.Field)
+	}
+}
+
+func compileLeaf(f *Filter, now time.Time) (string, []interface{}, error) {
+	if column, ok := memberColumns[f.Field]; ok {
+		return compare(column, f)
+	}
+
+	switch f.Field {
+	case "tag":
+		return existence("SELECT 1 FROM member_tags mt JOIN tags t ON t.id = mt.tag_id AND t.deleted_at IS NULL"+
+			" WHERE mt.member_id = members.id AND t.name = ?", []interface{}{f.Value}, f)
+
+	case "rfm_segment":
+		sql, args, err := compare("r.segment", f)
+		if err != nil {
+			return "", nil, err
+		}
+		return "EXISTS (SELECT 1 FROM member_rfms r WHERE r.member_id = members.id AND " + sql + ")", args, nil
+
+	case "churn_risk":
+		return existence("SELECT 1 FROM member_rfms r WHERE r.member_id = members.id AND r.churn_risk = ?",
+			[]interface{}{true}, &Filter{Field: f.Field, Op: boolOp(f), Value: true})
+
+	case "order_count", "order_total":
+		agg := "COUNT(*)"
+		if f.Field == "order_total" {
+			agg = "COALESCE(SUM(o.paid_amount), 0)"
+		}
+		window, windowArgs := windowClause(f, now, "o.created_at")
+		sql, args, err := compare("(SELECT "+agg+" FROM orders o WHERE o.member_id = members.id AND o.deleted_at IS NULL AND o.refunded_at IS NULL"+window+")", f)
+		if err != nil {
+			return "", nil, err
+		}
+		return sql, append(windowArgs, args...), nil
+
+	case "days_since_last_order":
+		// 无消费记录的会员视为无限久未到店
+		days, ok := toFloat(f.Value)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: days_since_last_order requires a number", ErrInvalidFilter)
+		}
+		cutoff := now.Add(-time.Duration(days * float64(24*time.Hour)))
+		last := "(SELECT MAX(o.created_at) FROM orders o WHERE o.member_id = members.id AND o.deleted_at IS NULL AND o.refunded_at IS NULL)"
+		switch f.Op {
+		case "gt", "gte":
+			return "(" + last + " IS NULL OR " + last + " <= ?)", []interface{}{cutoff}, nil
+		case "lt", "lte":
+			return last + " >= ?", []interface{}{cutoff}, nil
+		default:
+			return "", nil, fmt.Errorf("%w: days_since_last_order supports gt/gte/lt/lte", ErrInvalidFilter)
+		}
+
+	case "purchased_product":
+		window, windowArgs := windowClause(f, now, "o.created_at")
+		return existence("SELECT 1 FROM orders o JOIN inventory_logs il ON il.id = o.inventory_log_id"+
+			" WHERE o.member_id = members.id AND o.deleted_at IS NULL AND o.refunded_at IS NULL AND il.product_id = ?"+window,
+			append([]interface{}{f.Value}, windowArgs...), f)
+
+	case "used_service":
+		window, windowArgs := windowClause(f, now, "o.created_at")
+		return existence("SELECT 1 FROM orders o JOIN appointments a ON a.id = o.appointment_id"+
+			" WHERE o.member_id = members.id AND o.deleted_at IS NULL AND o.refunded_at IS NULL AND a.service_id = ?"+window,
+			append([]interface{}{f.Value}, windowArgs...), f)
+	}
+
+	return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, f.Field)
+}
+
+// boolOp 将布尔值条件转换为存在性操作：op 为 ne 与 value 为 false 各取反一次
+func boolOp(f *Filter) string {
+	negate := f.Op == "ne"
+	if v, ok := f.Value.(bool); ok && !v {
+		negate = !negate
+	}
+	if negate {
+		return "ne"
+	}
+	return "eq"
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// MemberIDSubquery 返回满足条件的会员ID子查询，用于订单、预约等其他表按会员筛选
+func MemberIDSubquery(f *Filter, now time.Time) (string, []interface{}, error) {
+	sql, args, err := Compile(f, now)
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT members.id FROM members WHERE members.deleted_at IS NULL AND (" + sql + ")", args, nil
+}
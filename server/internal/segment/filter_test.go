@@ -0,0 +1,47 @@
+package segment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_RejectsInvalidFilters(t *testing.T) {
+	cases := []string{
+		`{}`,
+		`{"field": "unknown", "op": "eq", "value": 1}`,
+		`{"field": "level", "op": "like", "value": "gold"}`,
+		`{"field": "level", "op": "in", "value": []}`,
+		`{"all": [{"field": "tag", "op": "gt", "value": "vip"}]}`,
+		`{"field": "days_since_last_order", "op": "eq", "value": 30}`,
+	}
+	for _, raw := range cases {
+		if _, err := Parse([]byte(raw)); !errors.Is(err, ErrInvalidFilter) {
+			t.Fatalf("expected ErrInvalidFilter for %s, got %v", raw, err)
+		}
+	}
+}
+
+func TestCompile_NestedGroups(t *testing.T) {
+	f, err := Parse([]byte(`{"all": [
+		{"field": "level", "op": "in", "value": ["gold", "platinum"]},
+		{"any": [
+			{"field": "tag", "op": "eq", "value": "vip"},
+			{"not": {"field": "churn_risk", "op": "eq", "value": false}}
+		]}
+	]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sql, args, err := Compile(f, time.Now())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if !strings.Contains(sql, "members.level IN ?") || !strings.Contains(sql, " OR ") || !strings.Contains(sql, "NOT (NOT EXISTS") {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+	if len(args) != 3 {
+		t.Fatalf("expected 3 args, got %d: %v", len(args), args)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"server/internal/db"
 	"server/internal/handlers"
+	"server/internal/jobs"
 	"server/internal/middleware"
 	"server/internal/response"
 
@@ -18,6 +19,9 @@ func main() {
 		log.Fatalf("failed to init database: %v", err)
 	}
 
+	// Start background jobs
+	jobs.Start(database)
+
 	// Initialize handlers
 	dashboardHandler := handlers.NewDashboardHandler(database)
 
@@ -66,6 +70,8 @@ func main() {
 		api.POST("/appointments", handlers.CreateAppointment)
 		api.PUT("/appointments/:id/cancel", handlers.CancelAppointment)
 		api.PUT("/appointments/:id/complete", handlers.CompleteAppointment)
+		api.GET("/appointments/:id/notes", handlers.ListAppointmentTreatmentNotes)
+		api.POST("/appointments/:id/notes", handlers.CreateTreatmentNote)
 
 		// Fission ranking (both manager and operator)
 		api.GET("/fission/ranking", dashboardHandler.GetFissionRanking)
@@ -82,10 +88,31 @@ func main() {
 
 		// Services (read for all, write for manager only)
 		api.GET("/services", handlers.ListServiceItems)
+		api.GET("/services/:id/materials", handlers.GetServiceMaterials)
 
 		// Members (both manager and operator)
 		api.GET("/members", handlers.ListMembers)
+		api.GET("/members/:id", handlers.GetMember)
 		api.POST("/members", handlers.CreateMember)
+		api.PUT("/members/:id", handlers.UpdateMember)
+		api.POST("/members/:id/referrer", handlers.BindMemberReferrer)
+		api.GET("/members/:id/level-history", handlers.GetMemberLevelHistory)
+		api.GET("/members/:id/points", handlers.GetMemberPoints)
+		api.GET("/member-tiers", handlers.ListMemberTiers)
+		api.PUT("/members/:id/tags", handlers.SetMemberTags)
+		api.GET("/members/:id/health-intake", handlers.GetMemberHealthIntake)
+		api.PUT("/members/:id/health-intake", handlers.SaveMemberHealthIntake)
+		api.GET("/members/:id/health-intake/history", handlers.ListMemberHealthIntakes)
+		api.GET("/members/:id/treatment-notes", handlers.ListMemberTreatmentNotes)
+		api.POST("/members/:id/commission-withdrawals", handlers.CreateCommissionWithdrawal)
+		api.GET("/commission-withdrawals", handlers.ListCommissionWithdrawals)
+		api.GET("/health-conditions", handlers.ListHealthConditions)
+
+		// Member tags and saved segments (read/preview for all)
+		api.GET("/tags", handlers.ListTags)
+		api.POST("/tags", handlers.CreateTag)
+		api.GET("/segments", handlers.ListSegments)
+		api.POST("/segments/preview", handlers.PreviewSegment)
 
 		api.POST("/orders", handlers.CreateOrder)
 		api.GET("/orders", handlers.ListOrders)
@@ -94,6 +121,10 @@ func main() {
 		api.GET("/products", handlers.ListProducts)
 		api.GET("/products/:id", handlers.GetProduct)
 		api.GET("/products/stats", handlers.GetProductStats)
+		api.GET("/products/barcode/:code", handlers.GetProductByBarcode)
+		api.GET("/product-categories", handlers.ListProductCategories)
+		api.GET("/product-parents", handlers.ListParentProducts)
+		api.GET("/product-parents/:id", handlers.GetParentProduct)
 
 		// Inventory (both manager and operator can view and change)
 		api.GET("/inventory/logs", handlers.ListInventoryLogs)
@@ -101,6 +132,24 @@ func main() {
 		api.POST("/inventory/change", handlers.CreateInventoryChange)
 		api.POST("/inventory/batch-restock", handlers.BatchRestock)
 		api.GET("/inventory/stats", handlers.GetInventoryStats)
+		api.GET("/inventory/batches", handlers.ListProductBatches)
+		api.GET("/inventory/batches/expiring", handlers.GetExpiringBatches)
+		api.POST("/inventory/batches/:id/write-off", handlers.WriteOffProductBatch)
+		api.GET("/inventory/low-stock", handlers.GetLowStockProducts)
+		api.GET("/inventory/alerts", handlers.ListStockAlerts)
+
+		// Suppliers and purchase orders (all staff can view and receive goods)
+		api.GET("/suppliers", handlers.ListSuppliers)
+		api.GET("/purchase-orders", handlers.ListPurchaseOrders)
+		api.GET("/purchase-orders/on-order", handlers.GetOnOrderQuantities)
+		api.GET("/purchase-orders/:id", handlers.GetPurchaseOrder)
+		api.POST("/purchase-orders/:id/receive", handlers.ReceivePurchaseOrder)
+
+		// Stocktakes (all staff can open sessions and enter counts)
+		api.GET("/stocktakes", handlers.ListStocktakes)
+		api.GET("/stocktakes/:id", handlers.GetStocktake)
+		api.POST("/stocktakes", handlers.CreateStocktake)
+		api.PUT("/stocktakes/:id/counts", handlers.SaveStocktakeCounts)
 	}
 
 	// Manager-only routes
@@ -120,11 +169,76 @@ func main() {
 		managerAPI.POST("/services", handlers.CreateServiceItem)
 		managerAPI.PUT("/services/:id", handlers.UpdateServiceItem)
 		managerAPI.DELETE("/services/:id", handlers.DeleteServiceItem)
+		managerAPI.PUT("/services/:id/materials", handlers.UpdateServiceMaterials)
 
 		// Product management (manager only for create/update/delete)
 		managerAPI.POST("/products", handlers.CreateProduct)
 		managerAPI.PUT("/products/:id", handlers.UpdateProduct)
 		managerAPI.DELETE("/products/:id", handlers.DeleteProduct)
+		managerAPI.POST("/product-categories", handlers.CreateProductCategory)
+		managerAPI.PUT("/product-categories/:id", handlers.UpdateProductCategory)
+		managerAPI.DELETE("/product-categories/:id", handlers.DeleteProductCategory)
+		managerAPI.POST("/product-parents", handlers.CreateParentProduct)
+		managerAPI.PUT("/product-parents/:id", handlers.UpdateParentProduct)
+		managerAPI.DELETE("/product-parents/:id", handlers.DeleteParentProduct)
+
+		// Supplier management and purchase ordering (manager only)
+		managerAPI.POST("/suppliers", handlers.CreateSupplier)
+		managerAPI.PUT("/suppliers/:id", handlers.UpdateSupplier)
+		managerAPI.DELETE("/suppliers/:id", handlers.DeleteSupplier)
+		managerAPI.POST("/purchase-orders", handlers.CreatePurchaseOrder)
+		managerAPI.PUT("/purchase-orders/:id", handlers.UpdatePurchaseOrder)
+		managerAPI.POST("/purchase-orders/:id/submit", handlers.SubmitPurchaseOrder)
+		managerAPI.POST("/purchase-orders/:id/cancel", handlers.CancelPurchaseOrder)
+
+		// Stocktake approval (manager only)
+		managerAPI.POST("/stocktakes/:id/approve", handlers.ApproveStocktake)
+		managerAPI.POST("/stocktakes/:id/cancel", handlers.CancelStocktake)
+
+		// Member deactivation and merge (manager only)
+		managerAPI.DELETE("/members/:id", handlers.DeactivateMember)
+		managerAPI.POST("/members/:id/merge", handlers.MergeMember)
+		managerAPI.POST("/member-levels/recalculate", handlers.RecalculateMemberLevels)
+
+		// Member tier configuration (manager only)
+		managerAPI.POST("/member-tiers", handlers.CreateMemberTier)
+		managerAPI.PUT("/member-tiers/:id", handlers.UpdateMemberTier)
+		managerAPI.DELETE("/member-tiers/:id", handlers.DeleteMemberTier)
+
+		// Birthday / anniversary campaigns (manager only)
+		managerAPI.GET("/campaign-issues", handlers.ListCampaignIssues)
+		managerAPI.POST("/campaigns/run", handlers.RunMemberCampaigns)
+
+		// RFM segments and churn-risk lists (manager only)
+		managerAPI.GET("/member-segments", handlers.GetMemberSegments)
+		managerAPI.GET("/member-segments/members", handlers.ListSegmentMembers)
+		managerAPI.POST("/member-segments/recalculate", handlers.RecalculateMemberSegments)
+
+		// Multi-level referral commission program (manager only)
+		managerAPI.GET("/referral-program", handlers.GetReferralProgram)
+		managerAPI.PUT("/referral-program", handlers.UpdateReferralProgram)
+
+		// Referral tree, attribution and commission audit (manager only)
+		managerAPI.GET("/members/:id/referral-tree", dashboardHandler.GetReferralTree)
+		managerAPI.GET("/dashboard/referral-attribution", dashboardHandler.GetReferralAttribution)
+		managerAPI.GET("/fission/logs", dashboardHandler.ListFissionLogs)
+
+		// Commission withdrawal review, payout and liability (manager only)
+		managerAPI.POST("/commission-withdrawals/:id/approve", handlers.ApproveCommissionWithdrawal)
+		managerAPI.POST("/commission-withdrawals/:id/reject", handlers.RejectCommissionWithdrawal)
+		managerAPI.POST("/commission-withdrawals/:id/pay", handlers.PayCommissionWithdrawal)
+		managerAPI.GET("/commission/liability", handlers.GetCommissionLiability)
+
+		// Order refunds; reverses commission still in its holding period (manager only)
+		managerAPI.POST("/orders/:id/refund", handlers.RefundOrder)
+
+		// Saved segments and bulk actions (manager only)
+		managerAPI.DELETE("/tags/:id", handlers.DeleteTag)
+		managerAPI.POST("/segments", handlers.CreateSegment)
+		managerAPI.PUT("/segments/:id", handlers.UpdateSegment)
+		managerAPI.DELETE("/segments/:id", handlers.DeleteSegment)
+		managerAPI.POST("/segments/:id/actions", handlers.RunSegmentAction)
+		managerAPI.GET("/segments/:id/actions", handlers.ListSegmentActionLogs)
 
 		// Member AI profile (manager only)
 		managerAPI.GET("/members/:id/ai-profile", handlers.GenerateMemberAIProfile)
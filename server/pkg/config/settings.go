@@ -21,36 +21,137 @@ var GlobalBusinessHours = BusinessHours{
 	TimeLocation: time.UTC,         // 兼容你原有代码的时区，后续可改成Asia/Shanghai
 }
 
-type MemberDiscountConfig struct {
-	Platinum float64 // 白金会员折扣率 (e.g. 0.8 for 20% off)
-	Gold     float64 // 金牌会员折扣率
-	Silver   float64 // 银牌会员折扣率
-	Basic    float64 // 普通会员折扣率 (usually 1.0)
+// ReferralProgramConfig 推荐分佣方案的出厂默认配置，未在后台配置方案时使用
+type ReferralProgramConfig struct {
+	ServiceRates []float64 // 服务订单各级佣金比例，第1项为直接推荐人 (e.g. 0.1 for 10%)
+	ProductRates []float64 // 商品订单各级佣金比例
+	MaxPerOrder  float64   // 单笔订单佣金合计上限（元），0 表示不限
+	MaxPerPeriod float64   // 每位受益人在统计周期内的佣金上限（元），0 表示不限
+	PeriodDays   int       // 佣金上限的统计周期天数
 }
 
-var GlobalMemberDiscount = MemberDiscountConfig{
-	Platinum: 0.8,
-	Gold:     0.9,
-	Silver:   0.95,
-	Basic:    1.0,
+var DefaultReferralProgram = ReferralProgramConfig{
+	ServiceRates: []float64{0.1},
+	ProductRates: []float64{0.1},
+	MaxPerOrder:  0,
+	MaxPerPeriod: 0,
+	PeriodDays:   30,
 }
 
-type CommissionConfig struct {
-	ReferralRate float64 // 推荐佣金比例 (e.g. 0.1 for 10%)
+type CommissionPolicy struct {
+	HoldingDays     int           // 佣金持有期天数：期内订单退款则佣金自动撤销，期满后才计入推荐人佣金钱包
+	ReleaseInterval time.Duration // 定时确认并发放到期佣金的间隔
+	MinWithdrawal   float64       // 单次提现最低金额（元）
 }
 
-var GlobalCommission = CommissionConfig{
-	ReferralRate: 0.1,
+var GlobalCommissionPolicy = CommissionPolicy{
+	HoldingDays:     7,
+	ReleaseInterval: time.Hour,
+	MinWithdrawal:   10,
 }
 
-type MemberUpgradeThresholds struct {
-	Platinum float64 // 白金会员升级阈值（年度消费额，单位：元）
-	Gold     float64 // 金卡会员升级阈值（年度消费额，单位：元）
-	Silver   float64 // 银卡会员升级阈值（年度消费额，单位：元）
+// MemberTierDefault 会员等级的出厂默认配置，仅在等级表为空时用于初始化
+type MemberTierDefault struct {
+	Name                 string   // 等级标识（会员 level 字段的取值）
+	DisplayName          string   // 展示名称
+	Threshold            float64  // 升级阈值（统计期内消费额超过该值，单位：元）
+	ServiceDiscount      float64  // 服务折扣率 (e.g. 0.8 for 20% off)
+	ProductDiscount      float64  // 商品折扣率
+	CommissionMultiplier float64  // 推荐佣金倍数（作为推荐人时佣金比例乘以该值）
+	PointsMultiplier     float64  // 积分倍数（消费获得积分时乘以该值）
+	Perks                []string // 其他权益说明
 }
 
-var GlobalMemberUpgrade = MemberUpgradeThresholds{
-	Platinum: 10000, // 10,000元
-	Gold:     5000,  // 5,000元
-	Silver:   1000,  // 1,000元
+var DefaultMemberTiers = []MemberTierDefault{
+	{Name: "basic", DisplayName: "普通", Threshold: 0, ServiceDiscount: 1.0, ProductDiscount: 1.0, CommissionMultiplier: 1.0, PointsMultiplier: 1.0},
+	{Name: "silver", DisplayName: "白银", Threshold: 1000, ServiceDiscount: 0.95, ProductDiscount: 0.95, CommissionMultiplier: 1.0, PointsMultiplier: 1.2},
+	{Name: "gold", DisplayName: "黄金", Threshold: 5000, ServiceDiscount: 0.9, ProductDiscount: 0.9, CommissionMultiplier: 1.0, PointsMultiplier: 1.5},
+	{Name: "platinum", DisplayName: "白金", Threshold: 10000, ServiceDiscount: 0.8, ProductDiscount: 0.8, CommissionMultiplier: 1.0, PointsMultiplier: 2.0},
+}
+
+type MemberLevelPolicy struct {
+	ConsumptionWindow     string        // 消费统计窗口："rolling"（近12个月滚动）或 "calendar"（自然年）
+	ConsumptionOrderTypes []string      // 计入会员消费额的订单类型（service/physical）
+	GraceDays             int           // 保级期天数：消费不足后仍保留当前等级的天数
+	MaxDowngradeSteps     int           // 每次评估最多下降的等级数
+	RecalculateInterval   time.Duration // 定时重算会员等级的间隔
+}
+
+var GlobalMemberLevelPolicy = MemberLevelPolicy{
+	ConsumptionWindow:     "rolling",
+	ConsumptionOrderTypes: []string{"service", "physical"},
+	GraceDays:             30,
+	MaxDowngradeSteps:     1,
+	RecalculateInterval:   24 * time.Hour,
+}
+
+type PointsPolicy struct {
+	PointsPerYuan      float64       // 每实付1元获得的基础积分
+	BirthdayMultiplier float64       // 生日当天消费的积分倍数
+	YuanPerPoint       float64       // 积分抵扣时每积分抵扣的金额（单位：元）
+	MaxRedeemRatio     float64       // 单笔订单最多可用积分抵扣的比例
+	ValidityDays       int           // 积分有效期天数（自获得之日起）
+	ExpireInterval     time.Duration // 定时清理过期积分的间隔
+}
+
+var GlobalPointsPolicy = PointsPolicy{
+	PointsPerYuan:      1,
+	BirthdayMultiplier: 2,
+	YuanPerPoint:       0.01, // 100积分抵1元
+	MaxRedeemRatio:     0.5,
+	ValidityDays:       365,
+	ExpireInterval:     24 * time.Hour,
+}
+
+type CampaignReward struct {
+	Enabled         bool    // 是否启用
+	LeadDays        int     // 提前发放天数（0 表示当天发放）
+	RewardType      string  // "coupon"(优惠券) 或 "balance"(赠送余额)
+	Amount          float64 // 优惠券面额或赠送余额（单位：元）
+	MinSpend        float64 // 优惠券使用门槛（单位：元）
+	CouponValidDays int     // 优惠券有效天数
+}
+
+type CampaignPolicy struct {
+	Birthday    CampaignReward // 生日活动
+	Anniversary CampaignReward // 入会周年活动
+	RunInterval time.Duration  // 活动任务执行间隔
+}
+
+var GlobalCampaignPolicy = CampaignPolicy{
+	Birthday:    CampaignReward{Enabled: true, LeadDays: 7, RewardType: "coupon", Amount: 50, MinSpend: 100, CouponValidDays: 30},
+	Anniversary: CampaignReward{Enabled: true, LeadDays: 0, RewardType: "balance", Amount: 20},
+	RunInterval: 24 * time.Hour,
+}
+
+type RFMPolicy struct {
+	WindowDays          int           // 频次与金额的统计窗口天数
+	RecencyDays         []int         // 距上次消费天数分档（升序），落在第 i 档得 5-i 分，超过全部分档得 1 分
+	FrequencyCounts     []int         // 消费次数分档（升序），达到第 i 档得 i+2 分
+	MonetaryAmounts     []float64     // 消费金额分档（升序，单位：元），达到第 i 档得 i+2 分
+	ChurnIntervalFactor float64       // 距上次消费天数超过个人平均消费间隔的倍数即视为流失风险
+	ChurnMinDays        int           // 判定流失风险的最少未到店天数，避免高频会员因短暂间隔被误判
+	RecalculateInterval time.Duration // 定时重算 RFM 的间隔
+}
+
+var GlobalRFMPolicy = RFMPolicy{
+	WindowDays:          365,
+	RecencyDays:         []int{30, 60, 90, 180},
+	FrequencyCounts:     []int{2, 4, 8, 12},
+	MonetaryAmounts:     []float64{500, 1500, 4000, 10000},
+	ChurnIntervalFactor: 2,
+	ChurnMinDays:        30,
+	RecalculateInterval: 24 * time.Hour,
+}
+
+type InventoryPolicy struct {
+	DefaultReorderPoint int // 新建商品未指定补货点时的默认值
+	VelocityWindowDays  int // 计算日均销量的统计天数
+	CoverDays           int // 未设置目标库存时，建议补货量额外覆盖的预计销售天数
+}
+
+var GlobalInventoryPolicy = InventoryPolicy{
+	DefaultReorderPoint: 10,
+	VelocityWindowDays:  30,
+	CoverDays:           14,
 }
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// invitationCodeAlphabet 邀请码字符集，去掉了易混淆的 0/O、1/I/L
+const invitationCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// InvitationCodeLength 邀请码默认长度
+const InvitationCodeLength = 6
+
+// GenerateInvitationCode 生成便于口述和手动输入的随机邀请码
+// length: 邀请码长度
+// 返回值: 由大写字母与数字组成的邀请码（唯一性需由调用方结合数据库校验）
+func GenerateInvitationCode(length int) (string, error) {
+	max := big.NewInt(int64(len(invitationCodeAlphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = invitationCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -1,10 +1,6 @@
 package util
 
-import (
-	"math"
-
-	"server/pkg/config"
-)
+import "math"
 
 // RoundMoney 将金额四舍五入保留两位小数
 // val: 金额（单位：元）
@@ -35,19 +31,3 @@ func ToCents(amount float64) int64 {
 func CentsToYuan(cents int64) float64 {
 	return float64(cents) / 100.0
 }
-
-// CalculateMemberLevel 根据年度消费额计算会员等级
-// consumption: 年度消费总额（单位：元）
-// 返回值: 对应的会员等级字符串 (platinum/gold/silver/basic)
-func CalculateMemberLevel(consumption float64) string {
-	switch {
-	case consumption > config.GlobalMemberUpgrade.Platinum:
-		return "platinum"
-	case consumption > config.GlobalMemberUpgrade.Gold:
-		return "gold"
-	case consumption > config.GlobalMemberUpgrade.Silver:
-		return "silver"
-	default:
-		return "basic"
-	}
-}